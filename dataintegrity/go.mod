@@ -0,0 +1,3 @@
+module github.com/bradtumy/credential-service/dataintegrity
+
+go 1.21
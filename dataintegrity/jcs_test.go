@@ -0,0 +1,107 @@
+package dataintegrity
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	// RFC 8785, section 3.2.2
+	input := `{
+		"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+		"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+		"literals": [null, true, false]
+	}`
+	expected := `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`
+
+	canonical, err := Canonicalize(json.RawMessage(input))
+	if err != nil {
+		t.Fatalf("Failed to canonicalize: %v", err)
+	}
+	if string(canonical) != expected {
+		t.Errorf("Unexpected canonical form:\n got %s\nwant %s", canonical, expected)
+	}
+}
+
+func TestCanonicalizeSortsByUTF16(t *testing.T) {
+	// RFC 8785, section 3.2.3
+	input := `{
+		"€": "Euro Sign",
+		"\r": "Carriage Return",
+		"דּ": "Hebrew Letter Dalet With Dagesh",
+		"1": "One",
+		"😀": "Emoji: Grinning Face",
+		"\u0080": "Control",
+		"ö": "Latin Small Letter O With Diaeresis"
+	}`
+	expected := `{"\r":"Carriage Return","1":"One","` + "\u0080" + `":"Control","ö":"Latin Small Letter O With Diaeresis",` +
+		`"€":"Euro Sign","😀":"Emoji: Grinning Face","דּ":"Hebrew Letter Dalet With Dagesh"}`
+
+	canonical, err := Canonicalize(json.RawMessage(input))
+	if err != nil {
+		t.Fatalf("Failed to canonicalize: %v", err)
+	}
+	if string(canonical) != expected {
+		t.Errorf("Unexpected canonical form:\n got %s\nwant %s", canonical, expected)
+	}
+}
+
+func TestCanonicalizeStructs(t *testing.T) {
+	value := struct {
+		B int               `json:"b"`
+		A map[string]string `json:"a"`
+	}{B: 1, A: map[string]string{"d": "<x>", "c": "\b "}}
+
+	canonical, err := Canonicalize(value)
+	if err != nil {
+		t.Fatalf("Failed to canonicalize: %v", err)
+	}
+	if expected := `{"a":{"c":"\b` + " " + `","d":"<x>"},"b":1}`; string(canonical) != expected {
+		t.Errorf("Expected %s, got %s", expected, canonical)
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	// RFC 8785, appendix B
+	tests := []struct {
+		bits     uint64
+		expected string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0xffefffffffffffff, "-1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0xc340000000000000, "-9007199254740992"},
+		{0x4430000000000000, "295147905179352830000"},
+		{0x44b52d02c7e14af5, "9.999999999999997e+22"},
+		{0x44b52d02c7e14af6, "1e+23"},
+		{0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+		{0x444b1ae4d6e2ef4e, "999999999999999700000"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x41b3de4355555554, "333333333.33333325"},
+		{0x41b3de4355555555, "333333333.3333333"},
+		{0x41b3de4355555556, "333333333.3333334"},
+		{0x41b3de4355555557, "333333333.33333343"},
+		{0xbecbf647612f3696, "-0.0000033333333333333333"},
+		{0x43143ff3c1cb0959, "1424953923781206.2"},
+	}
+
+	for _, tt := range tests {
+		number, err := formatNumber(math.Float64frombits(tt.bits))
+		if err != nil || number != tt.expected {
+			t.Errorf("%016x: expected %s, got %s (%v)", tt.bits, tt.expected, number, err)
+		}
+	}
+
+	if _, err := formatNumber(math.NaN()); err == nil {
+		t.Error("Expected an error for NaN")
+	}
+}
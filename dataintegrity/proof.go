@@ -0,0 +1,92 @@
+// Package dataintegrity builds the signing input of the W3C Data Integrity proofs the services
+// create and verify: credentials of issuer-service, presentations of holder-service and Domain
+// Linkage Credentials of did-service. Every cryptosuite used is a JCS cryptosuite, hashing the
+// RFC 8785 canonical form of the proof configuration and of the unsecured document.
+package dataintegrity
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// ProofType is the type of every proof
+const ProofType = "DataIntegrityProof"
+
+// Cryptosuites of the proofs. ecdsa-jcs-2019 is only registered for P-256 and P-384; secp256k1
+// keys use the same construction on their own curve. bls12381-jcs-2024 is specific to these
+// services: a plain BLS signature in G1 over the hash data, without the selective disclosure of
+// the bbs-2023 suite.
+const (
+	EdDSAJCS2022    = "eddsa-jcs-2022"
+	ECDSAJCS2019    = "ecdsa-jcs-2019"
+	BLS12381JCS2024 = "bls12381-jcs-2024"
+)
+
+// keyTypeCryptosuites is the cryptosuite of each key type, named as in the keystore module
+var keyTypeCryptosuites = map[string]string{
+	"Ed25519":      EdDSAJCS2022,
+	"P-256":        ECDSAJCS2019,
+	"secp256k1":    ECDSAJCS2019,
+	"BLS12-381-G2": BLS12381JCS2024,
+}
+
+// Cryptosuite returns the cryptosuite of proofs made with a key type
+func Cryptosuite(keyType string) (string, bool) {
+	cryptosuite, ok := keyTypeCryptosuites[keyType]
+	return cryptosuite, ok
+}
+
+// SupportedCryptosuite reports whether proofs are made with the cryptosuite for any key type
+func SupportedCryptosuite(cryptosuite string) bool {
+	for _, supported := range keyTypeCryptosuites {
+		if supported == cryptosuite {
+			return true
+		}
+	}
+	return false
+}
+
+// ProofConfig is a proof without its proof value. It shares the @context of the secured document.
+type ProofConfig struct {
+	Context            interface{} `json:"@context"`
+	Type               string      `json:"type"`
+	Cryptosuite        string      `json:"cryptosuite"`
+	Created            string      `json:"created"`
+	ProofPurpose       string      `json:"proofPurpose"`
+	VerificationMethod string      `json:"verificationMethod"`
+}
+
+// UnsecuredDocument returns a document as a generic JSON object without its proof
+func UnsecuredDocument(v interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document: %w", err)
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, fmt.Errorf("failed to decode document: %w", err)
+	}
+	delete(document, "proof")
+
+	return document, nil
+}
+
+// HashData builds the signing input of the JCS cryptosuites: the SHA-256 hash of the canonical
+// proof configuration followed by the SHA-256 hash of the canonical unsecured document
+func HashData(config ProofConfig, document interface{}) ([]byte, error) {
+	canonicalConfig, err := Canonicalize(config)
+	if err != nil {
+		return nil, err
+	}
+	canonicalDocument, err := Canonicalize(document)
+	if err != nil {
+		return nil, err
+	}
+
+	configHash := sha256.Sum256(canonicalConfig)
+	documentHash := sha256.Sum256(canonicalDocument)
+
+	return append(configHash[:], documentHash[:]...), nil
+}
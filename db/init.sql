@@ -8,7 +8,37 @@ CREATE TABLE dids (
     organization_id TEXT NOT NULL,
     created_at TIMESTAMPTZ NOT NULL,
     public_key JSONB, -- Store the public keys as a JSON array
-    document JSONB     -- Store the DID document as JSON
+    document JSONB,    -- Store the DID document as JSON
+    legacy_did TEXT,   -- Pre-migration identifier of DIDs minted with the old did:key encoding
+    version_id INTEGER NOT NULL DEFAULT 1, -- Current version of the DID document
+    updated_at TIMESTAMPTZ,                -- When the DID document was last updated
+    deactivated BOOLEAN NOT NULL DEFAULT FALSE, -- Whether the DID has been deactivated
+    deactivated_at TIMESTAMPTZ                  -- When the DID was deactivated
+);
+
+-- Index the filters of the paginated DID listing
+CREATE INDEX IF NOT EXISTS idx_dids_organization_id ON dids (organization_id, id);
+CREATE INDEX IF NOT EXISTS idx_dids_holder_id ON dids ((document->>'holder_id'));
+CREATE INDEX IF NOT EXISTS idx_dids_created_at ON dids (created_at);
+
+-- Create DID document history table, one row per version of each DID document
+CREATE TABLE IF NOT EXISTS did_document_versions (
+    did TEXT NOT NULL,
+    version_id INTEGER NOT NULL,
+    document JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (did, version_id)
+);
+
+-- Create Domain Linkage Credential table, one credential per origin and DID served in the
+-- origin's /.well-known/did-configuration.json
+CREATE TABLE IF NOT EXISTS domain_linkage_credentials (
+    origin TEXT NOT NULL,
+    did TEXT NOT NULL,
+    credential JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (origin, did)
 );
 
 -- Create DID document storage table
@@ -19,6 +49,19 @@ CREATE TABLE IF NOT EXISTS did_documents (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP -- Timestamp of document creation
 );
 
+-- Create a table for bulk issuance jobs
+CREATE TABLE IF NOT EXISTS issuance_jobs (
+    id UUID PRIMARY KEY,                             -- Job ID returned to the client
+    issuer_did VARCHAR(255) NOT NULL,                -- DID of the issuer
+    state VARCHAR(32) NOT NULL,                      -- queued, running, completed, partially_failed or failed
+    subject_count INTEGER NOT NULL,                  -- Number of subjects in the request
+    issued_count INTEGER NOT NULL DEFAULT 0,         -- Credentials issued
+    failed_count INTEGER NOT NULL DEFAULT 0,         -- Subjects that could not be issued
+    error TEXT,                                      -- Why the job failed as a whole
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
 -- Create verifiable credentials table with subject properties and revocation functionality
 CREATE TABLE IF NOT EXISTS verifiable_credentials (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),  -- Unique identifier for the credential
@@ -27,14 +70,69 @@ CREATE TABLE IF NOT EXISTS verifiable_credentials (
     credential JSONB NOT NULL,                       -- The verifiable credential (JSON format)
     subject JSONB NOT NULL,                          -- Dynamic subject properties as JSON
     issuance_date TIMESTAMP NOT NULL,                -- When the credential was issued
-    expiration_date TIMESTAMP NOT NULL,              -- Expiration date of the credential
+    expiration_date TIMESTAMP,                       -- Expiration date of the credential, none if it never expires
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,  -- Timestamp of credential issuance
     revoked BOOLEAN DEFAULT FALSE,                    -- Whether the credential is revoked
     revocation_reason TEXT,                           -- Reason for revocation (optional)
     revoked_at TIMESTAMP,                             -- Timestamp of when the credential was revoked (optional)
-    proof JSONB                                       -- Proof of the credential
+    proof JSONB,                                      -- Proof of the credential
+    job_id UUID REFERENCES issuance_jobs(id)          -- Issuance job that issued the credential
 );
 
+CREATE INDEX IF NOT EXISTS idx_verifiable_credentials_job_id ON verifiable_credentials (job_id);
+
+CREATE INDEX IF NOT EXISTS idx_issuance_jobs_issuer_did ON issuance_jobs (issuer_did, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_issuance_jobs_created_at ON issuance_jobs (created_at DESC, id DESC);
+
+-- Create a table for the outcome of each subject of an issuance job
+CREATE TABLE IF NOT EXISTS issuance_job_subjects (
+    job_id UUID NOT NULL REFERENCES issuance_jobs(id), -- Issuance job
+    position INTEGER NOT NULL,                         -- Index of the subject in the request
+    subject_id VARCHAR(255) NOT NULL,                  -- DID of the subject
+    external_reference TEXT,                           -- Caller's reference for the subject (optional)
+    state VARCHAR(32) NOT NULL,                        -- pending, issued or failed
+    credential_id UUID REFERENCES verifiable_credentials(id), -- Credential issued for the subject
+    reused BOOLEAN NOT NULL DEFAULT FALSE,             -- Credential was issued earlier for the external reference
+    error TEXT,                                        -- Why the credential could not be issued
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (job_id, position)
+);
+
+-- Create a table for the Idempotency-Keys of issuance requests
+CREATE TABLE IF NOT EXISTS issuance_idempotency_keys (
+    issuer_did VARCHAR(255) NOT NULL,                -- DID of the issuer
+    idempotency_key VARCHAR(255) NOT NULL,           -- Idempotency-Key header of the request
+    request_hash TEXT NOT NULL,                      -- SHA-256 of the canonical request
+    job_id UUID NOT NULL,                            -- Job created for the request
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,                 -- End of the retention window
+    PRIMARY KEY (issuer_did, idempotency_key)
+);
+
+-- Create a table for the credentials issued for external references of subjects
+CREATE TABLE IF NOT EXISTS issuance_external_references (
+    issuer_did VARCHAR(255) NOT NULL,                -- DID of the issuer
+    external_reference TEXT NOT NULL,                -- Caller's reference for the subject
+    credential_id UUID NOT NULL,                     -- Credential issued for the reference
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,                 -- End of the retention window
+    PRIMARY KEY (issuer_did, external_reference)
+);
+
+-- Create a table queueing issuance jobs when issuer-service runs with ISSUANCE_QUEUE=postgres
+CREATE TABLE IF NOT EXISTS issuance_queue (
+    job_id UUID PRIMARY KEY,                         -- Issuance job
+    job JSONB NOT NULL,                              -- The job as delivered to the worker
+    retries INTEGER NOT NULL DEFAULT 0,              -- How often the job was retried
+    available_at TIMESTAMPTZ NOT NULL,               -- When the job may be delivered (next)
+    dead_lettered_at TIMESTAMPTZ,                    -- When the job exhausted its retries
+    dead_letter_reason TEXT,                         -- Why the job was dead-lettered
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_issuance_queue_available_at ON issuance_queue (available_at) WHERE dead_lettered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_issuance_queue_dead_lettered_at ON issuance_queue (dead_lettered_at) WHERE dead_lettered_at IS NOT NULL;
+
 
 -- Create revocation table (optional, for more detailed tracking)
 CREATE TABLE IF NOT EXISTS revocation_registry (
@@ -59,6 +157,9 @@ CREATE TABLE schemas (
     organization_did VARCHAR(255) NOT NULL,         -- Organization DID
     schema_name VARCHAR(255) NOT NULL,              -- Human-readable name for the schema
     schema_json JSONB NOT NULL,                     -- JSON representation of the schema's properties
+    credential_types JSONB NOT NULL DEFAULT '[]'::jsonb, -- Types of the credentials issued with the schema
+    contexts JSONB NOT NULL DEFAULT '[]'::jsonb,    -- JSON-LD contexts of the credentials issued with the schema
+    default_lifetime TEXT,                          -- ISO 8601 duration the credentials are valid for by default
     created_at TIMESTAMP DEFAULT NOW(),             -- Timestamp for when the schema was created
     updated_at TIMESTAMP DEFAULT NOW()              -- Timestamp for last update
 );
@@ -66,3 +167,12 @@ CREATE TABLE schemas (
 -- Create an index on organization_did for faster lookups
 CREATE INDEX idx_organization_did ON schemas (organization_did);
 
+-- Issuance policies of issuers
+CREATE TABLE IF NOT EXISTS issuer_policies (
+    issuer_did VARCHAR(255) PRIMARY KEY,
+    max_lifetime TEXT,                               -- ISO 8601 duration credentials may be valid for at most
+    data_model TEXT,                                 -- Data model version of credentials by default, "1.1" or "2.0"
+    credential_status JSONB,                         -- credentialStatus entries of credentials by default
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
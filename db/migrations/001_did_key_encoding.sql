@@ -0,0 +1,5 @@
+-- Track DIDs re-encoded from the old "did:key:z6M" + base64url format.
+-- Run against existing databases before starting did-service with MIGRATE_LEGACY_DIDS=true.
+ALTER TABLE dids ADD COLUMN IF NOT EXISTS legacy_did TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_dids_legacy_did ON dids (legacy_did);
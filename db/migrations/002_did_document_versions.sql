@@ -0,0 +1,16 @@
+-- Version DID documents so keys can be added, rotated and retired.
+-- Existing documents become version 1 of their DID.
+ALTER TABLE dids ADD COLUMN IF NOT EXISTS version_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE dids ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS did_document_versions (
+    did TEXT NOT NULL,
+    version_id INTEGER NOT NULL,
+    document JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (did, version_id)
+);
+
+INSERT INTO did_document_versions (did, version_id, document, created_at)
+SELECT did, version_id, document, created_at FROM dids
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+-- Track deactivated DIDs. deactivated_at bounds which credentials of a deactivated issuer stay valid.
+ALTER TABLE dids ADD COLUMN IF NOT EXISTS deactivated BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE dids ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ;
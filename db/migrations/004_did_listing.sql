@@ -0,0 +1,4 @@
+-- Index the filters of the paginated DID listing (GET /v1/dids).
+CREATE INDEX IF NOT EXISTS idx_dids_organization_id ON dids (organization_id, id);
+CREATE INDEX IF NOT EXISTS idx_dids_holder_id ON dids ((document->>'holder_id'));
+CREATE INDEX IF NOT EXISTS idx_dids_created_at ON dids (created_at);
@@ -0,0 +1,9 @@
+-- Store the Domain Linkage Credentials served in /.well-known/did-configuration.json.
+CREATE TABLE IF NOT EXISTS domain_linkage_credentials (
+    origin TEXT NOT NULL,
+    did TEXT NOT NULL,
+    credential JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (origin, did)
+);
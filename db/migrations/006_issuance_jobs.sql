@@ -0,0 +1,16 @@
+-- Track the bulk issuance jobs the issuer-service worker processes, and which credentials each
+-- job issued.
+CREATE TABLE IF NOT EXISTS issuance_jobs (
+    id UUID PRIMARY KEY,
+    issuer_did VARCHAR(255) NOT NULL,
+    state VARCHAR(32) NOT NULL,
+    subject_count INTEGER NOT NULL,
+    issued_count INTEGER NOT NULL DEFAULT 0,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+ALTER TABLE verifiable_credentials ADD COLUMN IF NOT EXISTS job_id UUID REFERENCES issuance_jobs(id);
+CREATE INDEX IF NOT EXISTS idx_verifiable_credentials_job_id ON verifiable_credentials (job_id);
@@ -0,0 +1,14 @@
+-- Record the outcome of each subject of an issuance job, and list jobs by issuer.
+CREATE TABLE IF NOT EXISTS issuance_job_subjects (
+    job_id UUID NOT NULL REFERENCES issuance_jobs(id),
+    position INTEGER NOT NULL,
+    subject_id VARCHAR(255) NOT NULL,
+    state VARCHAR(32) NOT NULL,
+    credential_id UUID REFERENCES verifiable_credentials(id),
+    error TEXT,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (job_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_issuance_jobs_issuer_did ON issuance_jobs (issuer_did, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_issuance_jobs_created_at ON issuance_jobs (created_at DESC, id DESC);
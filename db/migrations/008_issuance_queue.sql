@@ -0,0 +1,14 @@
+-- Queue issuance jobs in Postgres for issuer-service deployments without RabbitMQ
+-- (ISSUANCE_QUEUE=postgres).
+CREATE TABLE IF NOT EXISTS issuance_queue (
+    job_id UUID PRIMARY KEY,
+    job JSONB NOT NULL,
+    retries INTEGER NOT NULL DEFAULT 0,
+    available_at TIMESTAMPTZ NOT NULL,
+    dead_lettered_at TIMESTAMPTZ,
+    dead_letter_reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_issuance_queue_available_at ON issuance_queue (available_at) WHERE dead_lettered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_issuance_queue_dead_lettered_at ON issuance_queue (dead_lettered_at) WHERE dead_lettered_at IS NOT NULL;
@@ -0,0 +1,23 @@
+-- Remember the Idempotency-Keys of issuance requests and the external references of their
+-- subjects, so retried requests do not issue credentials again.
+CREATE TABLE IF NOT EXISTS issuance_idempotency_keys (
+    issuer_did VARCHAR(255) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash TEXT NOT NULL,
+    job_id UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (issuer_did, idempotency_key)
+);
+
+CREATE TABLE IF NOT EXISTS issuance_external_references (
+    issuer_did VARCHAR(255) NOT NULL,
+    external_reference TEXT NOT NULL,
+    credential_id UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (issuer_did, external_reference)
+);
+
+ALTER TABLE issuance_job_subjects ADD COLUMN IF NOT EXISTS external_reference TEXT;
+ALTER TABLE issuance_job_subjects ADD COLUMN IF NOT EXISTS reused BOOLEAN NOT NULL DEFAULT FALSE;
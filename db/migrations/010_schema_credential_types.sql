@@ -0,0 +1,3 @@
+-- Let schemas declare the types and JSON-LD contexts of the credentials issued with them.
+ALTER TABLE schemas ADD COLUMN IF NOT EXISTS credential_types JSONB NOT NULL DEFAULT '[]'::jsonb;
+ALTER TABLE schemas ADD COLUMN IF NOT EXISTS contexts JSONB NOT NULL DEFAULT '[]'::jsonb;
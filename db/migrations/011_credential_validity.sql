@@ -0,0 +1,11 @@
+-- Let credentials never expire, let schemas set the default lifetime of their credentials and
+-- let issuers cap the lifetime of the credentials they issue.
+ALTER TABLE verifiable_credentials ALTER COLUMN expiration_date DROP NOT NULL;
+
+ALTER TABLE schemas ADD COLUMN IF NOT EXISTS default_lifetime TEXT;
+
+CREATE TABLE IF NOT EXISTS issuer_policies (
+    issuer_did VARCHAR(255) PRIMARY KEY,
+    max_lifetime TEXT,                               -- ISO 8601 duration, e.g. P1Y
+    updated_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,3 @@
+-- Let issuers choose the version of the W3C Verifiable Credentials Data Model their credentials
+-- are issued in by default ("1.1" or "2.0").
+ALTER TABLE issuer_policies ADD COLUMN IF NOT EXISTS data_model TEXT;
@@ -0,0 +1,2 @@
+-- Let issuers give their credentials credentialStatus entries by default.
+ALTER TABLE issuer_policies ADD COLUMN IF NOT EXISTS credential_status JSONB;
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// DeactivationResponse is the answer to a DID deactivation
+type DeactivationResponse struct {
+	DID           string    `json:"did"`
+	Deactivated   bool      `json:"deactivated"`
+	DeactivatedAt time.Time `json:"deactivatedAt"`
+}
+
+// deactivateDID marks a DID of the caller's organization as deactivated and destroys every
+// version of its private keys in the key store. Deactivation is permanent; repeating the request
+// retries key destruction, so a deactivation whose key store step failed can be completed.
+func deactivateDID(w http.ResponseWriter, r *http.Request) {
+	did, err := didFromPath(r)
+	if err != nil {
+		http.Error(w, "Invalid DID", http.StatusBadRequest)
+		return
+	}
+	caller := r.Header.Get(organizationHeader)
+	if caller == "" {
+		http.Error(w, errMissingOrganization.Error(), http.StatusUnauthorized)
+		return
+	}
+	ctx := context.Background()
+
+	// Keep the first deactivation time, it bounds which credentials stay valid
+	var deactivatedAt time.Time
+	query := `UPDATE dids
+		SET deactivated = TRUE,
+			deactivated_at = COALESCE(deactivated_at, $2),
+			updated_at = COALESCE(deactivated_at, $2)
+		WHERE did = $1 AND organization_id = $3
+		RETURNING deactivated_at`
+	err = db.QueryRow(ctx, query, did, time.Now().UTC(), caller).Scan(&deactivatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "DID not found", http.StatusNotFound)
+		} else {
+			log.Printf("Failed to deactivate DID: %v", err)
+			http.Error(w, "Failed to deactivate DID", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := keyStore.Destroy(ctx, did); err != nil {
+		log.Printf("Error destroying private keys of deactivated DID %s: %v", did, err)
+		http.Error(w, "DID deactivated but its keys could not be destroyed, retry the request", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DeactivationResponse{
+		DID:           did,
+		Deactivated:   true,
+		DeactivatedAt: deactivatedAt.UTC().Truncate(time.Second),
+	})
+	log.Printf("DID deactivated: %s", did)
+}
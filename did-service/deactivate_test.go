@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bradtumy/credential-service/keystore"
+	"github.com/gorilla/mux"
+)
+
+func TestDeactivateDID(t *testing.T) {
+	if dbClosed {
+		t.Fatal("Database connection pool is closed")
+	}
+	keyStore = keystore.NewMemory()
+
+	r := httptest.NewRequest("POST", "/v1/dids", strings.NewReader(`{"type": "organization"}`))
+	r.Header.Set(organizationHeader, "org123")
+	rr := httptest.NewRecorder()
+	createDID(rr, r)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", rr.Code, rr.Body)
+	}
+	var created DIDDocument
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to decode DID document: %v", err)
+	}
+
+	// Only the DID's own organization can deactivate it
+	for _, organizationID := range []string{"org456", "org123"} {
+		r = mux.SetURLVars(httptest.NewRequest("POST", "/v1/dids/"+created.ID+"/deactivate", nil), map[string]string{"did": created.ID})
+		r.Header.Set(organizationHeader, organizationID)
+		rr = httptest.NewRecorder()
+		deactivateDID(rr, r)
+		if organizationID == "org456" {
+			if rr.Code != http.StatusNotFound {
+				t.Errorf("Expected status %d deactivating as %s, got %d", http.StatusNotFound, organizationID, rr.Code)
+			}
+			continue
+		}
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status OK, got %d: %s", rr.Code, rr.Body)
+		}
+	}
+
+	var response DeactivationResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode deactivation response: %v", err)
+	}
+	if response.DID != created.ID || !response.Deactivated || response.DeactivatedAt.IsZero() {
+		t.Errorf("Unexpected deactivation response: %s", rr.Body)
+	}
+}
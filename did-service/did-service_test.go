@@ -1,16 +1,27 @@
 package main
 
 import (
+	"context"
 	"os"
 	"testing"
+
+	"github.com/bradtumy/credential-service/keystore"
 )
 
-func TestSavePrivateKeyToVault(t *testing.T) {
+func TestSaveKeyToVault(t *testing.T) {
 	//mockClient := &mockVaultClient{}
 	os.Setenv("VAULT_ADDR", "http://127.0.0.1:8200")
 	os.Setenv("VAULT_TOKEN", "root")
 
-	err := savePrivateKeyToVault("did:key:z6M", "mockPrivateKey")
+	store, err := keystore.NewVault(keystore.VaultConfig{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	ctx := context.Background()
+	key, err := store.Create(ctx, keyTypeEd25519)
+	if err == nil {
+		err = store.Assign(ctx, key, "did:key:z6M#key-1")
+	}
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
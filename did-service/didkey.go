@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mr-tron/base58"
+)
+
+const (
+	didKeyPrefix = "did:key:"
+
+	// multibaseBase58BTC is the multibase prefix for base58btc encoded values
+	multibaseBase58BTC = "z"
+
+	// legacyDIDKeyPrefix is the prefix of DIDs minted before did:key encoding was fixed:
+	// "did:key:z6M" followed by the base64url encoded public key
+	legacyDIDKeyPrefix = "did:key:z6M"
+)
+
+// ed25519PublicKeyMulticodec is the multicodec prefix (0xed, varint encoded) of an Ed25519 public key
+var ed25519PublicKeyMulticodec = []byte{0xed, 0x01}
+
+// encodeMultibaseEd25519 returns the base58btc multibase encoding of a multicodec prefixed Ed25519 key
+func encodeMultibaseEd25519(publicKey ed25519.PublicKey) string {
+	return encodeMultibaseKey(keyTypeEd25519, publicKey)
+}
+
+// decodeMultibaseEd25519 parses a base58btc multibase, multicodec prefixed Ed25519 public key
+func decodeMultibaseEd25519(multibaseKey string) (ed25519.PublicKey, error) {
+	if !strings.HasPrefix(multibaseKey, multibaseBase58BTC) {
+		return nil, errors.New("public key is not base58btc multibase encoded")
+	}
+
+	decoded, err := base58.Decode(strings.TrimPrefix(multibaseKey, multibaseBase58BTC))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base58btc public key: %w", err)
+	}
+	if !bytes.HasPrefix(decoded, ed25519PublicKeyMulticodec) {
+		return nil, errors.New("public key is not an Ed25519 multicodec key")
+	}
+
+	publicKey := decoded[len(ed25519PublicKeyMulticodec):]
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 public key length: %d", len(publicKey))
+	}
+
+	return ed25519.PublicKey(publicKey), nil
+}
+
+// didKeyFromPublicKey derives the did:key identifier of an Ed25519 public key
+func didKeyFromPublicKey(publicKey ed25519.PublicKey) string {
+	return didKeyPrefix + encodeMultibaseEd25519(publicKey)
+}
+
+// publicKeyFromDIDKey extracts the Ed25519 public key encoded in a did:key identifier
+func publicKeyFromDIDKey(did string) (ed25519.PublicKey, error) {
+	if !strings.HasPrefix(did, didKeyPrefix) {
+		return nil, fmt.Errorf("not a did:key identifier: %s", did)
+	}
+	return decodeMultibaseEd25519(strings.TrimPrefix(did, didKeyPrefix))
+}
+
+// legacyPublicKeyFromDIDKey extracts the public key of a DID minted with the old
+// "did:key:z6M" + base64url(publicKey) encoding. It reports false for any other DID.
+func legacyPublicKeyFromDIDKey(did string) (ed25519.PublicKey, bool) {
+	if !strings.HasPrefix(did, legacyDIDKeyPrefix) {
+		return nil, false
+	}
+	// Correctly encoded DIDs can share the prefix, so only treat undecodable ones as legacy
+	if _, err := publicKeyFromDIDKey(did); err == nil {
+		return nil, false
+	}
+
+	publicKey, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(did, legacyDIDKeyPrefix))
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, false
+	}
+
+	return ed25519.PublicKey(publicKey), true
+}
+
+// newDIDKeyDocument builds the DID Core document of a did:key identifier from a multibase
+// public key of any supported type. The single verification method is referenced for
+// authentication and assertions, as the did:key method specifies.
+func newDIDKeyDocument(multibaseKey string) DIDDocument {
+	did := didKeyPrefix + multibaseKey
+	keyID := fmt.Sprintf("%s#%s", did, multibaseKey)
+
+	return DIDDocument{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID: did,
+		VerificationMethod: []VerificationMethod{
+			{
+				ID:                 keyID,
+				Type:               "Multikey",
+				Controller:         did,
+				PublicKeyMultibase: multibaseKey,
+			},
+		},
+		Authentication:  []string{keyID},
+		AssertionMethod: []string{keyID},
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDIDKeyRoundTrip(t *testing.T) {
+	// Test vector from the did:key method specification
+	did := "did:key:z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp"
+
+	publicKey, err := publicKeyFromDIDKey(did)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := didKeyFromPublicKey(publicKey); got != did {
+		t.Errorf("Expected %s, got %s", did, got)
+	}
+}
+
+func TestNewDIDKeyDocument(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	didDocument := newDIDKeyDocument(encodeMultibaseEd25519(publicKey))
+	if !strings.HasPrefix(didDocument.ID, "did:key:z6Mk") {
+		t.Errorf("Expected an Ed25519 did:key identifier, got %s", didDocument.ID)
+	}
+	if len(didDocument.VerificationMethod) != 1 {
+		t.Fatalf("Expected one verification method, got %d", len(didDocument.VerificationMethod))
+	}
+
+	method := didDocument.VerificationMethod[0]
+	multibaseKey := strings.TrimPrefix(didDocument.ID, "did:key:")
+	if method.ID != didDocument.ID+"#"+multibaseKey || method.PublicKeyMultibase != multibaseKey {
+		t.Errorf("Unexpected verification method %+v", method)
+	}
+	if didDocument.AssertionMethod[0] != method.ID || didDocument.Authentication[0] != method.ID {
+		t.Error("Expected the key to be referenced for authentication and assertions")
+	}
+
+	decoded, err := decodeMultibaseEd25519(method.PublicKeyMultibase)
+	if err != nil || !decoded.Equal(publicKey) {
+		t.Errorf("Expected publicKeyMultibase to decode to the generated key, got %v", err)
+	}
+}
+
+func TestLegacyPublicKeyFromDIDKey(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	legacyDID := "did:key:z6M" + base64.RawURLEncoding.EncodeToString(publicKey)
+	recovered, ok := legacyPublicKeyFromDIDKey(legacyDID)
+	if !ok || !recovered.Equal(publicKey) {
+		t.Fatalf("Expected the legacy DID's public key to be recovered")
+	}
+
+	if _, ok := legacyPublicKeyFromDIDKey(didKeyFromPublicKey(publicKey)); ok {
+		t.Error("Expected a spec-compliant did:key not to be treated as legacy")
+	}
+}
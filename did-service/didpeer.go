@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	didPeerPrefix = "did:peer:"
+
+	// did:peer purpose codes of numalgo 2 elements
+	didPeerPurposeAssertion      = 'A'
+	didPeerPurposeAuthentication = 'V'
+	didPeerPurposeService        = 'S'
+)
+
+// didPeerServiceAbbreviations abbreviates the service types that have a short form in numalgo 2
+var didPeerServiceAbbreviations = map[string]string{
+	"DIDCommMessaging": "dm",
+}
+
+// didPeerService is the abbreviated JSON form of a service in a numalgo 2 identifier
+type didPeerService struct {
+	Type            string   `json:"t"`
+	ServiceEndpoint string   `json:"s"`
+	RoutingKeys     []string `json:"r,omitempty"`
+	Accept          []string `json:"a,omitempty"`
+}
+
+// encodeDIDPeerService encodes a service as a numalgo 2 element value: the abbreviated service
+// JSON, base64url encoded without padding
+func encodeDIDPeerService(service Service) (string, error) {
+	if service.Type == "" || service.ServiceEndpoint == "" {
+		return "", errors.New("service type and serviceEndpoint are required")
+	}
+
+	abbreviated := didPeerService{
+		Type:            service.Type,
+		ServiceEndpoint: service.ServiceEndpoint,
+		RoutingKeys:     service.RoutingKeys,
+		Accept:          service.Accept,
+	}
+	if abbreviation, ok := didPeerServiceAbbreviations[service.Type]; ok {
+		abbreviated.Type = abbreviation
+	}
+
+	serviceJSON, err := json.Marshal(abbreviated)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal service: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(serviceJSON), nil
+}
+
+// didPeerServiceID returns the id of the n-th (zero based) service of a numalgo 2 identifier
+func didPeerServiceID(did string, n int) string {
+	if n == 0 {
+		return did + "#service"
+	}
+	return fmt.Sprintf("%s#service-%d", did, n)
+}
+
+// newDIDPeer0Document builds the document of a did:peer:0 identifier, which wraps a single
+// inception key exactly like did:key does
+func newDIDPeer0Document(multibaseKey string) DIDDocument {
+	did := didPeerPrefix + "0" + multibaseKey
+	keyID := fmt.Sprintf("%s#%s", did, multibaseKey)
+
+	return DIDDocument{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID: did,
+		VerificationMethod: []VerificationMethod{
+			{
+				ID:                 keyID,
+				Type:               "Multikey",
+				Controller:         did,
+				PublicKeyMultibase: multibaseKey,
+			},
+		},
+		Authentication:  []string{keyID},
+		AssertionMethod: []string{keyID},
+	}
+}
+
+// newDIDPeer2Document builds a did:peer:2 identifier and its document. The key is encoded once
+// for authentication and once for assertions, and each service is appended as its own element,
+// so the whole document can be recovered from the identifier.
+func newDIDPeer2Document(multibaseKey string, services []Service) (DIDDocument, error) {
+	did := didPeerPrefix + "2"
+	did += "." + string(didPeerPurposeAuthentication) + multibaseKey
+	did += "." + string(didPeerPurposeAssertion) + multibaseKey
+	for _, service := range services {
+		encoded, err := encodeDIDPeerService(service)
+		if err != nil {
+			return DIDDocument{}, err
+		}
+		did += "." + string(didPeerPurposeService) + encoded
+	}
+
+	// Keys are numbered in the order they appear in the identifier
+	authenticationID := did + "#key-1"
+	assertionID := did + "#key-2"
+
+	didDocument := DIDDocument{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID: did,
+		VerificationMethod: []VerificationMethod{
+			{ID: authenticationID, Type: "Multikey", Controller: did, PublicKeyMultibase: multibaseKey},
+			{ID: assertionID, Type: "Multikey", Controller: did, PublicKeyMultibase: multibaseKey},
+		},
+		Authentication:  []string{authenticationID},
+		AssertionMethod: []string{assertionID},
+	}
+	for i, service := range services {
+		service.ID = didPeerServiceID(did, i)
+		didDocument.Service = append(didDocument.Service, service)
+	}
+
+	return didDocument, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestNewDIDPeer0Document(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	didDocument := newDIDPeer0Document(encodeMultibaseEd25519(publicKey))
+	multibaseKey := encodeMultibaseEd25519(publicKey)
+	if didDocument.ID != "did:peer:0"+multibaseKey {
+		t.Errorf("Expected did:peer:0%s, got %s", multibaseKey, didDocument.ID)
+	}
+	if didDocument.VerificationMethod[0].PublicKeyMultibase != multibaseKey {
+		t.Errorf("Unexpected verification method %+v", didDocument.VerificationMethod[0])
+	}
+}
+
+func TestNewDIDPeer2Document(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	services := []Service{
+		{Type: "DIDCommMessaging", ServiceEndpoint: "https://holder.example.com/didcomm", Accept: []string{"didcomm/v2"}},
+		{Type: "LinkedDomains", ServiceEndpoint: "https://holder.example.com"},
+	}
+	didDocument, err := newDIDPeer2Document(encodeMultibaseEd25519(publicKey), services)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	multibaseKey := encodeMultibaseEd25519(publicKey)
+	elements := strings.Split(didDocument.ID, ".")
+	if len(elements) != 5 || elements[0] != "did:peer:2" || elements[1] != "V"+multibaseKey || elements[2] != "A"+multibaseKey {
+		t.Fatalf("Unexpected did:peer:2 identifier %s", didDocument.ID)
+	}
+
+	serviceJSON, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(elements[3], "S"))
+	if err != nil {
+		t.Fatalf("Expected a base64url encoded service, got %v", err)
+	}
+	if expected := `{"t":"dm","s":"https://holder.example.com/didcomm","a":["didcomm/v2"]}`; string(serviceJSON) != expected {
+		t.Errorf("Expected service %s, got %s", expected, serviceJSON)
+	}
+
+	if didDocument.Authentication[0] != didDocument.ID+"#key-1" || didDocument.AssertionMethod[0] != didDocument.ID+"#key-2" {
+		t.Errorf("Unexpected verification relationships %v %v", didDocument.Authentication, didDocument.AssertionMethod)
+	}
+	if len(didDocument.Service) != 2 || didDocument.Service[0].ID != didDocument.ID+"#service" || didDocument.Service[1].ID != didDocument.ID+"#service-1" {
+		t.Errorf("Unexpected services %+v", didDocument.Service)
+	}
+
+	if _, err := newDIDPeer2Document(encodeMultibaseEd25519(publicKey), []Service{{Type: "DIDCommMessaging"}}); err == nil {
+		t.Error("Expected a service without endpoint to be rejected")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const didWebPrefix = "did:web:"
+
+var (
+	// didWebDomainPattern matches a host name with an optional port
+	didWebDomainPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*(:[0-9]{1,5})?$`)
+	// didWebPathSegmentPattern matches a single path segment of a did:web identifier
+	didWebPathSegmentPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+)
+
+// didWebFromDomain builds a did:web identifier from a domain and an optional "/"-separated
+// path. A port in the domain is percent-encoded and path segments are joined with ":", as the
+// did:web method specifies.
+func didWebFromDomain(domain, path string) (string, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if !didWebDomainPattern.MatchString(domain) {
+		return "", fmt.Errorf("invalid domain: %q", domain)
+	}
+
+	did := didWebPrefix + strings.Replace(domain, ":", "%3A", 1)
+
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return did, nil
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if !didWebPathSegmentPattern.MatchString(segment) || segment == "." || segment == ".." || segment == ".well-known" {
+			return "", fmt.Errorf("invalid path segment: %q", segment)
+		}
+		did += ":" + segment
+	}
+
+	return did, nil
+}
+
+// didWebFromRequest derives the did:web identifier a did.json request is asking for from the
+// request's host and path
+func didWebFromRequest(r *http.Request) (string, error) {
+	path := strings.TrimSuffix(r.URL.Path, "/did.json")
+	if path == "/.well-known" {
+		path = ""
+	}
+	return didWebFromDomain(r.Host, path)
+}
+
+// newDIDWebDocument builds the DID Core document of a did:web identifier with a single
+// verification method used for authentication and assertions
+func newDIDWebDocument(did, multibaseKey string) DIDDocument {
+	keyID := fmt.Sprintf("%s#keys-1", did)
+
+	return DIDDocument{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID: did,
+		VerificationMethod: []VerificationMethod{
+			{
+				ID:                 keyID,
+				Type:               "Multikey",
+				Controller:         did,
+				PublicKeyMultibase: multibaseKey,
+			},
+		},
+		Authentication:  []string{keyID},
+		AssertionMethod: []string{keyID},
+	}
+}
+
+// serveDIDWebDocument serves the stored DID document of a did:web identifier at
+// /.well-known/did.json or /<path>/did.json on the DID's domain
+func serveDIDWebDocument(w http.ResponseWriter, r *http.Request) {
+	did, err := didWebFromRequest(r)
+	if err != nil {
+		http.Error(w, "DID not found", http.StatusNotFound)
+		return
+	}
+
+	var document string
+	var deactivated bool
+	err = db.QueryRow(context.Background(), "SELECT document, deactivated FROM dids WHERE did = $1", did).Scan(&document, &deactivated)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "DID not found", http.StatusNotFound)
+		} else {
+			log.Printf("Failed to execute query: %v", err)
+			http.Error(w, "Failed to retrieve DID", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// A deactivated did:web is no longer published; resolvers learn about it from the registry
+	if deactivated {
+		http.Error(w, "DID deactivated", http.StatusGone)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/did+ld+json")
+	w.Write([]byte(document))
+	log.Printf("Served did:web document: %s", did)
+}
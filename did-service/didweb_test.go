@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDIDWebFromDomain(t *testing.T) {
+	tests := []struct {
+		domain, path, expected string
+	}{
+		{domain: "example.com", expected: "did:web:example.com"},
+		{domain: "Example.COM", path: "/", expected: "did:web:example.com"},
+		{domain: "example.com:3000", expected: "did:web:example.com%3A3000"},
+		{domain: "example.com", path: "issuers/hr", expected: "did:web:example.com:issuers:hr"},
+	}
+
+	for _, tt := range tests {
+		did, err := didWebFromDomain(tt.domain, tt.path)
+		if err != nil {
+			t.Errorf("%s %s: expected no error, got %v", tt.domain, tt.path, err)
+			continue
+		}
+		if did != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, did)
+		}
+	}
+
+	for _, invalid := range []struct{ domain, path string }{
+		{domain: ""},
+		{domain: "https://example.com"},
+		{domain: "example.com", path: "issuers/../admin"},
+		{domain: "example.com", path: ".well-known"},
+	} {
+		if did, err := didWebFromDomain(invalid.domain, invalid.path); err == nil {
+			t.Errorf("Expected an error for %q %q, got %s", invalid.domain, invalid.path, did)
+		}
+	}
+}
+
+func TestDIDWebFromRequest(t *testing.T) {
+	tests := []struct {
+		url, expected string
+	}{
+		{url: "https://example.com/.well-known/did.json", expected: "did:web:example.com"},
+		{url: "https://example.com:3000/.well-known/did.json", expected: "did:web:example.com%3A3000"},
+		{url: "https://example.com/issuers/hr/did.json", expected: "did:web:example.com:issuers:hr"},
+	}
+
+	for _, tt := range tests {
+		did, err := didWebFromRequest(httptest.NewRequest("GET", tt.url, nil))
+		if err != nil || did != tt.expected {
+			t.Errorf("%s: expected %s, got %s (%v)", tt.url, tt.expected, did, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	errDIDNotFound       = errors.New("DID not found")
+	errDIDDeactivated    = errors.New("DID is deactivated")
+	errServiceInvalid    = errors.New("invalid service")
+	errControllerInvalid = errors.New("invalid controller")
+)
+
+// DocumentUpdateRequest is the payload of PATCH /v1/dids/{did}. Omitted fields are left
+// unchanged; an empty list removes every entry.
+type DocumentUpdateRequest struct {
+	Controller *[]string  `json:"controller,omitempty"` // DIDs allowed to make changes to the document
+	Services   *[]Service `json:"services,omitempty"`   // Replaces the document's services
+}
+
+// normalizeServices checks the services of a stored DID document and gives them absolute ids:
+// "#fragment" and "fragment" are resolved against the DID, and services without an id are
+// numbered "#service-1", "#service-2" and so on. Ids must not clash with each other or with a
+// verification method, and "#keys-N" is left to keys added later.
+func normalizeServices(didDocument DIDDocument, services []Service) ([]Service, error) {
+	did := didDocument.ID
+	taken := map[string]bool{}
+	for _, method := range didDocument.VerificationMethod {
+		taken[method.ID] = true
+	}
+
+	normalized := make([]Service, 0, len(services))
+	for i, service := range services {
+		if service.Type == "" || service.ServiceEndpoint == "" {
+			return nil, fmt.Errorf("%w: type and serviceEndpoint are required", errServiceInvalid)
+		}
+		if endpoint, err := url.Parse(service.ServiceEndpoint); err != nil || endpoint.Scheme == "" {
+			return nil, fmt.Errorf("%w: serviceEndpoint %q is not an absolute URI", errServiceInvalid, service.ServiceEndpoint)
+		}
+
+		switch {
+		case service.ID == "":
+			service.ID = fmt.Sprintf("%s#service-%d", did, i+1)
+		case strings.HasPrefix(service.ID, did+"#"):
+		case strings.HasPrefix(service.ID, "did:"):
+			return nil, fmt.Errorf("%w: id %s belongs to another DID", errServiceInvalid, service.ID)
+		default:
+			service.ID = did + "#" + strings.TrimPrefix(service.ID, "#")
+		}
+		if fragment := strings.TrimPrefix(service.ID, did+"#"); fragment == "" || strings.HasPrefix(fragment, "keys-") {
+			return nil, fmt.Errorf("%w: id %s is reserved", errServiceInvalid, service.ID)
+		}
+		if taken[service.ID] {
+			return nil, fmt.Errorf("%w: duplicate id %s", errServiceInvalid, service.ID)
+		}
+		taken[service.ID] = true
+
+		normalized = append(normalized, service)
+	}
+	return normalized, nil
+}
+
+// validateControllers checks that every controller is a DID and listed once
+func validateControllers(controllers []string) error {
+	seen := map[string]bool{}
+	for _, controller := range controllers {
+		parts := strings.SplitN(controller, ":", 3)
+		if len(parts) != 3 || parts[0] != "did" || parts[1] == "" || parts[2] == "" {
+			return fmt.Errorf("%w: %q is not a DID", errControllerInvalid, controller)
+		}
+		if seen[controller] {
+			return fmt.Errorf("%w: duplicate controller %s", errControllerInvalid, controller)
+		}
+		seen[controller] = true
+	}
+	return nil
+}
+
+// applyDocumentUpdate returns the DID document with its controllers and services replaced by
+// those of the update
+func applyDocumentUpdate(didDocument DIDDocument, update DocumentUpdateRequest) (DIDDocument, error) {
+	updated := didDocument
+	if update.Controller != nil {
+		if err := validateControllers(*update.Controller); err != nil {
+			return DIDDocument{}, err
+		}
+		updated.Controller = append([]string{}, *update.Controller...)
+	}
+	if update.Services != nil {
+		services, err := normalizeServices(didDocument, *update.Services)
+		if err != nil {
+			return DIDDocument{}, err
+		}
+		updated.Service = services
+	}
+	return updated, nil
+}
+
+// lockDIDDocument reads the current document and version of a DID within the transaction and
+// locks its row, so concurrent updates cannot both claim the next version. Like getDID, DIDs of
+// other organizations than the caller's are reported as not found.
+func lockDIDDocument(ctx context.Context, tx pgx.Tx, did, caller string) (DIDDocument, int, error) {
+	var didDocument DIDDocument
+	var version int
+	var deactivated bool
+	var organizationID string
+	err := tx.QueryRow(ctx, "SELECT document, version_id, deactivated, organization_id FROM dids WHERE did = $1 FOR UPDATE", did).Scan(&didDocument, &version, &deactivated, &organizationID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DIDDocument{}, 0, errDIDNotFound
+	}
+	if err != nil {
+		return DIDDocument{}, 0, err
+	}
+	if organizationID != caller {
+		return DIDDocument{}, 0, errDIDNotFound
+	}
+	if deactivated {
+		return DIDDocument{}, 0, errDIDDeactivated
+	}
+	return didDocument, version, nil
+}
+
+// storeDIDDocumentVersion makes the document the current version of its DID and adds it to the
+// DID's version history, returning the stored JSON
+func storeDIDDocumentVersion(ctx context.Context, tx pgx.Tx, didDocument DIDDocument, version int) ([]byte, error) {
+	publicKeyJSON, err := json.Marshal(didDocument.VerificationMethod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	didDocJSON, err := json.Marshal(didDocument)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal DID document: %w", err)
+	}
+
+	updatedAt := time.Now().UTC()
+	_, err = tx.Exec(ctx, "UPDATE dids SET document = $1, public_key = $2, version_id = $3, updated_at = $4 WHERE did = $5",
+		didDocJSON, publicKeyJSON, version, updatedAt, didDocument.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update DID: %w", err)
+	}
+	_, err = tx.Exec(ctx, "INSERT INTO did_document_versions (did, version_id, document, created_at) VALUES ($1, $2, $3, $4)",
+		didDocument.ID, version, didDocJSON, updatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store DID document version: %w", err)
+	}
+	return didDocJSON, nil
+}
+
+// writeLockError responds to a failed lockDIDDocument
+func writeLockError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errDIDNotFound):
+		http.Error(w, "DID not found", http.StatusNotFound)
+	case errors.Is(err, errDIDDeactivated):
+		http.Error(w, "DID is deactivated", http.StatusConflict)
+	default:
+		log.Printf("Failed to execute query: %v", err)
+		http.Error(w, "Failed to update DID", http.StatusInternalServerError)
+	}
+}
+
+// updateDIDDocument replaces the controllers and/or services of a DID and stores the result as
+// a new version of the DID document. Like key updates, this is limited to did:web documents.
+func updateDIDDocument(w http.ResponseWriter, r *http.Request) {
+	did, err := didFromPath(r)
+	if err != nil {
+		http.Error(w, "Invalid DID", http.StatusBadRequest)
+		return
+	}
+	caller := r.Header.Get(organizationHeader)
+	if caller == "" {
+		http.Error(w, errMissingOrganization.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var update DocumentUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if update.Controller == nil && update.Services == nil {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	if !strings.HasPrefix(did, didWebPrefix) {
+		http.Error(w, "DID method does not support document updates", http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		http.Error(w, "Failed to update DID", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	didDocument, version, err := lockDIDDocument(ctx, tx, did, caller)
+	if err != nil {
+		writeLockError(w, err)
+		return
+	}
+	version++
+
+	updated, err := applyDocumentUpdate(didDocument, update)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	didDocJSON, err := storeDIDDocumentVersion(ctx, tx, updated, version)
+	if err != nil {
+		log.Printf("Failed to store DID update: %v", err)
+		http.Error(w, "Failed to update DID", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Failed to commit DID update: %v", err)
+		http.Error(w, "Failed to update DID", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(didDocJSON)
+	log.Printf("DID %s updated to version %d (controllers and services)", did, version)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyDocumentUpdate(t *testing.T) {
+	didDocument := newTestDIDWebDocument(t)
+	controllers := []string{"did:web:example.org", "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"}
+	services := []Service{
+		{Type: "LinkedDomains", ServiceEndpoint: "https://example.com"},
+		{ID: "#didcomm", Type: "DIDCommMessaging", ServiceEndpoint: "https://example.com/didcomm"},
+		{ID: "did:web:example.com#issuance", Type: "CredentialIssuance", ServiceEndpoint: "https://example.com/v1/credentials"},
+	}
+
+	updated, err := applyDocumentUpdate(didDocument, DocumentUpdateRequest{Controller: &controllers, Services: &services})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(updated.Controller) != 2 || updated.Controller[0] != "did:web:example.org" {
+		t.Errorf("Expected the controllers to be set, got %v", updated.Controller)
+	}
+	expectedIDs := []string{"did:web:example.com#service-1", "did:web:example.com#didcomm", "did:web:example.com#issuance"}
+	if len(updated.Service) != len(expectedIDs) {
+		t.Fatalf("Expected %d services, got %+v", len(expectedIDs), updated.Service)
+	}
+	for i, id := range expectedIDs {
+		if updated.Service[i].ID != id {
+			t.Errorf("Expected service id %s, got %s", id, updated.Service[i].ID)
+		}
+	}
+	if len(didDocument.Service) != 0 || len(didDocument.Controller) != 0 {
+		t.Error("Expected the original document to be left unchanged")
+	}
+
+	// Omitted fields are kept, empty lists clear them
+	none := []string{}
+	cleared, err := applyDocumentUpdate(updated, DocumentUpdateRequest{Controller: &none})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(cleared.Controller) != 0 || len(cleared.Service) != 3 {
+		t.Errorf("Expected only the controllers to be cleared, got %v %+v", cleared.Controller, cleared.Service)
+	}
+}
+
+func TestApplyDocumentUpdateInvalid(t *testing.T) {
+	didDocument := newTestDIDWebDocument(t)
+
+	invalidServices := [][]Service{
+		{{Type: "LinkedDomains"}},
+		{{Type: "LinkedDomains", ServiceEndpoint: "example.com"}},
+		{{ID: "#keys-1", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+		{{ID: "#keys-2", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+		{{ID: "did:web:example.org#domains", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+		{
+			{ID: "#domains", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"},
+			{ID: "domains", Type: "LinkedDomains", ServiceEndpoint: "https://example.org"},
+		},
+	}
+	for _, services := range invalidServices {
+		if _, err := applyDocumentUpdate(didDocument, DocumentUpdateRequest{Services: &services}); !errors.Is(err, errServiceInvalid) {
+			t.Errorf("Expected services %+v to be rejected, got %v", services, err)
+		}
+	}
+
+	invalidControllers := [][]string{
+		{"example.com"},
+		{"did:web:"},
+		{"did:web:example.org", "did:web:example.org"},
+	}
+	for _, controllers := range invalidControllers {
+		if _, err := applyDocumentUpdate(didDocument, DocumentUpdateRequest{Controller: &controllers}); !errors.Is(err, errControllerInvalid) {
+			t.Errorf("Expected controllers %v to be rejected, got %v", controllers, err)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bradtumy/credential-service/dataintegrity"
+	"github.com/bradtumy/credential-service/keystore"
+	"github.com/jackc/pgx/v4"
+	"github.com/mr-tron/base58"
+)
+
+// DIF Well-Known DID Configuration (https://identity.foundation/.well-known/resources/did-configuration/)
+const (
+	didConfigurationContext      = "https://identity.foundation/.well-known/did-configuration/v1"
+	credentialsContextV1         = "https://www.w3.org/2018/credentials/v1"
+	domainLinkageCredentialType  = "DomainLinkageCredential"
+	defaultDomainLinkageValidity = 365 * 24 * time.Hour
+)
+
+// Data Integrity proofs of Domain Linkage Credentials, signed like the credentials issuer-service
+// issues: a JCS cryptosuite chosen by the key type and a base58btc multibase proof value
+const (
+	proofTypeDataIntegrity = dataintegrity.ProofType
+	proofPurposeAssertion  = "assertionMethod"
+)
+
+var errInvalidOrigin = errors.New("invalid origin")
+
+// DomainLinkageCredential links a DID to a web origin. The DID is both the issuer and the subject.
+type DomainLinkageCredential struct {
+	Context           []string             `json:"@context"`
+	Issuer            string               `json:"issuer"`
+	IssuanceDate      string               `json:"issuanceDate"`
+	ExpirationDate    string               `json:"expirationDate"`
+	Type              []string             `json:"type"`
+	CredentialSubject DomainLinkageSubject `json:"credentialSubject"`
+	Proof             *DataIntegrityProof  `json:"proof,omitempty"`
+}
+
+// DomainLinkageSubject names the DID and the origin it is linked to
+type DomainLinkageSubject struct {
+	ID     string `json:"id"`
+	Origin string `json:"origin"`
+}
+
+// DataIntegrityProof is the proof of a Domain Linkage Credential
+type DataIntegrityProof struct {
+	Type               string `json:"type"`
+	Cryptosuite        string `json:"cryptosuite"`
+	Created            string `json:"created"`
+	ProofPurpose       string `json:"proofPurpose"`
+	VerificationMethod string `json:"verificationMethod"`
+	ProofValue         string `json:"proofValue"`
+}
+
+// DIDConfiguration is the did-configuration.json resource served at
+// <origin>/.well-known/did-configuration.json
+type DIDConfiguration struct {
+	Context    string            `json:"@context"`
+	LinkedDIDs []json.RawMessage `json:"linked_dids"`
+}
+
+// DomainLinkageRequest is the payload of POST /v1/dids/{did}/domain-linkage
+type DomainLinkageRequest struct {
+	Origin         string `json:"origin"`                   // e.g. "https://issuer.example.com"
+	ExpirationDate string `json:"expirationDate,omitempty"` // RFC 3339, a year from now by default
+}
+
+// normalizeOrigin returns the scheme, host and port of an https origin, lower-cased and without
+// a trailing slash
+func normalizeOrigin(origin string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(origin))
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" || parsed.User != nil ||
+		(parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", fmt.Errorf("%w: %q must be an https origin without path, e.g. https://example.com", errInvalidOrigin, origin)
+	}
+	return "https://" + strings.ToLower(parsed.Host), nil
+}
+
+// newDomainLinkageCredential builds the unsigned credential linking a DID to an origin
+func newDomainLinkageCredential(did, origin string, issuedAt, expiresAt time.Time) DomainLinkageCredential {
+	return DomainLinkageCredential{
+		Context:           []string{credentialsContextV1, didConfigurationContext},
+		Issuer:            did,
+		IssuanceDate:      issuedAt.UTC().Format(time.RFC3339),
+		ExpirationDate:    expiresAt.UTC().Format(time.RFC3339),
+		Type:              []string{"VerifiableCredential", domainLinkageCredentialType},
+		CredentialSubject: DomainLinkageSubject{ID: did, Origin: origin},
+	}
+}
+
+// proofHashData builds the JCS cryptosuites' signing input of a Domain Linkage Credential, see
+// dataintegrity.HashData
+func proofHashData(credential DomainLinkageCredential, proof DataIntegrityProof) ([]byte, error) {
+	credential.Proof = nil
+	return dataintegrity.HashData(dataintegrity.ProofConfig{
+		Context:            credential.Context,
+		Type:               proof.Type,
+		Cryptosuite:        proof.Cryptosuite,
+		Created:            proof.Created,
+		ProofPurpose:       proof.ProofPurpose,
+		VerificationMethod: proof.VerificationMethod,
+	}, credential)
+}
+
+// signDomainLinkageCredential adds a Data Integrity proof made with the verification method's key
+func signDomainLinkageCredential(ctx context.Context, credential DomainLinkageCredential, store keystore.KeyStore, verificationMethod string) (DomainLinkageCredential, error) {
+	key, err := store.PublicKey(ctx, verificationMethod)
+	if err != nil {
+		return DomainLinkageCredential{}, err
+	}
+	cryptosuite, ok := dataintegrity.Cryptosuite(key.Type)
+	if !ok {
+		return DomainLinkageCredential{}, fmt.Errorf("unsupported key type: %q", key.Type)
+	}
+
+	proof := DataIntegrityProof{
+		Type:               proofTypeDataIntegrity,
+		Cryptosuite:        cryptosuite,
+		Created:            time.Now().UTC().Format(time.RFC3339),
+		ProofPurpose:       proofPurposeAssertion,
+		VerificationMethod: verificationMethod,
+	}
+	hashData, err := proofHashData(credential, proof)
+	if err != nil {
+		return DomainLinkageCredential{}, err
+	}
+	signature, err := store.Sign(ctx, verificationMethod, hashData)
+	if err != nil {
+		return DomainLinkageCredential{}, err
+	}
+
+	proof.ProofValue = multibaseBase58BTC + base58.Encode(signature)
+	credential.Proof = &proof
+	return credential, nil
+}
+
+// loadDIDConfiguration collects the Domain Linkage Credentials of every active DID linked to an
+// origin into its did-configuration.json
+func loadDIDConfiguration(ctx context.Context, origin string) (DIDConfiguration, error) {
+	configuration := DIDConfiguration{Context: didConfigurationContext, LinkedDIDs: []json.RawMessage{}}
+
+	rows, err := db.Query(ctx, `SELECT l.credential FROM domain_linkage_credentials l
+		JOIN dids d ON d.did = l.did
+		WHERE l.origin = $1 AND NOT d.deactivated AND l.expires_at > now()
+		ORDER BY l.created_at`, origin)
+	if err != nil {
+		return DIDConfiguration{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var credential string
+		if err := rows.Scan(&credential); err != nil {
+			return DIDConfiguration{}, err
+		}
+		configuration.LinkedDIDs = append(configuration.LinkedDIDs, json.RawMessage(credential))
+	}
+	return configuration, rows.Err()
+}
+
+// createDomainLinkage signs a Domain Linkage Credential linking an organization DID to an origin
+// with the DID's first assertion key, stores it, replacing an earlier one for the same origin, and
+// returns the origin's did-configuration.json. Only the DID's own organization can link it.
+func createDomainLinkage(w http.ResponseWriter, r *http.Request) {
+	did, err := didFromPath(r)
+	if err != nil {
+		http.Error(w, "Invalid DID", http.StatusBadRequest)
+		return
+	}
+	caller := r.Header.Get(organizationHeader)
+	if caller == "" {
+		http.Error(w, errMissingOrganization.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var request DomainLinkageRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	origin, err := normalizeOrigin(request.Origin)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	issuedAt := time.Now().UTC()
+	expiresAt := issuedAt.Add(defaultDomainLinkageValidity)
+	if request.ExpirationDate != "" {
+		expiresAt, err = time.Parse(time.RFC3339, request.ExpirationDate)
+		if err != nil || !expiresAt.After(issuedAt) {
+			http.Error(w, "expirationDate must be a future RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := context.Background()
+	var didDocument DIDDocument
+	var deactivated bool
+	var organizationID string
+	err = db.QueryRow(ctx, "SELECT document, deactivated, organization_id FROM dids WHERE did = $1", did).Scan(&didDocument, &deactivated, &organizationID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "DID not found", http.StatusNotFound)
+		} else {
+			log.Printf("Failed to execute query: %v", err)
+			http.Error(w, "Failed to create domain linkage", http.StatusInternalServerError)
+		}
+		return
+	}
+	if organizationID != caller {
+		http.Error(w, "DID not found", http.StatusNotFound)
+		return
+	}
+	if deactivated {
+		http.Error(w, "DID is deactivated", http.StatusConflict)
+		return
+	}
+	if didDocument.OrganizationID == "" {
+		http.Error(w, "Only organization DIDs can be linked to a domain", http.StatusBadRequest)
+		return
+	}
+	if len(didDocument.AssertionMethod) == 0 {
+		http.Error(w, "DID has no assertion key", http.StatusBadRequest)
+		return
+	}
+
+	credential, err := signDomainLinkageCredential(ctx, newDomainLinkageCredential(did, origin, issuedAt, expiresAt), keyStore, didDocument.AssertionMethod[0])
+	if err != nil {
+		log.Printf("Failed to sign domain linkage credential: %v", err)
+		http.Error(w, "Failed to create domain linkage", http.StatusInternalServerError)
+		return
+	}
+	credentialJSON, err := json.Marshal(credential)
+	if err != nil {
+		log.Printf("Failed to marshal domain linkage credential: %v", err)
+		http.Error(w, "Failed to create domain linkage", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = db.Exec(ctx, `INSERT INTO domain_linkage_credentials (origin, did, credential, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (origin, did) DO UPDATE SET credential = $3, created_at = $4, expires_at = $5`,
+		origin, did, credentialJSON, issuedAt, expiresAt)
+	if err != nil {
+		log.Printf("Failed to store domain linkage credential: %v", err)
+		http.Error(w, "Failed to create domain linkage", http.StatusInternalServerError)
+		return
+	}
+
+	configuration, err := loadDIDConfiguration(ctx, origin)
+	if err != nil {
+		log.Printf("Failed to load DID configuration: %v", err)
+		http.Error(w, "Failed to create domain linkage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(configuration)
+	log.Printf("Linked DID %s to %s", did, origin)
+}
+
+// serveDIDConfiguration serves /.well-known/did-configuration.json for the origin of the request's
+// host, like did:web documents are served
+func serveDIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	origin, err := normalizeOrigin("https://" + r.Host)
+	if err != nil {
+		http.Error(w, "DID configuration not found", http.StatusNotFound)
+		return
+	}
+
+	configuration, err := loadDIDConfiguration(context.Background(), origin)
+	if err != nil {
+		log.Printf("Failed to load DID configuration: %v", err)
+		http.Error(w, "Failed to retrieve DID configuration", http.StatusInternalServerError)
+		return
+	}
+	if len(configuration.LinkedDIDs) == 0 {
+		http.Error(w, "DID configuration not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(configuration)
+	log.Printf("Served DID configuration of %s", origin)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradtumy/credential-service/keystore"
+	"github.com/mr-tron/base58"
+)
+
+func TestNormalizeOrigin(t *testing.T) {
+	tests := map[string]string{
+		"https://Issuer.Example.com":      "https://issuer.example.com",
+		"https://issuer.example.com/":     "https://issuer.example.com",
+		"https://issuer.example.com:8443": "https://issuer.example.com:8443",
+		" https://issuer.example.com ":    "https://issuer.example.com",
+	}
+	for origin, expected := range tests {
+		if normalized, err := normalizeOrigin(origin); err != nil || normalized != expected {
+			t.Errorf("normalizeOrigin(%q) = %q, %v; expected %q", origin, normalized, err, expected)
+		}
+	}
+
+	for _, invalid := range []string{"http://issuer.example.com", "issuer.example.com", "https://issuer.example.com/path", "https://issuer.example.com?x=1", "https://user@issuer.example.com"} {
+		if _, err := normalizeOrigin(invalid); !errors.Is(err, errInvalidOrigin) {
+			t.Errorf("Expected %q to be rejected, got %v", invalid, err)
+		}
+	}
+}
+
+func TestSignDomainLinkageCredential(t *testing.T) {
+	ctx := context.Background()
+	store := keystore.NewMemory()
+	did := "did:web:issuer.example.com"
+	keyID := did + "#keys-1"
+
+	key, err := store.Create(ctx, keyTypeEd25519)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.Assign(ctx, key, keyID); err != nil {
+		t.Fatalf("Failed to assign key: %v", err)
+	}
+
+	issuedAt := time.Now()
+	credential, err := signDomainLinkageCredential(ctx, newDomainLinkageCredential(did, "https://issuer.example.com", issuedAt, issuedAt.Add(time.Hour)), store, keyID)
+	if err != nil {
+		t.Fatalf("Failed to sign credential: %v", err)
+	}
+	if credential.Issuer != did || credential.CredentialSubject.ID != did || credential.Type[1] != domainLinkageCredentialType {
+		t.Errorf("Unexpected credential %+v", credential)
+	}
+
+	proof := credential.Proof
+	if proof == nil || proof.Cryptosuite != "eddsa-jcs-2022" || proof.VerificationMethod != keyID || !strings.HasPrefix(proof.ProofValue, multibaseBase58BTC) {
+		t.Fatalf("Unexpected proof %+v", proof)
+	}
+	signature, err := base58.Decode(proof.ProofValue[1:])
+	if err != nil {
+		t.Fatalf("Failed to decode proof value: %v", err)
+	}
+	hashData, err := proofHashData(credential, *proof)
+	if err != nil {
+		t.Fatalf("Failed to hash credential: %v", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key.PublicKey), hashData, signature) {
+		t.Error("Expected the proof to verify against the DID's key")
+	}
+
+	if _, err := signDomainLinkageCredential(ctx, credential, store, did+"#keys-9"); !errors.Is(err, keystore.ErrKeyNotFound) {
+		t.Errorf("Expected an unknown key to be reported, got %v", err)
+	}
+}
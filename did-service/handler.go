@@ -2,60 +2,146 @@ package main
 
 import (
 	"context"
-	"crypto/ed25519"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 )
 
-// Define the PublicKey struct
-type PublicKey struct {
-	ID              string `json:"id"`
-	Type            string `json:"type"`
-	Controller      string `json:"controller"`
-	PublicKeyBase58 string `json:"publicKeyBase58"`
+// VerificationMethod describes a public key of the DID subject (DID Core "verificationMethod")
+type VerificationMethod struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller"`
+	PublicKeyMultibase string `json:"publicKeyMultibase"`
 }
+
+// Service describes a service endpoint of the DID subject (DID Core "service")
+type Service struct {
+	ID              string   `json:"id"`
+	Type            string   `json:"type"`
+	ServiceEndpoint string   `json:"serviceEndpoint"`
+	RoutingKeys     []string `json:"routingKeys,omitempty"`
+	Accept          []string `json:"accept,omitempty"`
+}
+
 type DIDDocument struct {
-	Context        string      `json:"@context"`
-	ID             string      `json:"id"`
-	PublicKey      []PublicKey `json:"publicKey"`
-	CreatedAt      string      `json:"createdAt"`
-	OrganizationID string      `json:"organization_id,omitempty"` // Keep this as it is
-	HolderID       string      `json:"holder_id,omitempty"`       // Add HolderID
+	Context            []string             `json:"@context"`
+	ID                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod"`
+	Authentication     []string             `json:"authentication"`
+	AssertionMethod    []string             `json:"assertionMethod"`
+	Controller         []string             `json:"controller,omitempty"`
+	Service            []Service            `json:"service,omitempty"`
+	CreatedAt          string               `json:"createdAt"`
+	OrganizationID     string               `json:"organization_id,omitempty"` // Keep this as it is
+	HolderID           string               `json:"holder_id,omitempty"`       // Add HolderID
 }
 
 // Create a new DID and store the DID document in the database
 func createDID(w http.ResponseWriter, r *http.Request) {
-	// Generate a new Ed25519 key pair
-	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	// Extract type from the request payload
+	var payload struct {
+		Type           string    `json:"type"`                 // "organization" or "holder"
+		KeyType        string    `json:"keyType,omitempty"`    // "Ed25519" (default), "P-256", "secp256k1" or "BLS12-381-G2"
+		Method         string    `json:"method,omitempty"`     // "key" (default), "web" or "peer"
+		Domain         string    `json:"domain,omitempty"`     // did:web only, e.g. "example.com" or "example.com:8443"
+		Path           string    `json:"path,omitempty"`       // did:web only, optional, e.g. "issuers/hr"
+		Numalgo        *int      `json:"numalgo,omitempty"`    // did:peer only, 0 or 2 (default)
+		Services       []Service `json:"services,omitempty"`   // did:web and did:peer:2 only, encoded into the DID for did:peer:2
+		Controller     []string  `json:"controller,omitempty"` // did:web only, DIDs allowed to make changes to the document
+		OrganizationID string    `json:"organization_id,omitempty"`
+		HolderID       string    `json:"holder_id,omitempty"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
-		log.Printf("Failed to generate key pair: %v", err)
-		http.Error(w, "Failed to generate DID", http.StatusInternalServerError)
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
 
-	// Encode the public key in base64
-	encodedPublicKey := base64.RawURLEncoding.EncodeToString(publicKey)
+	// Only known key types are generated, anything else is a client error
+	if _, ok := keyTypeMulticodecs[payload.KeyType]; payload.KeyType != "" && !ok {
+		http.Error(w, "Invalid keyType specified", http.StatusBadRequest)
+		return
+	}
 
-	// Convert ed25519.PrivateKey to base64 string
-	encodedPrivateKey := base64.StdEncoding.EncodeToString(privateKey)
+	// did:key and did:peer documents are derived from the DID, so only did:web documents can
+	// name controllers, and only did:web and did:peer:2 documents can list services
+	if len(payload.Controller) > 0 && payload.Method != "web" {
+		http.Error(w, "Only did:web supports controllers", http.StatusBadRequest)
+		return
+	}
+	if err := validateControllers(payload.Controller); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(payload.Services) > 0 && (payload.Method == "" || payload.Method == "key") {
+		http.Error(w, "did:key does not support services", http.StatusBadRequest)
+		return
+	}
 
-	// Extract type from the request payload
-	var payload struct {
-		Type           string `json:"type"` // "organization" or "holder"
-		OrganizationID string `json:"organization_id,omitempty"`
-		HolderID       string `json:"holder_id,omitempty"`
+	// Every DID belongs to an organization, which alone can list and read it: an organization DID
+	// to its organization, a holder DID to the organization that created it for the holder. The
+	// organization is the caller's, or else the payload's organization_id.
+	if caller := r.Header.Get(organizationHeader); caller != "" {
+		if payload.OrganizationID != "" && payload.OrganizationID != caller {
+			http.Error(w, errForbiddenOrganization.Error(), http.StatusForbidden)
+			return
+		}
+		payload.OrganizationID = caller
 	}
-	err = json.NewDecoder(r.Body).Decode(&payload)
+	if payload.OrganizationID == "" {
+		http.Error(w, "Missing organization_id", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the owner of the DID based on the type
+	switch payload.Type {
+	case "organization":
+	case "holder":
+		if payload.HolderID == "" {
+			http.Error(w, "Missing holder_id", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Invalid type specified", http.StatusBadRequest)
+		return
+	}
+
+	switch payload.Method {
+	case "", "key", "web", "peer":
+	default:
+		http.Error(w, "Invalid method specified", http.StatusBadRequest)
+		return
+	}
+
+	// Create a key of the requested type in the key store. Until the DID is stored, every error
+	// discards it again, together with its assignments.
+	ctx := context.Background()
+	key, publicKeyMultibase, err := createKey(ctx, payload.KeyType)
 	if err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		log.Printf("Failed to create key pair: %v", err)
+		http.Error(w, "Failed to generate DID", http.StatusInternalServerError)
 		return
 	}
+	var assigned []string
+	stored := false
+	defer func() {
+		if stored {
+			return
+		}
+		if len(assigned) > 0 {
+			if err := keyStore.Unassign(ctx, assigned...); err != nil {
+				log.Printf("Failed to unassign keys of %s: %v", key.Name, err)
+			}
+		}
+		discardKey(ctx, key)
+	}()
 
 	/*
 		// Extract organization_id from the request payload
@@ -72,59 +158,62 @@ func createDID(w http.ResponseWriter, r *http.Request) {
 		}
 	*/
 
-	var did string
+	createdAt := time.Now().UTC()
 
-	// Construct the DID based on the type
-	switch payload.Type {
-	case "organization":
-		if payload.OrganizationID == "" {
-			http.Error(w, "Missing organization_id", http.StatusBadRequest)
+	// Build the DID and its DID Document for the requested method
+	var didDocument DIDDocument
+	switch payload.Method {
+	case "", "key":
+		// did:key identifiers and documents are derived from the public key
+		didDocument = newDIDKeyDocument(publicKeyMultibase)
+	case "web":
+		didWeb, err := didWebFromDomain(payload.Domain, payload.Path)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid did:web domain or path: %v", err), http.StatusBadRequest)
 			return
 		}
-		did = fmt.Sprintf("did:key:z6M%s", encodedPublicKey)
-	case "holder":
-		if payload.HolderID == "" {
-			http.Error(w, "Missing holder_id", http.StatusBadRequest)
+		didDocument, err = applyDocumentUpdate(newDIDWebDocument(didWeb, publicKeyMultibase),
+			DocumentUpdateRequest{Controller: &payload.Controller, Services: &payload.Services})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case "peer":
+		numalgo := 2
+		if payload.Numalgo != nil {
+			numalgo = *payload.Numalgo
+		}
+		switch {
+		case numalgo == 0 && len(payload.Services) == 0:
+			didDocument = newDIDPeer0Document(publicKeyMultibase)
+		case numalgo == 0:
+			http.Error(w, "did:peer:0 does not support services", http.StatusBadRequest)
+			return
+		case numalgo == 2:
+			didDocument, err = newDIDPeer2Document(publicKeyMultibase, payload.Services)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid did:peer service: %v", err), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "Unsupported did:peer numalgo", http.StatusBadRequest)
 			return
 		}
-		did = fmt.Sprintf("did:key:z6M%s", encodedPublicKey)
 	default:
-		http.Error(w, "Invalid type specified", http.StatusBadRequest)
+		http.Error(w, "Invalid method specified", http.StatusBadRequest)
 		return
 	}
-
-	/*
-		// Construct the DID
-		did := fmt.Sprintf("did:key:z6M%s", encodedPublicKey)
-	*/
-
-	createdAt := time.Now().UTC()
-
-	// Create the PublicKey object
-	publicKeyObject := PublicKey{
-		ID:              fmt.Sprintf("%s#keys-1", did), // Unique key ID
-		Type:            "Ed25519VerificationKey2018",
-		Controller:      did,
-		PublicKeyBase58: encodedPublicKey,
-	}
+	didDocument.CreatedAt = createdAt.Format(time.RFC3339)
+	did := didDocument.ID
 
 	// Create a JSON representation of the public key
-	publicKeyJSON, err := json.Marshal([]PublicKey{publicKeyObject})
+	publicKeyJSON, err := json.Marshal(didDocument.VerificationMethod)
 	if err != nil {
 		log.Printf("Failed to marshal public key: %v", err)
 		http.Error(w, "Failed to generate DID", http.StatusInternalServerError)
 		return
 	}
 
-	// Create the DID Document
-	didDocument := DIDDocument{
-		Context:   "https://www.w3.org/ns/did/v1",
-		ID:        did,
-		PublicKey: []PublicKey{publicKeyObject}, // Wrap in an array
-		CreatedAt: createdAt.Format(time.RFC3339),
-		//OrganizationID: organizationID,
-	}
-
 	// Set the OrganizationID or HolderID in the document based on the type
 	if payload.Type == "organization" {
 		didDocument.OrganizationID = payload.OrganizationID
@@ -145,29 +234,81 @@ func createDID(w http.ResponseWriter, r *http.Request) {
 	// Log the generated JSON
 	log.Printf("DID Document JSON: %s", string(didDocJSON))
 
-	// Store the DID, public key, and DID document in the database
-	query := "INSERT INTO dids (did, organization_id, created_at, public_key, document) VALUES ($1, $2, $3, $4, $5)"
-	_, err = db.Exec(context.Background(), query, did, payload.OrganizationID, createdAt, publicKeyJSON, didDocJSON)
+	// Store the DID, public key, and DID document in the database, along with the document as
+	// its first version
+	tx, err := db.Begin(ctx)
 	if err != nil {
-		log.Printf("Failed to insert DID into database: %v", err)
+		log.Printf("Failed to begin transaction: %v", err)
 		http.Error(w, "Failed to store DID", http.StatusInternalServerError)
 		return
 	}
-	// Securely store the private key
-	log.Printf("Private key for DID %s: %x", did, encodedPrivateKey)
-	err = savePrivateKeyToVault(did, encodedPrivateKey)
+	defer tx.Rollback(ctx)
+
+	query := "INSERT INTO dids (did, organization_id, created_at, public_key, document, version_id) VALUES ($1, $2, $3, $4, $5, 1)"
+	_, err = tx.Exec(ctx, query, did, payload.OrganizationID, createdAt, publicKeyJSON, didDocJSON)
+	if err == nil {
+		_, err = tx.Exec(ctx, "INSERT INTO did_document_versions (did, version_id, document, created_at) VALUES ($1, 1, $2, $3)", did, didDocJSON, createdAt)
+	}
 	if err != nil {
-		log.Printf("Error storing private key: %s", err)
+		log.Printf("Failed to insert DID into database: %v", err)
+		// 23505 is PostgreSQL's unique_violation, e.g. a did:web that was already created
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			http.Error(w, "DID already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to store DID", http.StatusInternalServerError)
+		return
+	}
+
+	// Record which key signs for each verification method before committing, so a stored DID
+	// can always sign
+	assigned = verificationMethodIDs(didDocument)
+	if err := keyStore.Assign(ctx, key, assigned...); err != nil {
+		log.Printf("Error assigning key: %s", err)
+		http.Error(w, "Failed to store DID", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Failed to commit DID: %v", err)
+		http.Error(w, "Failed to store DID", http.StatusInternalServerError)
+		return
 	}
+	stored = true
 
 	// Respond with the DID document
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(didDocJSON)
 	log.Printf("DID created successfully: %s", did)
 }
+
+// verificationMethodIDs returns the ids of all verification methods of a DID document
+func verificationMethodIDs(didDocument DIDDocument) []string {
+	keyIDs := make([]string, 0, len(didDocument.VerificationMethod))
+	for _, method := range didDocument.VerificationMethod {
+		keyIDs = append(keyIDs, method.ID)
+	}
+	return keyIDs
+}
+
+// getDIDs returns a page of DID documents matching the filters of the query, see didListFilter
 func getDIDs(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseDIDListFilter(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, errMissingOrganization):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, errForbiddenOrganization):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
 	// Query to retrieve DIDs from the database
-	rows, err := db.Query(context.Background(), "SELECT did, document FROM dids")
+	query, args := filter.sql()
+	rows, err := db.Query(context.Background(), query, args...)
 	if err != nil {
 		log.Printf("Failed to execute query: %v", err)
 		http.Error(w, "Failed to retrieve DIDs", http.StatusInternalServerError)
@@ -176,38 +317,58 @@ func getDIDs(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 
 	// Collect DIDs into a list of documents
-	var dids []json.RawMessage
+	list := DIDList{DIDs: []json.RawMessage{}}
+	var lastID int64
 	for rows.Next() {
-		var did, document string
-		if err := rows.Scan(&did, &document); err != nil {
+		var id int64
+		var document string
+		if err := rows.Scan(&id, &document); err != nil {
 			log.Printf("Failed to scan row: %v", err)
 			http.Error(w, "Failed to retrieve DIDs", http.StatusInternalServerError)
 			return
 		}
-		dids = append(dids, json.RawMessage(document))
+		// The extra row only tells that another page follows
+		if len(list.DIDs) == filter.Limit {
+			list.NextCursor = encodeDIDCursor(lastID)
+			break
+		}
+		list.DIDs = append(list.DIDs, json.RawMessage(document))
+		lastID = id
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Failed to read rows: %v", err)
+		http.Error(w, "Failed to retrieve DIDs", http.StatusInternalServerError)
+		return
 	}
 
 	// Respond with the DID documents
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(dids); err != nil {
+	if err := json.NewEncoder(w).Encode(list); err != nil {
 		log.Printf("Failed to encode response: %v", err)
 		http.Error(w, "Failed to retrieve DIDs", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Retrieved %d DIDs", len(dids))
+	log.Printf("Retrieved %d DIDs", len(list.DIDs))
 }
 
+// getDID returns the current DID document of a DID. DIDs of other organizations than the
+// caller's are reported as not found.
 func getDID(w http.ResponseWriter, r *http.Request) {
-	did := r.URL.Query().Get("did")
-	if did == "" {
-		http.Error(w, "Missing DID", http.StatusBadRequest)
+	did, err := didFromPath(r)
+	if err != nil {
+		http.Error(w, "Invalid DID", http.StatusBadRequest)
+		return
+	}
+	caller := r.Header.Get(organizationHeader)
+	if caller == "" {
+		http.Error(w, errMissingOrganization.Error(), http.StatusUnauthorized)
 		return
 	}
 
 	// Query to retrieve a specific DID document from the database
-	var document string
-	err := db.QueryRow(context.Background(), "SELECT document FROM dids WHERE did = $1", did).Scan(&document)
+	var document, organizationID string
+	err = db.QueryRow(context.Background(), "SELECT document, organization_id FROM dids WHERE did = $1", did).Scan(&document, &organizationID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			http.Error(w, "DID not found", http.StatusNotFound)
@@ -217,6 +378,10 @@ func getDID(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if caller != organizationID {
+		http.Error(w, "DID not found", http.StatusNotFound)
+		return
+	}
 
 	// Respond with the DID document
 	w.Header().Set("Content-Type", "application/json")
@@ -224,25 +389,32 @@ func getDID(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Retrieved DID document: %s", did)
 }
 
-func savePrivateKeyToVault(did string, privateKey string) error {
+// getPrivateKeyFromVault reads the base64 encoded private key that releases before the key store
+// wrote to the DID's own Vault secret, for migrating legacy DIDs
+func getPrivateKeyFromVault(did string) (string, error) {
 	client, err := getVaultClient()
 	if err != nil {
-		return fmt.Errorf("failed to initialize Vault client: %w", err)
+		return "", fmt.Errorf("failed to initialize Vault client: %w", err)
 	}
 
-	data := map[string]interface{}{
-		"data": map[string]interface{}{
-			"private_key": privateKey,
-		},
-	}
-
-	// Write the private key to Vault at the path "secret/data/dids/<did>"
+	// Read the private key from Vault at the path "secret/data/dids/<did>"
 	secretPath := fmt.Sprintf("secret/data/dids/%s", did)
-	log.Println("Secret Path --> We stored the secrets here: ", secretPath)
-	_, err = client.Logical().Write(secretPath, data)
+	secret, err := client.Logical().Read(secretPath)
 	if err != nil {
-		return fmt.Errorf("failed to write private key to Vault: %w", err)
+		return "", fmt.Errorf("failed to read private key from Vault: %w", err)
+	}
+	if secret == nil {
+		return "", fmt.Errorf("no secret found at path: %s", secretPath)
+	}
+
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("secret data is not in the expected format")
+	}
+	privateKey, ok := data["private_key"].(string)
+	if !ok {
+		return "", fmt.Errorf("private key not found in secret data")
 	}
 
-	return nil
+	return privateKey, nil
 }
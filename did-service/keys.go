@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/bradtumy/credential-service/keystore"
+)
+
+// Key update actions accepted by PUT /v1/dids/{did}/keys
+const (
+	keyActionAdd    = "add"
+	keyActionRotate = "rotate"
+	keyActionRetire = "retire"
+)
+
+var (
+	errKeyNotFound      = errors.New("verification method not found")
+	errLastAssertionKey = errors.New("cannot retire the only assertion key")
+)
+
+// KeyUpdateRequest is the payload of PUT /v1/dids/{did}/keys
+type KeyUpdateRequest struct {
+	Action  string `json:"action"`            // "add", "rotate" or "retire"
+	KeyID   string `json:"keyId,omitempty"`   // Key to rotate or retire, as a DID URL or "#fragment"
+	KeyType string `json:"keyType,omitempty"` // Type of a new key, Ed25519 by default; rotating without one keeps the key's type
+}
+
+// keyFragment returns the fragment of a verification method id, e.g. "keys-1" for "did:web:example.com#keys-1"
+func keyFragment(keyID string) string {
+	if i := strings.LastIndex(keyID, "#"); i >= 0 {
+		return keyID[i+1:]
+	}
+	return keyID
+}
+
+// applyKeyUpdate returns the DID document with the key update applied. Added and rotated keys
+// get the id "#keys-<version>" of the document version that introduces them, so ids are never
+// reused once a key is retired. newKeyID is empty when the update adds no key.
+func applyKeyUpdate(didDocument DIDDocument, version int, update KeyUpdateRequest, multibaseKey string) (updated DIDDocument, newKeyID string, err error) {
+	did := didDocument.ID
+	keyID := ""
+	if update.KeyID != "" {
+		keyID = did + "#" + keyFragment(update.KeyID)
+	}
+
+	newMethod := VerificationMethod{
+		ID:                 fmt.Sprintf("%s#keys-%d", did, version),
+		Type:               "Multikey",
+		Controller:         did,
+		PublicKeyMultibase: multibaseKey,
+	}
+
+	updated = didDocument
+	updated.VerificationMethod = append([]VerificationMethod{}, didDocument.VerificationMethod...)
+	updated.Authentication = append([]string{}, didDocument.Authentication...)
+	updated.AssertionMethod = append([]string{}, didDocument.AssertionMethod...)
+
+	switch update.Action {
+	case keyActionAdd:
+		updated.VerificationMethod = append(updated.VerificationMethod, newMethod)
+		updated.Authentication = append(updated.Authentication, newMethod.ID)
+		updated.AssertionMethod = append(updated.AssertionMethod, newMethod.ID)
+		return updated, newMethod.ID, nil
+
+	case keyActionRotate:
+		if !removeVerificationMethod(&updated, keyID) {
+			return DIDDocument{}, "", fmt.Errorf("%w: %s", errKeyNotFound, update.KeyID)
+		}
+		// The new key takes the old key's place in every relationship
+		updated.VerificationMethod = append(updated.VerificationMethod, newMethod)
+		replaceReference(updated.Authentication, keyID, newMethod.ID)
+		replaceReference(updated.AssertionMethod, keyID, newMethod.ID)
+		return updated, newMethod.ID, nil
+
+	case keyActionRetire:
+		if !removeVerificationMethod(&updated, keyID) {
+			return DIDDocument{}, "", fmt.Errorf("%w: %s", errKeyNotFound, update.KeyID)
+		}
+		updated.Authentication = removeReference(updated.Authentication, keyID)
+		updated.AssertionMethod = removeReference(updated.AssertionMethod, keyID)
+		if len(updated.AssertionMethod) == 0 {
+			return DIDDocument{}, "", errLastAssertionKey
+		}
+		return updated, "", nil
+
+	default:
+		return DIDDocument{}, "", fmt.Errorf("invalid action: %q", update.Action)
+	}
+}
+
+// retireKey unassigns the key of a verification method the DID document no longer lists. The key
+// is destroyed unless another verification method of the document still uses it, as the new
+// version of a rotated key does. Failures are only logged: the document change is already stored.
+func retireKey(ctx context.Context, didDocument DIDDocument, keyID string) {
+	key, err := keyStore.PublicKey(ctx, keyID)
+	if err != nil {
+		log.Printf("Failed to look up retired key %s: %v", keyID, err)
+		return
+	}
+	if err := keyStore.Unassign(ctx, keyID); err != nil {
+		log.Printf("Failed to unassign retired key %s: %v", keyID, err)
+		return
+	}
+
+	for _, method := range didDocument.VerificationMethod {
+		other, err := keyStore.PublicKey(ctx, method.ID)
+		if errors.Is(err, keystore.ErrKeyNotFound) {
+			continue
+		}
+		// Keep the key when in doubt
+		if err != nil || other.Name == key.Name {
+			return
+		}
+	}
+	discardKey(ctx, key)
+}
+
+// removeVerificationMethod drops the verification method with the given id and reports whether it existed
+func removeVerificationMethod(didDocument *DIDDocument, keyID string) bool {
+	for i, method := range didDocument.VerificationMethod {
+		if method.ID == keyID {
+			didDocument.VerificationMethod = append(didDocument.VerificationMethod[:i], didDocument.VerificationMethod[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// hasVerificationMethod reports whether the DID document has a verification method with the given id
+func hasVerificationMethod(didDocument DIDDocument, keyID string) bool {
+	for _, method := range didDocument.VerificationMethod {
+		if method.ID == keyID {
+			return true
+		}
+	}
+	return false
+}
+
+func replaceReference(references []string, oldID, newID string) {
+	for i, reference := range references {
+		if reference == oldID {
+			references[i] = newID
+		}
+	}
+}
+
+func removeReference(references []string, keyID string) []string {
+	kept := references[:0]
+	for _, reference := range references {
+		if reference != keyID {
+			kept = append(kept, reference)
+		}
+	}
+	return kept
+}
+
+// updateDIDKeys adds, rotates or retires a verification method of a DID and stores the result
+// as a new version of the DID document. Only did:web documents can change: did:key and did:peer
+// documents are derived from the identifier itself.
+func updateDIDKeys(w http.ResponseWriter, r *http.Request) {
+	did, err := didFromPath(r)
+	if err != nil {
+		http.Error(w, "Invalid DID", http.StatusBadRequest)
+		return
+	}
+	caller := r.Header.Get(organizationHeader)
+	if caller == "" {
+		http.Error(w, errMissingOrganization.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var update KeyUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if (update.Action == keyActionRotate || update.Action == keyActionRetire) && update.KeyID == "" {
+		http.Error(w, "Missing keyId", http.StatusBadRequest)
+		return
+	}
+	if _, ok := keyTypeMulticodecs[update.KeyType]; update.KeyType != "" && !ok {
+		http.Error(w, "Invalid keyType specified", http.StatusBadRequest)
+		return
+	}
+
+	if !strings.HasPrefix(did, didWebPrefix) {
+		http.Error(w, "DID method does not support key updates", http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		http.Error(w, "Failed to update DID", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	didDocument, version, err := lockDIDDocument(ctx, tx, did, caller)
+	if err != nil {
+		writeLockError(w, err)
+		return
+	}
+	version++
+
+	// Rotating without a key type creates a new version of the rotated key in the key store,
+	// anything else that adds a key creates a new one
+	var key keystore.Key
+	var publicKeyMultibase string
+	created := false
+	switch {
+	case update.Action == keyActionRotate && update.KeyType == "":
+		keyID := did + "#" + keyFragment(update.KeyID)
+		if !hasVerificationMethod(didDocument, keyID) {
+			http.Error(w, fmt.Sprintf("%v: %s", errKeyNotFound, update.KeyID), http.StatusNotFound)
+			return
+		}
+		key, err = keyStore.Rotate(ctx, keyID)
+		publicKeyMultibase = encodeMultibaseKey(key.Type, key.PublicKey)
+	case update.Action == keyActionAdd || update.Action == keyActionRotate:
+		key, publicKeyMultibase, err = createKey(ctx, update.KeyType)
+		created = true
+	}
+	if err != nil {
+		log.Printf("Failed to create key: %v", err)
+		http.Error(w, "Failed to update DID", http.StatusInternalServerError)
+		return
+	}
+
+	// Until the new document version is committed, undo the key store changes on every error so
+	// the store never points at a key the stored document does not list. Only new keys are
+	// discarded, a rotated key still holds its earlier versions.
+	var newKeyID string
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if newKeyID != "" {
+			if err := keyStore.Unassign(ctx, newKeyID); err != nil {
+				log.Printf("Failed to unassign key %s: %v", newKeyID, err)
+			}
+		}
+		if created {
+			discardKey(ctx, key)
+		}
+	}()
+
+	updated, newKeyID, err := applyKeyUpdate(didDocument, version, update, publicKeyMultibase)
+	if err != nil {
+		if errors.Is(err, errKeyNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	// Assign the new key first so the document never references a key the store cannot sign with
+	if newKeyID != "" {
+		if err := keyStore.Assign(ctx, key, newKeyID); err != nil {
+			log.Printf("Error assigning key: %s", err)
+			http.Error(w, "Failed to update DID", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	didDocJSON, err := storeDIDDocumentVersion(ctx, tx, updated, version)
+	if err != nil {
+		log.Printf("Failed to store DID update: %v", err)
+		http.Error(w, "Failed to update DID", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Failed to commit DID update: %v", err)
+		http.Error(w, "Failed to update DID", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	// A rotated or retired verification method must no longer sign
+	if update.Action == keyActionRotate || update.Action == keyActionRetire {
+		retireKey(ctx, updated, did+"#"+keyFragment(update.KeyID))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(didDocJSON)
+	log.Printf("DID %s updated to version %d (%s %s)", did, version, update.Action, update.KeyID)
+}
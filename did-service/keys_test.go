@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"github.com/bradtumy/credential-service/keystore"
+)
+
+func newTestDIDWebDocument(t *testing.T) DIDDocument {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	return newDIDWebDocument("did:web:example.com", encodeMultibaseEd25519(publicKey))
+}
+
+func TestApplyKeyUpdateAdd(t *testing.T) {
+	didDocument := newTestDIDWebDocument(t)
+	publicKeyMultibase := newTestKey(t, keyTypeEd25519)
+
+	updated, newKeyID, err := applyKeyUpdate(didDocument, 2, KeyUpdateRequest{Action: keyActionAdd}, publicKeyMultibase)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if newKeyID != "did:web:example.com#keys-2" || len(updated.VerificationMethod) != 2 {
+		t.Fatalf("Expected keys-2 to be added, got %s %+v", newKeyID, updated.VerificationMethod)
+	}
+	// The original key stays the first assertion key
+	if updated.AssertionMethod[0] != "did:web:example.com#keys-1" || updated.AssertionMethod[1] != newKeyID {
+		t.Errorf("Unexpected assertion methods %v", updated.AssertionMethod)
+	}
+	if len(didDocument.VerificationMethod) != 1 {
+		t.Error("Expected the original document to be left unchanged")
+	}
+}
+
+func TestApplyKeyUpdateRotate(t *testing.T) {
+	didDocument := newTestDIDWebDocument(t)
+	publicKeyMultibase := newTestKey(t, keyTypeP256)
+
+	updated, newKeyID, err := applyKeyUpdate(didDocument, 3, KeyUpdateRequest{Action: keyActionRotate, KeyID: "#keys-1"}, publicKeyMultibase)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if newKeyID != "did:web:example.com#keys-3" {
+		t.Errorf("Expected keys-3, got %s", newKeyID)
+	}
+	if len(updated.VerificationMethod) != 1 || updated.VerificationMethod[0].ID != newKeyID || updated.VerificationMethod[0].PublicKeyMultibase != publicKeyMultibase {
+		t.Errorf("Expected keys-1 to be replaced, got %+v", updated.VerificationMethod)
+	}
+	if updated.AssertionMethod[0] != newKeyID || updated.Authentication[0] != newKeyID {
+		t.Errorf("Expected the rotated key to take over every relationship, got %v %v", updated.AssertionMethod, updated.Authentication)
+	}
+	if didDocument.AssertionMethod[0] != "did:web:example.com#keys-1" {
+		t.Error("Expected the original document to be left unchanged")
+	}
+}
+
+func TestApplyKeyUpdateRetire(t *testing.T) {
+	didDocument := newTestDIDWebDocument(t)
+	publicKeyMultibase := newTestKey(t, keyTypeEd25519)
+
+	if _, _, err := applyKeyUpdate(didDocument, 2, KeyUpdateRequest{Action: keyActionRetire, KeyID: "keys-1"}, ""); !errors.Is(err, errLastAssertionKey) {
+		t.Errorf("Expected the only assertion key not to be retired, got %v", err)
+	}
+	if _, _, err := applyKeyUpdate(didDocument, 2, KeyUpdateRequest{Action: keyActionRetire, KeyID: "#keys-9"}, ""); !errors.Is(err, errKeyNotFound) {
+		t.Errorf("Expected an unknown key to be reported, got %v", err)
+	}
+
+	added, _, err := applyKeyUpdate(didDocument, 2, KeyUpdateRequest{Action: keyActionAdd}, publicKeyMultibase)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	retired, newKeyID, err := applyKeyUpdate(added, 3, KeyUpdateRequest{Action: keyActionRetire, KeyID: "did:web:example.com#keys-1"}, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if newKeyID != "" || len(retired.VerificationMethod) != 1 || retired.AssertionMethod[0] != "did:web:example.com#keys-2" || len(retired.Authentication) != 1 {
+		t.Errorf("Expected only keys-2 to remain, got %+v", retired)
+	}
+}
+
+func TestRetireKey(t *testing.T) {
+	ctx := context.Background()
+	keyStore = keystore.NewMemory()
+	key, publicKeyMultibase, err := createKey(ctx, keyTypeEd25519)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	didDocument := newDIDWebDocument("did:web:example.com", publicKeyMultibase)
+	keyID := didDocument.VerificationMethod[0].ID
+	if err := keyStore.Assign(ctx, key, keyID, "did:web:example.com#keys-2"); err != nil {
+		t.Fatalf("Failed to assign key: %v", err)
+	}
+
+	// A rotated key keeps signing for the verification method that still uses it
+	rotated := didDocument
+	rotated.VerificationMethod = []VerificationMethod{{ID: "did:web:example.com#keys-2", Type: "Multikey", PublicKeyMultibase: publicKeyMultibase}}
+	retireKey(ctx, rotated, keyID)
+	if _, err := keyStore.Sign(ctx, keyID, []byte("data")); !errors.Is(err, keystore.ErrKeyNotFound) {
+		t.Errorf("Expected the retired key ID not to sign, got %v", err)
+	}
+	if _, err := keyStore.Sign(ctx, "did:web:example.com#keys-2", []byte("data")); err != nil {
+		t.Errorf("Expected the remaining key ID to sign, got %v", err)
+	}
+
+	// A key no verification method uses any more is destroyed
+	retireKey(ctx, DIDDocument{ID: "did:web:example.com"}, "did:web:example.com#keys-2")
+	if _, err := keyStore.Sign(ctx, "did:web:example.com#keys-2", []byte("data")); err == nil {
+		t.Error("Expected the retired key not to sign")
+	}
+	if err := keyStore.Assign(ctx, key, keyID); err == nil {
+		t.Error("Expected the retired key to be destroyed")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/bradtumy/credential-service/keystore"
+	"github.com/mr-tron/base58"
+)
+
+// Key types a DID can be created with. Ed25519 is the default.
+const (
+	keyTypeEd25519    = keystore.Ed25519
+	keyTypeP256       = keystore.P256
+	keyTypeSecp256k1  = keystore.Secp256k1
+	keyTypeBLS12381G2 = keystore.BLS12381G2
+)
+
+// keyTypeMulticodecs maps each key type to the varint encoded multicodec prefix of its public key
+var keyTypeMulticodecs = map[string][]byte{
+	keyTypeEd25519:    ed25519PublicKeyMulticodec, // ed25519-pub (0xed)
+	keyTypeP256:       {0x80, 0x24},               // p256-pub (0x1200)
+	keyTypeSecp256k1:  {0xe7, 0x01},               // secp256k1-pub (0xe7)
+	keyTypeBLS12381G2: {0xeb, 0x01},               // bls12_381-g2-pub (0xeb)
+}
+
+// keyStore holds the private keys of all verification methods, see keystore.NewFromEnv
+var keyStore keystore.KeyStore
+
+// encodeMultibaseKey returns the base58btc multibase encoding of a multicodec prefixed public key
+func encodeMultibaseKey(keyType string, publicKey []byte) string {
+	prefixed := append(append([]byte{}, keyTypeMulticodecs[keyType]...), publicKey...)
+	return multibaseBase58BTC + base58.Encode(prefixed)
+}
+
+// createKey creates a key of the given type in the key store, or an Ed25519 key when keyType is
+// empty, and returns it along with its public key in multibase form. The key must be assigned
+// to its verification methods once their ids are known, or discarded.
+func createKey(ctx context.Context, keyType string) (keystore.Key, string, error) {
+	if keyType == "" {
+		keyType = keyTypeEd25519
+	}
+	key, err := keyStore.Create(ctx, keyType)
+	if err != nil {
+		return keystore.Key{}, "", err
+	}
+	return key, encodeMultibaseKey(key.Type, key.PublicKey), nil
+}
+
+// discardKey destroys a key that ended up unused, e.g. because its DID already exists
+func discardKey(ctx context.Context, key keystore.Key) {
+	if err := keyStore.Discard(ctx, key); err != nil {
+		log.Printf("Failed to discard unused key %s: %v", key.Name, err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bradtumy/credential-service/keystore"
+	"github.com/mr-tron/base58"
+)
+
+// newTestKey creates a key in an in-memory key store and returns its public key in multibase form
+func newTestKey(t *testing.T, keyType string) string {
+	t.Helper()
+	keyStore = keystore.NewMemory()
+	_, publicKeyMultibase, err := createKey(context.Background(), keyType)
+	if err != nil {
+		t.Fatalf("Failed to create %s key: %v", keyType, err)
+	}
+	return publicKeyMultibase
+}
+
+func TestCreateKey(t *testing.T) {
+	tests := []struct {
+		keyType         string
+		didKeyPrefix    string
+		publicKeyLength int
+	}{
+		{keyType: "", didKeyPrefix: "did:key:z6Mk", publicKeyLength: 32},
+		{keyType: keyTypeEd25519, didKeyPrefix: "did:key:z6Mk", publicKeyLength: 32},
+		{keyType: keyTypeP256, didKeyPrefix: "did:key:zDn", publicKeyLength: 33},
+		{keyType: keyTypeSecp256k1, didKeyPrefix: "did:key:zQ3s", publicKeyLength: 33},
+		{keyType: keyTypeBLS12381G2, didKeyPrefix: "did:key:zUC7", publicKeyLength: 96},
+	}
+
+	for _, tt := range tests {
+		publicKeyMultibase := newTestKey(t, tt.keyType)
+
+		didDocument := newDIDKeyDocument(publicKeyMultibase)
+		if !strings.HasPrefix(didDocument.ID, tt.didKeyPrefix) {
+			t.Errorf("%s: expected a DID starting with %s, got %s", tt.keyType, tt.didKeyPrefix, didDocument.ID)
+		}
+
+		keyType := tt.keyType
+		if keyType == "" {
+			keyType = keyTypeEd25519
+		}
+		decoded, err := base58.Decode(strings.TrimPrefix(publicKeyMultibase, "z"))
+		multicodec := keyTypeMulticodecs[keyType]
+		if err != nil || !bytes.HasPrefix(decoded, multicodec) || len(decoded)-len(multicodec) != tt.publicKeyLength {
+			t.Errorf("%s: unexpected public key %s", tt.keyType, publicKeyMultibase)
+		}
+	}
+
+	if _, _, err := createKey(context.Background(), "RSA"); err == nil {
+		t.Error("Expected an unsupported key type to be rejected")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// organizationHeader carries the organization of the caller, set by the gateway in front of the
+// service. Listing and reading DIDs requires it, and only shows that organization's DIDs.
+const organizationHeader = "X-Organization-ID"
+
+// Page sizes of GET /v1/dids
+const (
+	defaultDIDPageSize = 50
+	maxDIDPageSize     = 200
+)
+
+// keyTypeMultibasePrefixes are the leading characters every multibase public key of a key type
+// starts with, used to filter DIDs by key type without decoding their documents
+var keyTypeMultibasePrefixes = map[string]string{
+	keyTypeEd25519:    "z6Mk",
+	keyTypeP256:       "zDn",
+	keyTypeSecp256k1:  "zQ3s",
+	keyTypeBLS12381G2: "zUC7",
+}
+
+var (
+	errMissingOrganization   = errors.New("missing " + organizationHeader + " header")
+	errInvalidDIDFilter      = errors.New("invalid DID filter")
+	errForbiddenOrganization = errors.New("organization_id does not match the caller's organization")
+)
+
+// didListFilter selects a page of DIDs for GET /v1/dids
+type didListFilter struct {
+	OrganizationID string
+	HolderID       string
+	Method         string // "key", "web" or "peer"
+	KeyType        string
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	Limit          int
+	After          int64 // Row id the previous page ended with
+}
+
+// DIDList is a page of DID documents. NextCursor is empty on the last page.
+type DIDList struct {
+	DIDs       []json.RawMessage `json:"dids"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+}
+
+// parseDIDListFilter reads the filter from the query parameters of a request, scoped to the
+// caller's organization, which the request must name in its X-Organization-ID header
+func parseDIDListFilter(r *http.Request) (didListFilter, error) {
+	query := r.URL.Query()
+	filter := didListFilter{
+		OrganizationID: query.Get("organization_id"),
+		HolderID:       query.Get("holder_id"),
+		Method:         query.Get("method"),
+		KeyType:        query.Get("keyType"),
+		Limit:          defaultDIDPageSize,
+	}
+
+	organizationID := r.Header.Get(organizationHeader)
+	if organizationID == "" {
+		return didListFilter{}, errMissingOrganization
+	}
+	if filter.OrganizationID != "" && filter.OrganizationID != organizationID {
+		return didListFilter{}, errForbiddenOrganization
+	}
+	filter.OrganizationID = organizationID
+
+	switch filter.Method {
+	case "", "key", "web", "peer":
+	default:
+		return didListFilter{}, fmt.Errorf("%w: unknown method %q", errInvalidDIDFilter, filter.Method)
+	}
+	if _, ok := keyTypeMultibasePrefixes[filter.KeyType]; filter.KeyType != "" && !ok {
+		return didListFilter{}, fmt.Errorf("%w: unknown keyType %q", errInvalidDIDFilter, filter.KeyType)
+	}
+
+	for name, target := range map[string]*time.Time{"createdAfter": &filter.CreatedAfter, "createdBefore": &filter.CreatedBefore} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return didListFilter{}, fmt.Errorf("%w: %s must be an RFC 3339 timestamp", errInvalidDIDFilter, name)
+			}
+			*target = parsed
+		}
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxDIDPageSize {
+			return didListFilter{}, fmt.Errorf("%w: limit must be between 1 and %d", errInvalidDIDFilter, maxDIDPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := decodeDIDCursor(cursor)
+		if err != nil {
+			return didListFilter{}, err
+		}
+		filter.After = after
+	}
+
+	return filter, nil
+}
+
+// sql returns the query selecting the filtered page in creation (row id) order. One row more than the
+// page size is selected to tell whether another page follows.
+func (f didListFilter) sql() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.After > 0 {
+		where("id > $%d", f.After)
+	}
+	if f.OrganizationID != "" {
+		where("organization_id = $%d", f.OrganizationID)
+	}
+	if f.HolderID != "" {
+		where("document->>'holder_id' = $%d", f.HolderID)
+	}
+	if f.Method != "" {
+		where("did LIKE $%d", "did:"+f.Method+":%")
+	}
+	if f.KeyType != "" {
+		where("EXISTS (SELECT 1 FROM jsonb_array_elements(document->'verificationMethod') AS method WHERE method->>'publicKeyMultibase' LIKE $%d)",
+			keyTypeMultibasePrefixes[f.KeyType]+"%")
+	}
+	if !f.CreatedAfter.IsZero() {
+		where("created_at >= $%d", f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		where("created_at < $%d", f.CreatedBefore)
+	}
+
+	query := "SELECT id, document FROM dids"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, f.Limit+1)
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args))
+	return query, args
+}
+
+// encodeDIDCursor returns the opaque cursor of the page following the row with the given id
+func encodeDIDCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeDIDCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid cursor", errInvalidDIDFilter)
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("%w: invalid cursor", errInvalidDIDFilter)
+	}
+	return id, nil
+}
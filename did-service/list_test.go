@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradtumy/credential-service/keystore"
+	"github.com/gorilla/mux"
+)
+
+func TestKeyTypeMultibasePrefixes(t *testing.T) {
+	for keyType, prefix := range keyTypeMultibasePrefixes {
+		publicKeyMultibase := newTestKey(t, keyType)
+		if !strings.HasPrefix(publicKeyMultibase, prefix) {
+			t.Errorf("Expected %s keys to start with %s, got %s", keyType, prefix, publicKeyMultibase)
+		}
+	}
+}
+
+func TestParseDIDListFilter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/dids?holder_id=holder1&method=web&keyType=P-256&createdAfter=2024-01-01T00:00:00Z&limit=10&cursor="+encodeDIDCursor(42), nil)
+	r.Header.Set(organizationHeader, "org123")
+
+	filter, err := parseDIDListFilter(r)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if filter.OrganizationID != "org123" || filter.HolderID != "holder1" || filter.Method != "web" || filter.KeyType != keyTypeP256 ||
+		filter.CreatedAfter.Year() != 2024 || !filter.CreatedBefore.IsZero() || filter.Limit != 10 || filter.After != 42 {
+		t.Errorf("Unexpected filter %+v", filter)
+	}
+
+	query, args := filter.sql()
+	expected := "SELECT id, document FROM dids WHERE id > $1 AND organization_id = $2 AND document->>'holder_id' = $3 AND did LIKE $4 AND " +
+		"EXISTS (SELECT 1 FROM jsonb_array_elements(document->'verificationMethod') AS method WHERE method->>'publicKeyMultibase' LIKE $5) AND " +
+		"created_at >= $6 ORDER BY id LIMIT $7"
+	if query != expected {
+		t.Errorf("Unexpected query %s", query)
+	}
+	if len(args) != 7 || args[3] != "did:web:%" || args[4] != "zDn%" || args[6] != 11 {
+		t.Errorf("Unexpected arguments %v", args)
+	}
+
+	// Without filters every DID of the caller's organization is listed, a page at a time
+	r = httptest.NewRequest("GET", "/v1/dids", nil)
+	r.Header.Set(organizationHeader, "org123")
+	filter, err = parseDIDListFilter(r)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if query, args := filter.sql(); query != "SELECT id, document FROM dids WHERE organization_id = $1 ORDER BY id LIMIT $2" || args[1] != defaultDIDPageSize+1 {
+		t.Errorf("Unexpected query %s %v", query, args)
+	}
+}
+
+func TestParseDIDListFilterInvalid(t *testing.T) {
+	for _, query := range []string{"method=btcr", "keyType=RSA", "createdBefore=yesterday", "limit=0", "limit=1000", "cursor=not-a-cursor"} {
+		r := httptest.NewRequest("GET", "/v1/dids?"+query, nil)
+		r.Header.Set(organizationHeader, "org123")
+		if _, err := parseDIDListFilter(r); !errors.Is(err, errInvalidDIDFilter) {
+			t.Errorf("%s: expected an invalid filter error, got %v", query, err)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/v1/dids?organization_id=org456", nil)
+	r.Header.Set(organizationHeader, "org123")
+	if _, err := parseDIDListFilter(r); !errors.Is(err, errForbiddenOrganization) {
+		t.Errorf("Expected another organization's DIDs to be forbidden, got %v", err)
+	}
+}
+
+func TestDIDsRequireOrganization(t *testing.T) {
+	if _, err := parseDIDListFilter(httptest.NewRequest("GET", "/v1/dids?organization_id=org123", nil)); !errors.Is(err, errMissingOrganization) {
+		t.Errorf("Expected listing without %s to fail, got %v", organizationHeader, err)
+	}
+
+	// Reading and changing DIDs need the caller's organization, checked before the database
+	for _, handler := range []http.HandlerFunc{getDIDs, getDID, updateDIDDocument, updateDIDKeys, deactivateDID, createDomainLinkage} {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest("GET", "/v1/dids/did:web:example.com", strings.NewReader(`{"action": "add"}`)))
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status %d without %s, got %d", http.StatusUnauthorized, organizationHeader, rr.Code)
+		}
+	}
+	// DIDs, holder DIDs included, are created for the caller's organization
+	for payload, status := range map[string]int{
+		`{"type": "holder", "holder_id": "holder1"}`:                              http.StatusBadRequest,
+		`{"type": "holder", "holder_id": "holder1", "organization_id": "org456"}`: http.StatusForbidden,
+	} {
+		r := httptest.NewRequest("POST", "/v1/dids", strings.NewReader(payload))
+		if status == http.StatusForbidden {
+			r.Header.Set(organizationHeader, "org123")
+		}
+		rr := httptest.NewRecorder()
+		createDID(rr, r)
+		if rr.Code != status {
+			t.Errorf("%s: expected status %d, got %d", payload, status, rr.Code)
+		}
+	}
+}
+
+func TestListHolderDIDs(t *testing.T) {
+	if dbClosed {
+		t.Fatal("Database connection pool is closed")
+	}
+	keyStore = keystore.NewMemory()
+
+	// A holder DID belongs to the organization that created it
+	holderID := "holder-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	r := httptest.NewRequest("POST", "/v1/dids", strings.NewReader(`{"type": "holder", "holder_id": "`+holderID+`", "method": "peer", "numalgo": 0}`))
+	r.Header.Set(organizationHeader, "org123")
+	rr := httptest.NewRecorder()
+	createDID(rr, r)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", rr.Code, rr.Body)
+	}
+	var created DIDDocument
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to decode DID document: %v", err)
+	}
+
+	r = httptest.NewRequest("GET", "/v1/dids?holder_id="+holderID, nil)
+	r.Header.Set(organizationHeader, "org123")
+	rr = httptest.NewRecorder()
+	getDIDs(rr, r)
+	var list DIDList
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("Failed to list DIDs: %d %s", rr.Code, rr.Body)
+	}
+	if len(list.DIDs) != 1 || !strings.Contains(string(list.DIDs[0]), created.ID) {
+		t.Errorf("Expected the holder's DID %s, got %s", created.ID, list.DIDs)
+	}
+
+	for organizationID, status := range map[string]int{"org123": http.StatusOK, "org456": http.StatusNotFound} {
+		r = mux.SetURLVars(httptest.NewRequest("GET", "/v1/dids/"+created.ID, nil), map[string]string{"did": created.ID})
+		r.Header.Set(organizationHeader, organizationID)
+		rr = httptest.NewRecorder()
+		getDID(rr, r)
+		if rr.Code != status {
+			t.Errorf("Expected status %d reading the holder DID as %s, got %d", status, organizationID, rr.Code)
+		}
+	}
+}
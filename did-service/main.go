@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
 	"fmt"
 	"net/http"
 
+	"github.com/bradtumy/credential-service/keystore"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
 )
@@ -20,6 +22,22 @@ func main() {
 	// Initialize the database connection
 	initDB()
 
+	// Open the key store holding the private keys of verification methods
+	var err error
+	keyStore, err = keystore.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to open key store: %v", err)
+	}
+
+	// Rewrite DIDs minted with the old did:key encoding when requested
+	if os.Getenv("MIGRATE_LEGACY_DIDS") == "true" {
+		migrated, err := migrateLegacyDIDs(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to migrate legacy DIDs: %v", err)
+		}
+		log.Printf("Migrated %d legacy DIDs", migrated)
+	}
+
 	// Set up routes
 	routes := InitializeRoutes()
 
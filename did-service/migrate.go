@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migrateLegacyDIDs rewrites DIDs minted with the old "did:key:z6M" + base64url encoding into
+// spec-compliant did:key identifiers. The public key is recovered from the legacy identifier,
+// the row is updated with the new DID and DID Document, the old identifier is kept in
+// legacy_did, and the private key is imported into the key store for the new DID. The legacy
+// Vault secret is left in place so operators can remove it once the migration has been verified.
+func migrateLegacyDIDs(ctx context.Context) (int, error) {
+	rows, err := db.Query(ctx, "SELECT did, document FROM dids WHERE did LIKE $1 AND legacy_did IS NULL", legacyDIDKeyPrefix+"%")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query DIDs: %w", err)
+	}
+
+	type legacyDID struct {
+		did      string
+		document DIDDocument
+	}
+
+	var candidates []legacyDID
+	for rows.Next() {
+		var did string
+		var document map[string]interface{}
+		if err := rows.Scan(&did, &document); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan DID: %w", err)
+		}
+		if _, ok := legacyPublicKeyFromDIDKey(did); !ok {
+			continue
+		}
+
+		// Keep the creation time and owner from the legacy document
+		candidate := legacyDID{did: did}
+		candidate.document.CreatedAt, _ = document["createdAt"].(string)
+		candidate.document.OrganizationID, _ = document["organization_id"].(string)
+		candidate.document.HolderID, _ = document["holder_id"].(string)
+		candidates = append(candidates, candidate)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read DIDs: %w", err)
+	}
+
+	migrated := 0
+	for _, candidate := range candidates {
+		publicKey, _ := legacyPublicKeyFromDIDKey(candidate.did)
+
+		didDocument := newDIDKeyDocument(encodeMultibaseEd25519(publicKey))
+		didDocument.CreatedAt = candidate.document.CreatedAt
+		didDocument.OrganizationID = candidate.document.OrganizationID
+		didDocument.HolderID = candidate.document.HolderID
+		if didDocument.CreatedAt == "" {
+			didDocument.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+		}
+
+		publicKeyJSON, err := json.Marshal(didDocument.VerificationMethod)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to marshal public key for %s: %w", candidate.did, err)
+		}
+		didDocJSON, err := json.Marshal(didDocument)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to marshal DID document for %s: %w", candidate.did, err)
+		}
+
+		// Copy the private key first so the new DID is never stored without its key
+		privateKeyBase64, err := getPrivateKeyFromVault(candidate.did)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to read private key for %s: %w", candidate.did, err)
+		}
+		privateKey, err := base64.StdEncoding.DecodeString(privateKeyBase64)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to decode private key for %s: %w", candidate.did, err)
+		}
+		key, err := keyStore.Import(ctx, keyTypeEd25519, privateKey)
+		if err == nil {
+			err = keyStore.Assign(ctx, key, verificationMethodIDs(didDocument)...)
+		}
+		if err != nil {
+			return migrated, fmt.Errorf("failed to copy private key for %s: %w", candidate.did, err)
+		}
+
+		query := "UPDATE dids SET did = $1, legacy_did = $2, public_key = $3, document = $4 WHERE did = $2"
+		if _, err := db.Exec(ctx, query, didDocument.ID, candidate.did, publicKeyJSON, didDocJSON); err != nil {
+			return migrated, fmt.Errorf("failed to update DID %s: %w", candidate.did, err)
+		}
+		// The re-encoded document starts the new DID's version history
+		historyQuery := "INSERT INTO did_document_versions (did, version_id, document, created_at) SELECT did, version_id, document, created_at FROM dids WHERE did = $1 ON CONFLICT DO NOTHING"
+		if _, err := db.Exec(ctx, historyQuery, didDocument.ID); err != nil {
+			return migrated, fmt.Errorf("failed to store version history of %s: %w", didDocument.ID, err)
+		}
+
+		log.Printf("Migrated legacy DID %s to %s", candidate.did, didDocument.ID)
+		migrated++
+	}
+
+	return migrated, nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -18,14 +19,33 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// didFromPath returns the {did} of a request's path. DIDs are percent-encoded in paths, so a
+// did:web DID with a port ("%3A") arrives as "%253A".
+func didFromPath(r *http.Request) (string, error) {
+	return url.PathUnescape(mux.Vars(r)["did"])
+}
+
 func InitializeRoutes() *mux.Router {
 
-	r := mux.NewRouter()
+	// Match the encoded path, so a DID with an encoded character stays a single {did}
+	r := mux.NewRouter().UseEncodedPath()
 
 	// Version 1 routes
 	v1 := r.PathPrefix("/v1").Subrouter()
 	v1.Handle("/dids", LoggingMiddleware(http.HandlerFunc(createDID))).Methods("POST")
 	v1.Handle("/dids", LoggingMiddleware(http.HandlerFunc(getDIDs))).Methods("GET")
+	v1.Handle("/dids/{did}", LoggingMiddleware(http.HandlerFunc(getDID))).Methods("GET")
+	v1.Handle("/dids/{did}", LoggingMiddleware(http.HandlerFunc(updateDIDDocument))).Methods("PATCH")
+	v1.Handle("/dids/{did}/keys", LoggingMiddleware(http.HandlerFunc(updateDIDKeys))).Methods("PUT")
+	v1.Handle("/dids/{did}/domain-linkage", LoggingMiddleware(http.HandlerFunc(createDomainLinkage))).Methods("POST")
+	v1.Handle("/dids/{did}/deactivate", LoggingMiddleware(http.HandlerFunc(deactivateDID))).Methods("POST")
+
+	// did:web documents are served from the DID's domain: /.well-known/did.json for a bare
+	// domain, /<path>/did.json for DIDs with path segments. Domain Linkage Credentials are
+	// served the same way from /.well-known/did-configuration.json.
+	r.Handle("/.well-known/did-configuration.json", LoggingMiddleware(http.HandlerFunc(serveDIDConfiguration))).Methods("GET")
+	r.Handle("/.well-known/did.json", LoggingMiddleware(http.HandlerFunc(serveDIDWebDocument))).Methods("GET")
+	r.Handle("/{path:.+}/did.json", LoggingMiddleware(http.HandlerFunc(serveDIDWebDocument))).Methods("GET")
 
 	// Version 2 routes
 	// whent he time comes put the v2 routes here.
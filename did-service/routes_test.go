@@ -3,7 +3,10 @@ package main
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+
+	"github.com/gorilla/mux"
 )
 
 func TestInitializeRoutes(t *testing.T) {
@@ -19,3 +22,28 @@ func TestInitializeRoutes(t *testing.T) {
 		t.Errorf("Expected status OK, got %d", rr.Code)
 	}
 }
+
+func TestRoutesDecodeDID(t *testing.T) {
+	router := InitializeRoutes()
+
+	// A did:web DID with a port is percent-encoded once more in the path, as url.PathEscape does
+	did := "did:web:example.com%3A8443:issuers:hr"
+	for _, route := range []struct{ method, path string }{
+		{"GET", "/v1/dids/" + url.PathEscape(did)},
+		{"PATCH", "/v1/dids/" + url.PathEscape(did)},
+		{"PUT", "/v1/dids/" + url.PathEscape(did) + "/keys"},
+		{"POST", "/v1/dids/" + url.PathEscape(did) + "/domain-linkage"},
+		{"POST", "/v1/dids/" + url.PathEscape(did) + "/deactivate"},
+	} {
+		req := httptest.NewRequest(route.method, route.path, nil)
+		var match mux.RouteMatch
+		if !router.Match(req, &match) {
+			t.Errorf("%s %s: expected a route to match", route.method, route.path)
+			continue
+		}
+		got, err := didFromPath(mux.SetURLVars(req, match.Vars))
+		if err != nil || got != did {
+			t.Errorf("%s %s: expected DID %s, got %s (%v)", route.method, route.path, did, got, err)
+		}
+	}
+}
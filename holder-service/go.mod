@@ -3,13 +3,17 @@ module github.com/bradtumy/credential-service/holder-service
 go 1.21
 
 require (
+	github.com/bradtumy/credential-service/dataintegrity v0.0.0-00010101000000-000000000000
+	github.com/bradtumy/credential-service/keystore v0.0.0-00010101000000-000000000000
 	github.com/gorilla/mux v1.8.1
-	github.com/hashicorp/vault/api v1.15.0
 	github.com/joho/godotenv v1.5.1
+	github.com/mr-tron/base58 v1.2.0
 )
 
 require (
 	github.com/cenkalti/backoff/v4 v4.3.0 // indirect
+	github.com/cloudflare/circl v1.3.8 // indirect
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0 // indirect
 	github.com/go-jose/go-jose/v4 v4.0.1 // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
@@ -20,11 +24,17 @@ require (
 	github.com/hashicorp/go-secure-stdlib/strutil v0.1.2 // indirect
 	github.com/hashicorp/go-sockaddr v1.0.2 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
+	github.com/hashicorp/vault/api v1.15.0 // indirect
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	github.com/ryanuber/go-glob v1.0.0 // indirect
 	golang.org/x/crypto v0.23.0 // indirect
 	golang.org/x/net v0.25.0 // indirect
+	golang.org/x/sys v0.20.0 // indirect
 	golang.org/x/text v0.15.0 // indirect
 	golang.org/x/time v0.0.0-20200416051211-89c76fbcd5d1 // indirect
 )
+
+replace github.com/bradtumy/credential-service/keystore => ../keystore
+
+replace github.com/bradtumy/credential-service/dataintegrity => ../dataintegrity
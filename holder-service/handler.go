@@ -2,30 +2,73 @@ package main
 
 import (
 	"bytes"
-	"crypto/ed25519"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
 	"net/http"
 )
 
-// VerifiableCredential structure aligned with W3C
+// VerifiableCredential is a credential the holder received. Only the fields the holder works with
+// are decoded; the credential is stored and presented as the JSON it was received as, which is
+// what its proof covers, so an object issuer or claims not modelled here are kept.
 type VerifiableCredential struct {
-	Context           []string          `json:"@context"`
-	Type              []string          `json:"type"`
-	ID                string            `json:"id"`
-	Issuer            string            `json:"issuer"`
-	IssuanceDate      string            `json:"issuanceDate"`
-	ExpirationDate    string            `json:"expirationDate"`
-	CredentialSubject map[string]string `json:"credentialSubject"`
-	Proof             Proof             `json:"proof,omitempty"`
+	Context []interface{} // Base context first, the others may be objects
+	ID      string
+	raw     json.RawMessage
+}
+
+// UnmarshalJSON decodes the @context and id of a credential and keeps the credential as received
+func (vc *VerifiableCredential) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		Context []interface{} `json:"@context"`
+		ID      string        `json:"id"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	vc.Context, vc.ID = fields.Context, fields.ID
+	vc.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON encodes the credential as it was received
+func (vc VerifiableCredential) MarshalJSON() ([]byte, error) {
+	if vc.raw == nil {
+		return nil, errors.New("credential was not received as JSON")
+	}
+	return vc.raw, nil
+}
+
+// Base contexts of the W3C Verifiable Credentials Data Model 1.1 and 2.0
+const (
+	credentialsContextV1 = "https://www.w3.org/2018/credentials/v1"
+	credentialsContextV2 = "https://www.w3.org/ns/credentials/v2"
+)
+
+// isDataModelV2 reports whether a credential is in the 2.0 data model
+func (vc VerifiableCredential) isDataModelV2() bool {
+	return len(vc.Context) > 0 && vc.Context[0] == credentialsContextV2
+}
+
+// presentationContext returns the context of a presentation of the given credentials: the 2.0
+// base context when every credential is a 2.0 credential, the 1.1 one otherwise
+func presentationContext(credentials []VerifiableCredential) []string {
+	if len(credentials) == 0 {
+		return []string{credentialsContextV1}
+	}
+	for _, vc := range credentials {
+		if !vc.isDataModelV2() {
+			return []string{credentialsContextV1}
+		}
+	}
+	return []string{credentialsContextV2}
 }
 
 // Proof structure for digital signature
 type Proof struct {
 	Type               string `json:"type"`
+	Cryptosuite        string `json:"cryptosuite,omitempty"`
 	Created            string `json:"created"`
 	ProofValue         string `json:"proofValue"`
 	ProofPurpose       string `json:"proofPurpose"`
@@ -35,6 +78,11 @@ type Proof struct {
 type PresentationRequest struct {
 	HolderDID string   `json:"holderDid"`
 	VCIDs     []string `json:"vcIds"`
+
+	// Verifier the presentation is meant for, e.g. its DID or origin. A request naming one is
+	// signed with the holder's pairwise DID for it instead of holderDid.
+	Verifier string `json:"verifier,omitempty"`
+	HolderID string `json:"holderId,omitempty"`
 }
 
 // VerifiablePresentation represents a verifiable presentation
@@ -52,12 +100,15 @@ func ReceiveCredential(w http.ResponseWriter, r *http.Request) {
 	var vc VerifiableCredential
 
 	err := json.NewDecoder(r.Body).Decode(&vc)
-	if err != nil {
+	if err != nil || !bytes.HasPrefix(bytes.TrimSpace(vc.raw), []byte("{")) {
 		http.Error(w, "Invalid credential format", http.StatusBadRequest)
 		return
 	}
-	// Debug:
-	log.Println("Debug: VC: ", vc)
+
+	if len(vc.Context) == 0 || (vc.Context[0] != credentialsContextV1 && vc.Context[0] != credentialsContextV2) {
+		http.Error(w, "Unsupported credential data model", http.StatusBadRequest)
+		return
+	}
 
 	// Store the credential in memory (for now)
 	StoreCredential(vc)
@@ -74,19 +125,28 @@ func PresentCredential(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	holderDID, err := presentationHolder(req)
+	if err != nil {
+		writePresentationHolderError(w, err)
+		return
+	}
 
 	credentials := GetStoredCredentials()
 
 	// Create the Verifiable Presentation
 	presentation := VerifiablePresentation{
-		Context:              []string{"https://www.w3.org/2018/credentials/v1"},
+		Context:              presentationContext(credentials),
 		Type:                 []string{"VerifiablePresentation"},
-		Holder:               req.HolderDID,
+		Holder:               holderDID,
 		VerifiableCredential: credentials,
 	}
 
 	// Sign the presentation
-	if err := SignPresentation(&presentation, req.HolderDID); err != nil {
+	if err := SignPresentation(&presentation, holderDID); err != nil {
+		if errors.Is(err, errDIDDeactivated) {
+			http.Error(w, "Holder DID is deactivated", http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to sign presentation", http.StatusInternalServerError)
 		return
 	}
@@ -117,21 +177,30 @@ func CreateAndSignPresentation(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	holderDID, err := presentationHolder(req)
+	if err != nil {
+		writePresentationHolderError(w, err)
+		return
+	}
 
 	// Retrieve credentials using the VC IDs
 	credentials := GetStoredCredentialsByIDs(req.VCIDs)
 
 	// Create the Verifiable Presentation
 	presentation := VerifiablePresentation{
-		Context:              []string{"https://www.w3.org/2018/credentials/v1"},
+		Context:              presentationContext(credentials),
 		Type:                 []string{"VerifiablePresentation"},
-		Holder:               req.HolderDID,
+		Holder:               holderDID,
 		VerifiableCredential: credentials,
 	}
 
 	// Sign the presentation using the holder's private key from HashiCorp Vault
-	err := SignPresentation(&presentation, req.HolderDID)
+	err = SignPresentation(&presentation, holderDID)
 	if err != nil {
+		if errors.Is(err, errDIDDeactivated) {
+			http.Error(w, "Holder DID is deactivated", http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to sign presentation", http.StatusInternalServerError)
 		return
 	}
@@ -170,29 +239,26 @@ func GetStoredCredentialsByIDs(vcIDs []string) []VerifiableCredential {
 // SignPresentation signs a Verifiable Presentation and returns the signed presentation
 func SignPresentation(presentation *VerifiablePresentation, holderDID string) error {
 
-	// Fetch the private key from HashiCorp Vault (pseudo-code, implement actual retrieval)
-	privateKey, err := fetchPrivateKeyFromVault(holderDID)
+	// Sign with the key the holder's DID document currently declares for authentication
+	didDocument, err := resolveDIDDocument(holderDID)
+	if errors.Is(err, errDIDDeactivated) {
+		return err
+	}
 	if err != nil {
-		log.Println("failed to fetch private key: ", err)
-		return errors.New("failed to fetch private key")
+		log.Println("failed to resolve holder DID: ", err)
+		return errors.New("failed to resolve holder DID")
 	}
-
-	// Prepare the data to be signed
-	dataToSign, err := json.Marshal(presentation)
+	verificationMethod, err := authenticationMethodID(didDocument)
 	if err != nil {
-		return errors.New("failed to marshal presentation for signing")
+		log.Println("failed to select verification method: ", err)
+		return err
 	}
 
-	// Sign the data using Ed25519
-	signature := ed25519.Sign(privateKey, dataToSign)
-
-	// Construct the proof
-	proof := Proof{
-		Type:               "EcdsaSignature2019",                         // Adjust type based on your requirements
-		Created:            "2024-09-29T20:41:23Z",                       // Use current time in production
-		ProofValue:         base64.StdEncoding.EncodeToString(signature), // Base64 encoding of signature
-		VerificationMethod: holderDID + "#keys-1",                        // Example of using a key ID, adjust as needed
-		ProofPurpose:       "assertionMethod",
+	// Sign through the key store with the cryptosuite of the key's type
+	proof, err := createPresentationProof(context.Background(), *presentation, keyStore, verificationMethod)
+	if err != nil {
+		log.Println("failed to sign presentation: ", err)
+		return errors.New("failed to sign presentation")
 	}
 
 	// Attach the proof to the presentation
@@ -201,50 +267,6 @@ func SignPresentation(presentation *VerifiablePresentation, holderDID string) er
 	return nil
 }
 
-// fetchPrivateKeyFromVault retrieves the private key for the specified holder DID from HashiCorp Vault
-func fetchPrivateKeyFromVault(holderDID string) (ed25519.PrivateKey, error) {
-
-	client, err := getVaultClient()
-	if err != nil {
-		return nil, err
-	}
-
-	// Define the path to your private key in Vault
-	log.Println("Holder DID: ", holderDID)
-	secretPath := fmt.Sprintf("secret/data/dids/%s", holderDID) // Adjust this path as needed
-	log.Println("Secret Path --> We stored the secrets here: ", secretPath)
-
-	// Read the private key from Vault
-	secret, err := client.Logical().Read(secretPath)
-	if err != nil {
-		log.Printf("Error reading secret from Vault: %v", err)
-		return nil, err
-	}
-
-	// Check if the secret is found
-	if secret == nil {
-		return nil, errors.New("private key not found in Vault")
-	}
-
-	data, ok := secret.Data["data"].(map[string]interface{})
-	if !ok {
-		return nil, errors.New("invalid secret format: missing 'data'")
-	}
-
-	privateKeyBase64, ok := data["private_key"].(string)
-	if !ok {
-		return nil, errors.New("private key not found in secret data")
-	}
-
-	// Decode the base64-encoded private key
-	privateKeyBytes, err := base64.StdEncoding.DecodeString(privateKeyBase64)
-	if err != nil {
-		return nil, errors.New("failed to decode private key")
-	}
-
-	return ed25519.PrivateKey(privateKeyBytes), nil // Return the parsed ECDSA private key
-}
-
 // SendPresentationToService sends the verifiable presentation to the presentation service
 func SendPresentationToService(presentation VerifiablePresentation) error {
 	// Convert the presentation to JSON
@@ -298,22 +320,31 @@ func handlePresentationRequest(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	holderDID, err := presentationHolder(req)
+	if err != nil {
+		writePresentationHolderError(w, err)
+		return
+	}
 
 	// Retrieve credentials using the VC IDs
 	credentials := GetStoredCredentialsByIDs(req.VCIDs)
 
 	// Create the Verifiable Presentation
 	presentation := VerifiablePresentation{
-		Context:              []string{"https://www.w3.org/2018/credentials/v1"},
+		Context:              presentationContext(credentials),
 		Type:                 []string{"VerifiablePresentation"},
-		Holder:               req.HolderDID,
+		Holder:               holderDID,
 		VerifiableCredential: credentials,
 	}
 
 	// Sign the presentation
-	err := SignPresentation(&presentation, req.HolderDID)
+	err = SignPresentation(&presentation, holderDID)
 	if err != nil {
 		log.Printf("Failed to sign presentation: %s", err)
+		if errors.Is(err, errDIDDeactivated) {
+			http.Error(w, "Holder DID is deactivated", http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to sign presentation", http.StatusInternalServerError)
 		return
 	}
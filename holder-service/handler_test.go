@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testCredentialV2 has an object issuer and claims the holder does not model, all covered by
+// its proof
+const testCredentialV2 = `{
+  "@context": ["https://www.w3.org/ns/credentials/v2", {"@vocab": "https://example.com/vocab#"}],
+  "id": "urn:uuid:7f2b5c1e-2b7a-4f6e-9a51-3c1d2e4f5a6b",
+  "type": ["VerifiableCredential", "EmployeeCredential"],
+  "issuer": {"id": "did:web:issuer.example.com", "name": "Example Corp"},
+  "validFrom": "2024-11-02T09:15:00Z",
+  "credentialSubject": {"id": "did:key:z6Mkholder", "employeeNumber": 42, "roles": ["admin"]},
+  "proof": {"type": "DataIntegrityProof", "cryptosuite": "eddsa-jcs-2022", "proofValue": "z3abc"}
+}`
+
+func TestVerifiableCredentialRawJSON(t *testing.T) {
+	var vc VerifiableCredential
+	if err := json.Unmarshal([]byte(testCredentialV2), &vc); err != nil {
+		t.Fatalf("Failed to decode credential: %v", err)
+	}
+	if vc.ID != "urn:uuid:7f2b5c1e-2b7a-4f6e-9a51-3c1d2e4f5a6b" || !vc.isDataModelV2() {
+		t.Errorf("Unexpected decoded fields: %q %v", vc.ID, vc.Context)
+	}
+
+	// The credential is presented as received, inside a presentation too
+	var compact bytes.Buffer
+	json.Compact(&compact, []byte(testCredentialV2))
+	data, err := json.Marshal(vc)
+	if err != nil || string(data) != compact.String() {
+		t.Errorf("Expected the credential as received, got %s (%v)", data, err)
+	}
+	presentation, err := json.Marshal(VerifiablePresentation{VerifiableCredential: []VerifiableCredential{vc}})
+	if err != nil {
+		t.Fatalf("Failed to encode presentation: %v", err)
+	}
+	var decoded struct {
+		VerifiableCredential []json.RawMessage `json:"verifiableCredential"`
+	}
+	if err := json.Unmarshal(presentation, &decoded); err != nil || len(decoded.VerifiableCredential) != 1 {
+		t.Fatalf("Failed to decode presentation: %s (%v)", presentation, err)
+	}
+	if string(decoded.VerifiableCredential[0]) != compact.String() {
+		t.Errorf("Expected the presented credential to keep every field, got %s", decoded.VerifiableCredential[0])
+	}
+
+	if _, err := json.Marshal(VerifiableCredential{}); err == nil {
+		t.Error("Expected a credential not received as JSON not to encode")
+	}
+}
+
+func TestReceiveCredential(t *testing.T) {
+	credentialV1 := `{"@context": ["https://www.w3.org/2018/credentials/v1"], "id": "urn:uuid:1", "type": ["VerifiableCredential"], "issuer": "did:key:z6Mkissuer"}`
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"data model 1.1", credentialV1, http.StatusAccepted},
+		{"data model 2.0", testCredentialV2, http.StatusAccepted},
+		{"unknown base context", `{"@context": ["https://example.com/credentials/v3"], "id": "urn:uuid:2"}`, http.StatusBadRequest},
+		{"no context", `{"id": "urn:uuid:3"}`, http.StatusBadRequest},
+		{"not an object", `["https://www.w3.org/2018/credentials/v1"]`, http.StatusBadRequest},
+		{"invalid JSON", `{"@context": `, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credentialStore = nil
+			rr := httptest.NewRecorder()
+			ReceiveCredential(rr, httptest.NewRequest("POST", "/v1/holder/receive", strings.NewReader(tt.body)))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body)
+			}
+
+			stored := GetStoredCredentials()
+			if tt.wantStatus != http.StatusAccepted {
+				if len(stored) != 0 {
+					t.Errorf("Expected the credential not to be stored, got %d", len(stored))
+				}
+				return
+			}
+			if len(stored) != 1 || string(stored[0].raw) != tt.body {
+				t.Errorf("Expected the credential to be stored as received, got %v", stored)
+			}
+		})
+	}
+}
+
+func TestPresentationContext(t *testing.T) {
+	var v1, v2 VerifiableCredential
+	json.Unmarshal([]byte(`{"@context": ["https://www.w3.org/2018/credentials/v1"]}`), &v1)
+	json.Unmarshal([]byte(testCredentialV2), &v2)
+
+	tests := []struct {
+		name        string
+		credentials []VerifiableCredential
+		want        string
+	}{
+		{"no credentials", nil, credentialsContextV1},
+		{"1.1 credentials", []VerifiableCredential{v1}, credentialsContextV1},
+		{"2.0 credentials", []VerifiableCredential{v2, v2}, credentialsContextV2},
+		{"mixed credentials", []VerifiableCredential{v2, v1}, credentialsContextV1},
+	}
+	for _, tt := range tests {
+		if got := presentationContext(tt.credentials); len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%s: expected context %s, got %v", tt.name, tt.want, got)
+		}
+	}
+}
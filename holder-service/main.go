@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/bradtumy/credential-service/keystore"
 	"github.com/joho/godotenv"
 )
 
@@ -14,6 +15,13 @@ func main() {
 	// Load environment variables
 	_ = godotenv.Load()
 
+	// Open the key store holding the holders' private keys
+	var err error
+	keyStore, err = keystore.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to open key store: %v", err)
+	}
+
 	// Initialize routes
 	routes := InitializeRoutes()
 
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// errMissingHolderID is returned for presentations to a verifier without the holder to
+	// present for
+	errMissingHolderID = errors.New("holderId is required to present to a verifier")
+	// errAmbiguousHolder is returned for presentations that name both a holder DID and a verifier
+	errAmbiguousHolder = errors.New("holderDid cannot be combined with verifier")
+)
+
+// pairwiseKey identifies the relationship of a holder with a verifier
+type pairwiseKey struct {
+	holderID string
+	verifier string
+}
+
+// pairwiseCall is a pairwise DID being created in did-service. Presentations for the same holder
+// and verifier wait for it instead of creating a second DID.
+type pairwiseCall struct {
+	done chan struct{}
+	did  string
+	err  error
+}
+
+// pairwiseDIDs are the did:peer:0 DIDs holders present with, one per holder and verifier, so
+// verifiers cannot correlate a holder's presentations. Like the credentials, they are only kept
+// in memory, so a restart creates new ones.
+var (
+	pairwiseMu    sync.Mutex
+	pairwiseDIDs  = map[pairwiseKey]string{}
+	pairwiseCalls = map[pairwiseKey]*pairwiseCall{}
+)
+
+var didServiceClient = &http.Client{Timeout: 10 * time.Second}
+
+// presentationHolder returns the DID a presentation is signed with: the request's holderDid, or
+// for a request naming a verifier the holder's pairwise DID for that verifier
+func presentationHolder(req PresentationRequest) (string, error) {
+	if req.Verifier == "" {
+		return req.HolderDID, nil
+	}
+	if req.HolderDID != "" {
+		return "", errAmbiguousHolder
+	}
+	if req.HolderID == "" {
+		return "", errMissingHolderID
+	}
+	return pairwiseDID(req.HolderID, req.Verifier)
+}
+
+// writePresentationHolderError answers a presentation request whose holder DID could not be
+// determined
+func writePresentationHolderError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errMissingHolderID) || errors.Is(err, errAmbiguousHolder) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Failed to get pairwise DID: %v", err)
+	http.Error(w, "Failed to create pairwise DID", http.StatusBadGateway)
+}
+
+// pairwiseDID returns the DID of a holder for a verifier, asking did-service for a new did:peer:0
+// DID the first time the holder presents to the verifier
+func pairwiseDID(holderID, verifier string) (string, error) {
+	key := pairwiseKey{holderID: holderID, verifier: verifier}
+
+	pairwiseMu.Lock()
+	if did, ok := pairwiseDIDs[key]; ok {
+		pairwiseMu.Unlock()
+		return did, nil
+	}
+	if call, ok := pairwiseCalls[key]; ok {
+		pairwiseMu.Unlock()
+		<-call.done
+		return call.did, call.err
+	}
+	call := &pairwiseCall{done: make(chan struct{})}
+	pairwiseCalls[key] = call
+	pairwiseMu.Unlock()
+
+	// Other holders and verifiers are not held up by the request to did-service
+	call.did, call.err = createPeerDID(holderID)
+
+	pairwiseMu.Lock()
+	if call.err == nil {
+		pairwiseDIDs[key] = call.did
+	}
+	delete(pairwiseCalls, key)
+	pairwiseMu.Unlock()
+	close(call.done)
+
+	return call.did, call.err
+}
+
+// createPeerDID creates a did:peer:0 DID for a holder in did-service, which assigns its key in
+// the key store the holder signs with. The DID belongs to the organization running the holder
+// service, HOLDER_ORGANIZATION_ID.
+func createPeerDID(holderID string) (string, error) {
+	baseURL := os.Getenv("DID_SERVICE_URL")
+	if baseURL == "" {
+		baseURL = "http://did-service:8080"
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"type":      "holder",
+		"holder_id": holderID,
+		"method":    "peer",
+		"numalgo":   0,
+
+		"organization_id": os.Getenv("HOLDER_ORGANIZATION_ID"),
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, err := didServiceClient.Post(strings.TrimSuffix(baseURL, "/")+"/v1/dids", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create pairwise DID: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to create pairwise DID: did-service answered %s", resp.Status)
+	}
+
+	var didDocument struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&didDocument); err != nil {
+		return "", fmt.Errorf("failed to decode pairwise DID document: %w", err)
+	}
+	if didDocument.ID == "" {
+		return "", errors.New("did-service returned no pairwise DID")
+	}
+	return didDocument.ID, nil
+}
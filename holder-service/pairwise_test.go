@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestDIDService starts a did-service that answers every DID creation with a new did:peer:0
+// DID and counts the DIDs it created
+func newTestDIDService(t *testing.T) *int32 {
+	t.Helper()
+	var created int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/dids" || json.NewDecoder(r.Body).Decode(&payload) != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if payload["type"] != "holder" || payload["method"] != "peer" || payload["organization_id"] != "holder-wallet" {
+			http.Error(w, "Unexpected DID request", http.StatusBadRequest)
+			return
+		}
+		n := atomic.AddInt32(&created, 1)
+		json.NewEncoder(w).Encode(map[string]string{"id": fmt.Sprintf("did:peer:0z6Mk%s%d", payload["holder_id"], n)})
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("DID_SERVICE_URL", server.URL)
+	t.Setenv("HOLDER_ORGANIZATION_ID", "holder-wallet")
+
+	pairwiseMu.Lock()
+	pairwiseDIDs = map[pairwiseKey]string{}
+	pairwiseMu.Unlock()
+	return &created
+}
+
+func TestPresentationHolder(t *testing.T) {
+	newTestDIDService(t)
+
+	tests := []struct {
+		name    string
+		req     PresentationRequest
+		want    string
+		wantErr error
+	}{
+		{"holder DID without verifier", PresentationRequest{HolderDID: "did:key:z6Mkholder"}, "did:key:z6Mkholder", nil},
+		{"holder DID and verifier", PresentationRequest{HolderDID: "did:key:z6Mkholder", Verifier: "https://verifier.example.com", HolderID: "alice"}, "", errAmbiguousHolder},
+		{"verifier without holder ID", PresentationRequest{Verifier: "https://verifier.example.com"}, "", errMissingHolderID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := presentationHolder(tt.req)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("Expected %q, %v; got %q, %v", tt.want, tt.wantErr, got, err)
+			}
+			if tt.wantErr != nil {
+				rr := httptest.NewRecorder()
+				writePresentationHolderError(rr, err)
+				if rr.Code != http.StatusBadRequest {
+					t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestPresentationHolderReusesPairwiseDID(t *testing.T) {
+	created := newTestDIDService(t)
+
+	req := PresentationRequest{Verifier: "https://verifier.example.com", HolderID: "alice"}
+	first, err := presentationHolder(req)
+	if err != nil {
+		t.Fatalf("Failed to get pairwise DID: %v", err)
+	}
+	second, err := presentationHolder(req)
+	if err != nil || second != first {
+		t.Errorf("Expected the cached DID %s, got %s (%v)", first, second, err)
+	}
+
+	other, err := presentationHolder(PresentationRequest{Verifier: "https://other.example.com", HolderID: "alice"})
+	if err != nil || other == first {
+		t.Errorf("Expected a new DID for another verifier, got %s (%v)", other, err)
+	}
+	if *created != 2 {
+		t.Errorf("Expected 2 DIDs to be created, got %d", *created)
+	}
+}
+
+func TestPairwiseDIDConcurrent(t *testing.T) {
+	created := newTestDIDService(t)
+
+	// Concurrent first presentations to a verifier share one DID
+	var wg sync.WaitGroup
+	dids := make([]string, 10)
+	for i := range dids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dids[i], _ = pairwiseDID("alice", "https://verifier.example.com")
+		}(i)
+	}
+	wg.Wait()
+
+	for _, did := range dids {
+		if did == "" || did != dids[0] {
+			t.Fatalf("Expected one pairwise DID, got %v", dids)
+		}
+	}
+	if *created != 1 {
+		t.Errorf("Expected 1 DID to be created, got %d", *created)
+	}
+}
+
+func TestPairwiseDIDServiceError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	t.Setenv("DID_SERVICE_URL", server.URL)
+
+	_, err := presentationHolder(PresentationRequest{Verifier: "https://down.example.com", HolderID: "bob"})
+	if err == nil {
+		t.Fatal("Expected a did-service error")
+	}
+	rr := httptest.NewRecorder()
+	writePresentationHolderError(rr, err)
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("Expected status %d, got %d", http.StatusBadGateway, rr.Code)
+	}
+
+	// A failed creation is not cached
+	pairwiseMu.Lock()
+	_, cached := pairwiseDIDs[pairwiseKey{holderID: "bob", verifier: "https://down.example.com"}]
+	pairwiseMu.Unlock()
+	if cached {
+		t.Error("Expected the failed pairwise DID not to be cached")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bradtumy/credential-service/dataintegrity"
+	"github.com/mr-tron/base58"
+)
+
+// Data Integrity proof parameters of the presentations we sign
+const (
+	proofTypeDataIntegrity     = dataintegrity.ProofType
+	proofPurposeAuthentication = "authentication"
+
+	// multibaseBase58BTC is the multibase prefix for base58btc encoded values
+	multibaseBase58BTC = "z"
+)
+
+// presentationHashData builds the JCS cryptosuites' signing input of a presentation, see
+// dataintegrity.HashData
+func presentationHashData(presentation VerifiablePresentation, proof Proof) ([]byte, error) {
+	document, err := dataintegrity.UnsecuredDocument(presentation)
+	if err != nil {
+		return nil, err
+	}
+	return dataintegrity.HashData(dataintegrity.ProofConfig{
+		Context:            presentation.Context,
+		Type:               proof.Type,
+		Cryptosuite:        proof.Cryptosuite,
+		Created:            proof.Created,
+		ProofPurpose:       proof.ProofPurpose,
+		VerificationMethod: proof.VerificationMethod,
+	}, document)
+}
+
+// createPresentationProof signs the presentation with the verification method's key and returns
+// an authentication proof using the key type's cryptosuite
+func createPresentationProof(ctx context.Context, presentation VerifiablePresentation, signer Signer, verificationMethod string) (Proof, error) {
+	key, err := signer.PublicKey(ctx, verificationMethod)
+	if err != nil {
+		return Proof{}, err
+	}
+	cryptosuite, ok := dataintegrity.Cryptosuite(key.Type)
+	if !ok {
+		return Proof{}, fmt.Errorf("unsupported key type: %q", key.Type)
+	}
+
+	proof := Proof{
+		Type:               proofTypeDataIntegrity,
+		Cryptosuite:        cryptosuite,
+		Created:            time.Now().UTC().Format(time.RFC3339),
+		ProofPurpose:       proofPurposeAuthentication,
+		VerificationMethod: verificationMethod,
+	}
+
+	hashData, err := presentationHashData(presentation, proof)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	signature, err := signer.Sign(ctx, verificationMethod, hashData)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	proof.ProofValue = multibaseBase58BTC + base58.Encode(signature)
+	return proof, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/bradtumy/credential-service/keystore"
+	"github.com/mr-tron/base58"
+)
+
+func TestCreatePresentationProof(t *testing.T) {
+	ctx := context.Background()
+	store := keystore.NewMemory()
+	key, err := store.Create(ctx, keystore.Ed25519)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	verificationMethod := "did:peer:0z6Mkholder#z6Mkholder"
+	if err := store.Assign(ctx, key, verificationMethod); err != nil {
+		t.Fatalf("Failed to assign key: %v", err)
+	}
+
+	var vc VerifiableCredential
+	if err := json.Unmarshal([]byte(testCredentialV2), &vc); err != nil {
+		t.Fatalf("Failed to decode credential: %v", err)
+	}
+	credentials := []VerifiableCredential{vc}
+	presentation := VerifiablePresentation{
+		Context:              presentationContext(credentials),
+		Type:                 []string{"VerifiablePresentation"},
+		Holder:               "did:peer:0z6Mkholder",
+		VerifiableCredential: credentials,
+	}
+
+	proof, err := createPresentationProof(ctx, presentation, store, verificationMethod)
+	if err != nil {
+		t.Fatalf("Failed to sign presentation: %v", err)
+	}
+	if proof.Type != proofTypeDataIntegrity || proof.Cryptosuite != "eddsa-jcs-2022" || proof.ProofPurpose != proofPurposeAuthentication || proof.VerificationMethod != verificationMethod {
+		t.Errorf("Unexpected proof: %+v", proof)
+	}
+	if len(proof.ProofValue) < 2 || proof.ProofValue[:1] != multibaseBase58BTC {
+		t.Fatalf("Expected a base58btc proof value, got %q", proof.ProofValue)
+	}
+	signature, err := base58.Decode(proof.ProofValue[1:])
+	if err != nil {
+		t.Fatalf("Failed to decode proof value: %v", err)
+	}
+
+	hashData, err := presentationHashData(presentation, proof)
+	if err != nil {
+		t.Fatalf("Failed to hash presentation: %v", err)
+	}
+	if !ed25519.Verify(key.PublicKey, hashData, signature) {
+		t.Error("Expected the proof to verify with the holder's key")
+	}
+
+	// The proof covers the credentials as received
+	var tampered VerifiableCredential
+	json.Unmarshal([]byte(`{"@context": ["https://www.w3.org/ns/credentials/v2"], "id": "urn:uuid:other"}`), &tampered)
+	presentation.VerifiableCredential = []VerifiableCredential{tampered}
+	hashData, err = presentationHashData(presentation, proof)
+	if err != nil {
+		t.Fatalf("Failed to hash presentation: %v", err)
+	}
+	if ed25519.Verify(key.PublicKey, hashData, signature) {
+		t.Error("Expected the proof not to verify with other credentials")
+	}
+
+	if _, err := createPresentationProof(ctx, presentation, store, "did:peer:0z6Mkother#z6Mkother"); !errors.Is(err, keystore.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound for an unassigned verification method, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// errDIDDeactivated is returned when the resolved DID has been deactivated
+var errDIDDeactivated = errors.New("DID is deactivated")
+
+// DIDResolutionResult is the DID Resolution Result returned by resolver-service
+type DIDResolutionResult struct {
+	DIDDocument map[string]interface{} `json:"didDocument"`
+}
+
+var resolverClient = &http.Client{Timeout: 10 * time.Second}
+
+// resolveDIDDocument fetches the DID document of a DID from resolver-service
+func resolveDIDDocument(did string) (map[string]interface{}, error) {
+	baseURL := os.Getenv("RESOLVER_SERVICE_URL")
+	if baseURL == "" {
+		baseURL = "http://resolver-service:8080"
+	}
+
+	resolverURL := fmt.Sprintf("%s/v1/dids/resolver?did=%s", strings.TrimSuffix(baseURL, "/"), url.QueryEscape(did))
+	resp, err := resolverClient.Get(resolverURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch DID document from resolver: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// resolver-service answers 410 Gone for deactivated DIDs
+	if resp.StatusCode == http.StatusGone {
+		return nil, fmt.Errorf("%w: %s", errDIDDeactivated, did)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-OK response from resolver: %s", resp.Status)
+	}
+
+	var resolution DIDResolutionResult
+	if err := json.NewDecoder(resp.Body).Decode(&resolution); err != nil {
+		return nil, fmt.Errorf("failed to decode DID resolution result: %w", err)
+	}
+	if resolution.DIDDocument == nil {
+		return nil, fmt.Errorf("resolver returned no DID document for %s", did)
+	}
+
+	return resolution.DIDDocument, nil
+}
+
+// authenticationMethodID returns the id of the first verification method the holder's DID
+// document declares for authentication, which is the key presentations are signed with
+func authenticationMethodID(didDocument map[string]interface{}) (string, error) {
+	did, _ := didDocument["id"].(string)
+
+	references, _ := didDocument["authentication"].([]interface{})
+	for _, reference := range references {
+		var keyID string
+		switch v := reference.(type) {
+		case string:
+			keyID = v
+		case map[string]interface{}:
+			keyID, _ = v["id"].(string)
+		}
+		if keyID == "" {
+			continue
+		}
+		// Relative references are resolved against the DID
+		if strings.HasPrefix(keyID, "#") {
+			keyID = did + keyID
+		}
+		return keyID, nil
+	}
+
+	return "", errors.New("DID document has no authentication method")
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+
+	"github.com/bradtumy/credential-service/keystore"
+)
+
+// Signer signs proof hash data with the key of a verification method. Every keystore.KeyStore
+// is a Signer.
+type Signer interface {
+	// PublicKey returns the verification method's key, whose type selects the cryptosuite
+	PublicKey(ctx context.Context, keyID string) (keystore.Key, error)
+	// Sign signs data with the verification method's key
+	Sign(ctx context.Context, keyID string, data []byte) ([]byte, error)
+}
+
+// keyStore holds the private keys did-service created for holder DIDs, see keystore.NewFromEnv
+var keyStore keystore.KeyStore
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Versions of the W3C Verifiable Credentials Data Model credentials are issued in, selected per
+// request or as the issuer's default
+const (
+	dataModelV1      = "1.1"
+	dataModelV2      = "2.0"
+	defaultDataModel = dataModelV1
+)
+
+// Base contexts of the data models
+const (
+	credentialsContextV1 = "https://www.w3.org/2018/credentials/v1"
+	credentialsContextV2 = "https://www.w3.org/ns/credentials/v2"
+)
+
+var (
+	// errUnsupportedDataModel is returned for data model versions credentials cannot be issued in
+	errUnsupportedDataModel = errors.New("unsupported data model")
+	// errInvalidCredentialStatus is returned for credentialStatus entries the data model does not
+	// allow
+	errInvalidCredentialStatus = errors.New("invalid credentialStatus")
+)
+
+// validateDataModel checks that credentials can be issued in a data model version
+func validateDataModel(dataModel string) error {
+	if dataModel != dataModelV1 && dataModel != dataModelV2 {
+		return fmt.Errorf("%w %q, expected %q or %q", errUnsupportedDataModel, dataModel, dataModelV1, dataModelV2)
+	}
+	return nil
+}
+
+// selectDataModel returns the data model of a request's credentials: the one it asks for, else
+// the issuer's default, else defaultDataModel
+func selectDataModel(requested, issuerDefault string) string {
+	switch {
+	case requested != "":
+		return requested
+	case issuerDefault != "":
+		return issuerDefault
+	default:
+		return defaultDataModel
+	}
+}
+
+// validateCredentialStatus checks credentialStatus entries against a data model version: every
+// entry needs a type, and the 1.1 data model allows a single entry, which also needs an id
+func validateCredentialStatus(entries []map[string]interface{}, dataModel string) error {
+	if dataModel == dataModelV1 && len(entries) > 1 {
+		return fmt.Errorf("%w: data model 1.1 allows a single entry", errInvalidCredentialStatus)
+	}
+	for i, entry := range entries {
+		if statusType, ok := entry["type"].(string); !ok || statusType == "" {
+			return fmt.Errorf("%w: entry %d has no type", errInvalidCredentialStatus, i)
+		}
+		if id, ok := entry["id"].(string); dataModel == dataModelV1 && (!ok || id == "") {
+			return fmt.Errorf("%w: entry %d has no id", errInvalidCredentialStatus, i)
+		}
+	}
+	return nil
+}
+
+// credentialStatus returns the credentialStatus of a credential in a data model version: an
+// array of the entries in 2.0, the single entry in 1.1, and nil without entries
+func credentialStatus(entries []map[string]interface{}, dataModel string) interface{} {
+	switch {
+	case len(entries) == 0:
+		return nil
+	case dataModel == dataModelV2:
+		return entries
+	default:
+		return entries[0]
+	}
+}
+
+// baseContext returns the base @context of a data model version; credentials of jobs queued
+// without a version are 1.1 credentials
+func baseContext(dataModel string) string {
+	if dataModel == dataModelV2 {
+		return credentialsContextV2
+	}
+	return credentialsContextV1
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateDataModel(t *testing.T) {
+	for _, dataModel := range []string{dataModelV1, dataModelV2} {
+		if err := validateDataModel(dataModel); err != nil {
+			t.Errorf("Expected data model %s to be supported, got %v", dataModel, err)
+		}
+	}
+	for _, dataModel := range []string{"", "1.0", "2", "v2"} {
+		if err := validateDataModel(dataModel); !errors.Is(err, errUnsupportedDataModel) {
+			t.Errorf("Expected errUnsupportedDataModel for %q, got %v", dataModel, err)
+		}
+	}
+}
+
+func TestSelectDataModel(t *testing.T) {
+	if dataModel := selectDataModel("", ""); dataModel != dataModelV1 {
+		t.Errorf("Expected the default data model, got %s", dataModel)
+	}
+	if dataModel := selectDataModel("", dataModelV2); dataModel != dataModelV2 {
+		t.Errorf("Expected the issuer's data model, got %s", dataModel)
+	}
+	if dataModel := selectDataModel(dataModelV1, dataModelV2); dataModel != dataModelV1 {
+		t.Errorf("Expected the requested data model, got %s", dataModel)
+	}
+}
+
+func TestCredentialContextsV2(t *testing.T) {
+	contexts := credentialContexts(dataModelV2, []string{credentialsContextV1, "https://example.com/contexts/employee/v1", credentialsContextV2})
+	expected := []string{credentialsContextV2, "https://example.com/contexts/employee/v1"}
+	if !reflect.DeepEqual(contexts, expected) {
+		t.Errorf("Expected contexts %v, got %v", expected, contexts)
+	}
+}
+
+func TestNewCredentialV2(t *testing.T) {
+	issuedAt := time.Date(2024, 9, 5, 12, 0, 0, 0, time.UTC)
+	job := issuanceJob{
+		Request:   CredentialRequest{IssuerDid: "did:key:z6MkIssuer"},
+		Context:   credentialContexts(dataModelV2),
+		Validity:  validityPeriod{Lifetime: "P90D"},
+		DataModel: dataModelV2,
+	}
+
+	credential, err := newCredential(uuid.New(), job, map[string]interface{}{"id": "did:key:z6MkHolder"}, issuedAt)
+	if err != nil {
+		t.Fatalf("Failed to build credential: %v", err)
+	}
+	if !reflect.DeepEqual(credential.Context, []string{credentialsContextV2}) {
+		t.Errorf("Unexpected context %v", credential.Context)
+	}
+	if credential.ValidFrom != "2024-09-05T12:00:00Z" || credential.ValidUntil != "2024-12-04T12:00:00Z" {
+		t.Errorf("Unexpected validity %q - %q", credential.ValidFrom, credential.ValidUntil)
+	}
+	if credential.IssuanceDate != "" || credential.ExpirationDate != "" {
+		t.Errorf("Expected no 1.1 dates, got %q - %q", credential.IssuanceDate, credential.ExpirationDate)
+	}
+	if from, until := credential.validityDates(); from != credential.ValidFrom || until != credential.ValidUntil {
+		t.Errorf("Unexpected validity dates %q - %q", from, until)
+	}
+
+	// A credential that never expires has no validUntil
+	job.Validity = validityPeriod{NeverExpires: true}
+	credential, err = newCredential(uuid.New(), job, map[string]interface{}{"id": "did:key:z6MkHolder"}, issuedAt)
+	if err != nil || credential.ValidFrom != "2024-09-05T12:00:00Z" || credential.ValidUntil != "" {
+		t.Errorf("Unexpected validity %q - %q (%v)", credential.ValidFrom, credential.ValidUntil, err)
+	}
+}
+
+func TestValidateCredentialStatus(t *testing.T) {
+	entry := map[string]interface{}{"id": "https://status.example.com/1#7", "type": "BitstringStatusListEntry"}
+	withoutID := map[string]interface{}{"type": "BitstringStatusListEntry"}
+	tests := []struct {
+		name      string
+		dataModel string
+		entries   []map[string]interface{}
+		valid     bool
+	}{
+		{name: "none", dataModel: dataModelV1, valid: true},
+		{name: "1.1 entry", dataModel: dataModelV1, entries: []map[string]interface{}{entry}, valid: true},
+		{name: "1.1 entries", dataModel: dataModelV1, entries: []map[string]interface{}{entry, entry}},
+		{name: "1.1 entry without id", dataModel: dataModelV1, entries: []map[string]interface{}{withoutID}},
+		{name: "2.0 entries", dataModel: dataModelV2, entries: []map[string]interface{}{entry, withoutID}, valid: true},
+		{name: "2.0 entry without type", dataModel: dataModelV2, entries: []map[string]interface{}{{"id": "https://status.example.com/1#7"}}},
+	}
+
+	for _, tt := range tests {
+		err := validateCredentialStatus(tt.entries, tt.dataModel)
+		if tt.valid && err != nil {
+			t.Errorf("%s: expected valid entries, got %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, errInvalidCredentialStatus) {
+			t.Errorf("%s: expected %v, got %v", tt.name, errInvalidCredentialStatus, err)
+		}
+	}
+}
+
+func TestNewCredentialStatus(t *testing.T) {
+	privateKey, didDocument := newTestIssuer(t)
+	verificationMethod, _ := assertionMethodID(didDocument)
+	publicKey, err := publicKeyFromDIDDocument(didDocument, verificationMethod)
+	if err != nil {
+		t.Fatalf("Failed to resolve public key: %v", err)
+	}
+	signer := newTestSigner(t, verificationMethod, keyTypeEd25519, privateKey)
+
+	entries := []map[string]interface{}{{
+		"id":                   "https://status.example.com/credentials/status/3#94567",
+		"type":                 "BitstringStatusListEntry",
+		"statusPurpose":        "revocation",
+		"statusListIndex":      "94567",
+		"statusListCredential": "https://status.example.com/credentials/status/3",
+	}}
+	for dataModel, expected := range map[string]string{dataModelV1: "{", dataModelV2: "[{"} {
+		job := issuanceJob{
+			Request:          CredentialRequest{IssuerDid: didDocument["id"].(string)},
+			Context:          credentialContexts(dataModel),
+			DataModel:        dataModel,
+			CredentialStatus: entries,
+		}
+		credential, err := newCredential(uuid.New(), job, map[string]interface{}{"id": "did:key:z6MkHolder"}, time.Now())
+		if err != nil {
+			t.Fatalf("Failed to build credential: %v", err)
+		}
+		proof, err := createDataIntegrityProof(context.Background(), credential, signer, verificationMethod)
+		if err != nil {
+			t.Fatalf("Failed to sign credential: %v", err)
+		}
+		credential.Proof = proof
+
+		// The status is emitted in the shape of the data model
+		encoded, _ := json.Marshal(credential)
+		var emitted map[string]json.RawMessage
+		json.Unmarshal(encoded, &emitted)
+		if status := string(emitted["credentialStatus"]); len(status) < 2 || status[:len(expected)] != expected {
+			t.Errorf("%s: unexpected credentialStatus %s", dataModel, status)
+		}
+
+		// and covered by the proof
+		if err := verifyDataIntegrityProof(credential, keyTypeEd25519, publicKey); err != nil {
+			t.Errorf("%s: expected the credential to verify, got %v", dataModel, err)
+		}
+		tampered := credential
+		tampered.CredentialStatus = credentialStatus([]map[string]interface{}{{"id": "https://status.example.com/credentials/status/3#1", "type": "BitstringStatusListEntry"}}, dataModel)
+		if err := verifyDataIntegrityProof(tampered, keyTypeEd25519, publicKey); err == nil {
+			t.Errorf("%s: expected a changed credentialStatus to fail verification", dataModel)
+		}
+		tampered.CredentialStatus = nil
+		if err := verifyDataIntegrityProof(tampered, keyTypeEd25519, publicKey); err == nil {
+			t.Errorf("%s: expected a removed credentialStatus to fail verification", dataModel)
+		}
+	}
+}
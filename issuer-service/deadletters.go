@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// ReplayRequest is the payload of POST /v1/admin/dead-letters/replay. Without job IDs every
+// dead-lettered job is replayed.
+type ReplayRequest struct {
+	JobIDs []string `json:"jobIds,omitempty"`
+}
+
+// listDeadLetters reports the dead-lettered issuance jobs, oldest first
+func listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := maxDeadLetterBatch
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxDeadLetterBatch {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxDeadLetterBatch), http.StatusBadRequest)
+			return
+		}
+	}
+
+	deadLetters, err := jobQueue.DeadLetters(context.Background(), limit)
+	if err != nil {
+		log.Printf("Failed to list dead letters: %v", err)
+		http.Error(w, "Failed to list dead-lettered jobs", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]DeadLetter{"deadLetters": deadLetters})
+}
+
+// replayDeadLettersHandler puts dead-lettered issuance jobs back on the issuance queue
+func replayDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	var req ReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	replayed, err := jobQueue.Replay(ctx, req.JobIDs)
+	for _, jobID := range replayed {
+		if err := markIssuanceJob(ctx, jobID, jobStateQueued, ""); err != nil {
+			log.Printf("Failed to mark replayed issuance job %s queued: %v", jobID, err)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to replay dead letters: %v", err)
+		status := http.StatusServiceUnavailable
+		if len(replayed) > 0 {
+			status = http.StatusInternalServerError
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"replayed": replayed, "error": err.Error()})
+		return
+	}
+
+	log.Printf("Replayed %d dead-lettered issuance jobs", len(replayed))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"replayed": replayed})
+}
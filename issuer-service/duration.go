@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// isoDurationPattern matches the ISO 8601 durations credential lifetimes are given in, e.g. P1Y,
+// P6M, P2W, P90D or PT12H. Components are whole numbers.
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// isoDuration is an ISO 8601 duration. Years, months and days are calendar units, so a duration
+// only has a length from a given time on.
+type isoDuration struct {
+	Years, Months, Days int
+	Time                time.Duration
+	text                string
+}
+
+// parseISODuration parses a positive ISO 8601 duration
+func parseISODuration(text string) (isoDuration, error) {
+	match := isoDurationPattern.FindStringSubmatch(text)
+	if match == nil || text == "P" || text[len(text)-1] == 'T' {
+		return isoDuration{}, fmt.Errorf("%q is not an ISO 8601 duration such as P1Y or P90D", text)
+	}
+
+	values := make([]int, len(match)-1)
+	for i, component := range match[1:] {
+		if component == "" {
+			continue
+		}
+		value, err := strconv.Atoi(component)
+		if err != nil {
+			return isoDuration{}, fmt.Errorf("%q is out of range", text)
+		}
+		values[i] = value
+	}
+
+	d := isoDuration{
+		Years:  values[0],
+		Months: values[1],
+		Days:   values[2]*7 + values[3],
+		Time:   time.Duration(values[4])*time.Hour + time.Duration(values[5])*time.Minute + time.Duration(values[6])*time.Second,
+		text:   text,
+	}
+	if d.Years == 0 && d.Months == 0 && d.Days == 0 && d.Time == 0 {
+		return isoDuration{}, errors.New("duration must be positive")
+	}
+	return d, nil
+}
+
+// addTo returns the time the duration ends when it starts at t
+func (d isoDuration) addTo(t time.Time) time.Time {
+	return t.AddDate(d.Years, d.Months, d.Days).Add(d.Time)
+}
+
+// String returns the duration as it was parsed
+func (d isoDuration) String() string {
+	return d.text
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseISODuration(t *testing.T) {
+	start := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		text string
+		end  time.Time
+	}{
+		{"P1Y", time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC)},
+		{"P90D", time.Date(2024, 4, 30, 12, 0, 0, 0, time.UTC)},
+		{"P2W", time.Date(2024, 2, 14, 12, 0, 0, 0, time.UTC)},
+		{"PT12H", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"P1Y2M3DT4H5M6S", time.Date(2025, 4, 3, 16, 5, 6, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		d, err := parseISODuration(tt.text)
+		if err != nil {
+			t.Errorf("Failed to parse %s: %v", tt.text, err)
+			continue
+		}
+		if end := d.addTo(start); !end.Equal(tt.end) {
+			t.Errorf("Expected %s from %s to end at %s, got %s", tt.text, start, tt.end, end)
+		}
+		if d.String() != tt.text {
+			t.Errorf("Expected %s, got %s", tt.text, d)
+		}
+	}
+
+	for _, text := range []string{"", "P", "PT", "P1YT", "1Y", "P1.5Y", "P0D", "PT0S", "P-1D", "P1H", "P99999999999999999999Y"} {
+		if _, err := parseISODuration(text); err == nil {
+			t.Errorf("Expected an error for %q", text)
+		}
+	}
+}
@@ -5,9 +5,13 @@ go 1.21
 toolchain go1.23.1
 
 require (
+	github.com/bradtumy/credential-service/dataintegrity v0.0.0-00010101000000-000000000000
+	github.com/bradtumy/credential-service/keystore v0.0.0-00010101000000-000000000000
+	github.com/cloudflare/circl v1.3.8
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0
 	github.com/google/uuid v1.6.0
 	github.com/jackc/pgx/v4 v4.18.3
-	github.com/joho/godotenv v1.5.1
+	github.com/mr-tron/base58 v1.2.0
 )
 
 require (
@@ -26,12 +30,13 @@ require (
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	github.com/ryanuber/go-glob v1.0.0 // indirect
 	golang.org/x/net v0.25.0 // indirect
+	golang.org/x/sys v0.20.0 // indirect
 	golang.org/x/time v0.0.0-20200416051211-89c76fbcd5d1 // indirect
 )
 
 require (
 	github.com/gorilla/mux v1.8.1
-	github.com/hashicorp/vault/api v1.15.0
+	github.com/hashicorp/vault/api v1.15.0 // indirect
 	github.com/jackc/chunkreader/v2 v2.0.1 // indirect
 	github.com/jackc/pgconn v1.14.3 // indirect
 	github.com/jackc/pgio v1.0.0 // indirect
@@ -40,8 +45,12 @@ require (
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
 	github.com/jackc/pgtype v1.14.0 // indirect
 	github.com/jackc/puddle v1.3.0 // indirect
-	github.com/lib/pq v1.10.9
+	github.com/lib/pq v1.10.9 // indirect
 	github.com/streadway/amqp v1.1.0
 	golang.org/x/crypto v0.23.0 // indirect
 	golang.org/x/text v0.15.0 // indirect
 )
+
+replace github.com/bradtumy/credential-service/keystore => ../keystore
+
+replace github.com/bradtumy/credential-service/dataintegrity => ../dataintegrity
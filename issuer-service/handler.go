@@ -2,17 +2,15 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/hashicorp/vault/api"
 )
 
 // VerifiableCredential structure following W3C schema
@@ -21,35 +19,69 @@ type VerifiableCredential struct {
 	Type              []string               `json:"type"`
 	ID                string                 `json:"id"`
 	Issuer            string                 `json:"issuer"`
-	IssuanceDate      string                 `json:"issuanceDate"`
-	ExpirationDate    string                 `json:"expirationDate"`
+	IssuanceDate      string                 `json:"issuanceDate,omitempty"`   // Data model 1.1
+	ExpirationDate    string                 `json:"expirationDate,omitempty"` // Data model 1.1
+	ValidFrom         string                 `json:"validFrom,omitempty"`      // Data model 2.0
+	ValidUntil        string                 `json:"validUntil,omitempty"`     // Data model 2.0
 	CredentialSubject map[string]interface{} `json:"credentialSubject"`
+	CredentialSchema  *CredentialSchema      `json:"credentialSchema,omitempty"`
+	CredentialStatus  interface{}            `json:"credentialStatus,omitempty"` // An object in data model 1.1, an array in 2.0
 	Proof             Proof                  `json:"proof,omitempty"`
 }
 
+// validityDates returns when a credential becomes valid and when it expires, in either data
+// model. The expiration is empty for credentials that never expire.
+func (vc VerifiableCredential) validityDates() (string, string) {
+	if vc.ValidFrom != "" || vc.ValidUntil != "" {
+		return vc.ValidFrom, vc.ValidUntil
+	}
+	return vc.IssuanceDate, vc.ExpirationDate
+}
+
 // Proof structure for digital signature
 type Proof struct {
 	Type               string `json:"type"`
+	Cryptosuite        string `json:"cryptosuite,omitempty"`
 	Created            string `json:"created"`
 	ProofValue         string `json:"proofValue"`
 	ProofPurpose       string `json:"proofPurpose"`
 	VerificationMethod string `json:"verificationMethod"`
 }
 
+// DIDResolutionResult is the DID Resolution Result returned by resolver-service
+type DIDResolutionResult struct {
+	DIDDocument           map[string]interface{} `json:"didDocument"`
+	DIDResolutionMetadata map[string]interface{} `json:"didResolutionMetadata"`
+	DIDDocumentMetadata   map[string]interface{} `json:"didDocumentMetadata"`
+}
+
 // Updated Request payload for issuing a credential - using a map enables us to support different schema combinations.
 type CredentialRequest struct {
 	IssuerDid string                   `json:"issuerDid"`
-	Subjects  []map[string]interface{} `json:"subject"` // Change to a dynamic structure
+	SchemaID  string                   `json:"schemaId,omitempty"`  // Schema of schema-service the subjects follow
+	Context   []string                 `json:"@context,omitempty"`  // Additional JSON-LD contexts of the credentials
+	Type      []string                 `json:"type,omitempty"`      // Additional types of the credentials
+	Subjects  []map[string]interface{} `json:"subject"`             // Change to a dynamic structure
+	DataModel string                   `json:"dataModel,omitempty"` // "1.1" or "2.0", the issuer's default if empty
+
+	// CredentialStatus are the credentialStatus entries of every credential, the issuer's if empty
+	CredentialStatus []map[string]interface{} `json:"credentialStatus,omitempty"`
+
+	// Validity of the credentials; by default they are valid from their issuance for the
+	// schema's default lifetime
+	ValidFrom    string `json:"validFrom,omitempty"`    // RFC 3339
+	ValidUntil   string `json:"validUntil,omitempty"`   // RFC 3339
+	NeverExpires bool   `json:"neverExpires,omitempty"` // The credentials have no expiration date
 }
 
-// BaseSchema represents the structure of the base schema
-// TODO: use a map for the base schema too so that we can change the base schema json file and dynamically update the type
-type BaseSchema struct {
-	CredentialID   Property `json:"credentialID"`
-	CredentialType Property `json:"credentialType"`
-	IssueDate      Property `json:"issueDate"`
-	ExpirationDate Property `json:"expirationDate"`
-	Issuer         Property `json:"issuer"`
+// BaseSchema holds the properties every credential has, by name. Their names are reserved:
+// subjects cannot carry claims named after them.
+type BaseSchema map[string]Property
+
+// Reserves reports whether a claim name is reserved by the base schema
+func (b BaseSchema) Reserves(name string) bool {
+	_, ok := b[name]
+	return ok
 }
 
 // Property represents a property of the schema
@@ -59,9 +91,16 @@ type Property struct {
 	Required bool   `json:"required"` // Indicate if the property is required
 }
 
-// Schema represents a JSON Schema structure.
+// Schema is a schema of schema-service, the claims a credential's subjects may carry and the
+// types and contexts of the credentials issued with it
 type Schema struct {
-	Properties []Property `json:"properties"` // Array of properties
+	ID              string     `json:"id"`
+	OrganizationDID string     `json:"organization_did"`
+	SchemaName      string     `json:"schema_name"`
+	Properties      []Property `json:"properties"` // Array of properties
+	CredentialTypes []string   `json:"credential_types,omitempty"`
+	Contexts        []string   `json:"contexts,omitempty"`
+	DefaultLifetime string     `json:"default_lifetime,omitempty"` // ISO 8601 duration credentials are valid for by default
 }
 
 // loadBaseSchema loads the base schema from a JSON file.
@@ -75,31 +114,17 @@ func loadBaseSchema(filePath string) (BaseSchema, error) {
 	if err := json.Unmarshal(file, &baseSchema); err != nil {
 		return baseSchema, fmt.Errorf("failed to unmarshal base schema JSON: %v", err)
 	}
-
-	return baseSchema, nil
-}
-
-/*
-func mergeSchemas(base BaseSchema, customerSchema Schema) Schema {
-	// Create a new schema to hold the merged properties
-	mergedSchema := Schema{
-		Properties: []Property{
-			base.CredentialID,
-			base.CredentialType,
-			base.IssueDate,
-			base.ExpirationDate,
-			base.Issuer,
-		},
+	for name, property := range baseSchema {
+		property.Name = name
+		baseSchema[name] = property
 	}
 
-	// Append customer schema properties
-	mergedSchema.Properties = append(mergedSchema.Properties, customerSchema.Properties...)
-
-	return mergedSchema
+	return baseSchema, nil
 }
-*/
 
-// IssueCredential issues a verifiable credential
+// IssueCredential accepts a request to issue a verifiable credential to each subject and queues
+// it as an issuance job. The credentials are issued by the worker; the response carries the job
+// ID to follow the job's outcome at /v1/credential/jobs/{id}.
 func issueCredential(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -114,229 +139,180 @@ func issueCredential(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.IssuerDid == "" {
+		http.Error(w, "Missing issuerDid", http.StatusBadRequest)
+		return
+	}
 	if len(req.Subjects) == 0 {
 		http.Error(w, "No subjects provided", http.StatusBadRequest)
 		return
 	}
-
-	// Enqueue the bulk request to RabbitMQ for processing.
-	if err := enqueueBulkIssuance(req); err != nil {
-		log.Printf("Failed to enqueue bulk issuance: %v", err)
-		http.Error(w, "Failed to process request", http.StatusInternalServerError)
+	references := map[string]bool{}
+	for _, subject := range req.Subjects {
+		if _, err := subjectID(subject); err != nil {
+			log.Printf("Invalid subject %+v: %v", subject, err)
+			http.Error(w, "Invalid subject data: every subject needs an id", http.StatusBadRequest)
+			return
+		}
+		reference, err := externalReference(subject)
+		if err != nil {
+			http.Error(w, "Invalid subject data: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if reference == "" {
+			continue
+		}
+		if references[reference] {
+			http.Error(w, fmt.Sprintf("Invalid subject data: duplicate externalReference %q", reference), http.StatusBadRequest)
+			return
+		}
+		references[reference] = true
+	}
+	if err := validateContexts(req.Context); err != nil {
+		http.Error(w, "Invalid @context: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateTypes(req.Type); err != nil {
+		http.Error(w, "Invalid type: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	if req.DataModel != "" {
+		if err := validateDataModel(req.DataModel); err != nil {
+			http.Error(w, "Invalid dataModel: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
-	// Log the request for debugging purposes
-	log.Printf("Received credential request: %+v", req)
+	// Validate the claims against the schema before anything is signed
+	ctx := context.Background()
+	var schema Schema
+	if req.SchemaID != "" {
+		var err error
+		schema, err = fetchSchema(ctx, req.SchemaID)
+		if errors.Is(err, errSchemaNotFound) {
+			http.Error(w, fmt.Sprintf("Unknown schemaId %q", req.SchemaID), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to fetch schema %s: %v", req.SchemaID, err)
+			http.Error(w, "Schema service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if invalid := validateSubjects(schema, baseSchema, req.Subjects); len(invalid) > 0 {
+			writeInvalidSubjects(w, req.SchemaID, invalid)
+			return
+		}
+	}
 
-	// Resolve the issuer DID
-	log.Println("Here is the issuer DID: ", req.IssuerDid)
-	resolverURL := fmt.Sprintf("http://resolver-service:8080/v1/dids/resolver?did=%s", url.QueryEscape(req.IssuerDid))
-	log.Println("Resolver Endpoint:: ", resolverURL)
-	resp, err := http.Get(resolverURL)
+	// Work out when the credentials are valid, within the issuer's maximum lifetime
+	policy, err := loadIssuerPolicy(ctx, req.IssuerDid)
 	if err != nil {
-		log.Printf("Failed to fetch DID document from resolver: %v", err)
-		http.Error(w, "Failed to resolve DID", http.StatusInternalServerError)
+		log.Printf("Failed to load policy of %s: %v", req.IssuerDid, err)
+		http.Error(w, "Failed to process request", http.StatusInternalServerError)
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Received non-OK response from resolver: %s", resp.Status)
-		http.Error(w, "Failed to resolve DID", http.StatusInternalServerError)
+	validity, err := resolveValidity(req, schema.DefaultLifetime, policy.MaxLifetime, time.Now())
+	switch {
+	case errors.Is(err, errInvalidValidity):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, errLifetimeExceeded):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		log.Printf("Failed to resolve validity period: %v", err)
+		http.Error(w, "Failed to process request", http.StatusInternalServerError)
 		return
 	}
 
-	// Initialize Vault client
-	vaultClient, err := getVaultClient()
-	if err != nil {
-		log.Printf("Failed to initialize Vault client: %v", err)
-		http.Error(w, "Failed to connect to Vault", http.StatusInternalServerError)
+	idempotencyKey := r.Header.Get(idempotencyKeyHeader)
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		http.Error(w, fmt.Sprintf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength), http.StatusBadRequest)
 		return
 	}
 
-	// Retrieve the private key from Vault
-	privateKeyPEM, err := getPrivateKeyFromVault(req.IssuerDid, vaultClient)
-	if err != nil {
-		log.Printf("Failed to retrieve private key from Vault: %v", err)
-		http.Error(w, "Failed to issue credential", http.StatusInternalServerError)
+	// Record the job before enqueueing it, so the worker always finds it. The credentials get
+	// the types and contexts of the schema, then those of the request.
+	dataModel := selectDataModel(req.DataModel, policy.DataModel)
+	statusEntries := req.CredentialStatus
+	if len(statusEntries) == 0 {
+		statusEntries = policy.CredentialStatus
+	}
+	if err := validateCredentialStatus(statusEntries, dataModel); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	log.Println("Here's the Private Key to parse: ", privateKeyPEM)
-
-	privateKey, err := parseEd25519PrivateKeyFromBase64(privateKeyPEM)
-	if err != nil {
-		log.Printf("Failed to parse private key: %v", err)
-		http.Error(w, "Failed to issue credential", http.StatusInternalServerError)
+	job := issuanceJob{
+		ID:      uuid.New().String(),
+		Request: req,
+		Context: credentialContexts(dataModel, schema.Contexts, req.Context),
+		Type:    credentialTypes(schema.CredentialTypes, req.Type),
+
+		Validity:         validity,
+		DataModel:        dataModel,
+		CredentialStatus: statusEntries,
+	}
+	jobID, replayed, err := createIssuanceJob(ctx, job, idempotencyKey)
+	if errors.Is(err, errIdempotencyKeyReused) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 		return
 	}
-
-	// Process DID document
-	var didDocument map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&didDocument); err != nil {
-		log.Printf("Failed to decode DID document: %v", err)
-		http.Error(w, "Failed to process DID document", http.StatusInternalServerError)
+	if err != nil {
+		log.Printf("Failed to create issuance job: %v", err)
+		http.Error(w, "Failed to process request", http.StatusInternalServerError)
 		return
 	}
 
-	// Generate credential ID and set issuance/expiration dates
-	//credentialID := uuid.New().String()
-	issuanceDate := time.Now().UTC().Format(time.RFC3339)
-	expirationDate := time.Now().AddDate(1, 0, 0).UTC().Format(time.RFC3339)
-
-	log.Printf("*** I have: %d subjects in the request", len(req.Subjects))
-
-	// Loop through each subject and create a verifiable credential
-	for _, subject := range req.Subjects {
-		// Generate a unique credential ID for each subject
-		credentialID := uuid.New().String()
-
-		log.Printf("*** Here is the Unique Credential ID: %s", credentialID)
-
-		// Create the verifiable credential for the current subject
-		credential := VerifiableCredential{
-			Context:        []string{"https://www.w3.org/2018/credentials/v1"},
-			Type:           []string{"VerifiableCredential"},
-			ID:             "",
-			Issuer:         req.IssuerDid,
-			IssuanceDate:   issuanceDate,
-			ExpirationDate: expirationDate,
-			CredentialSubject: map[string]interface{}{
-				"subject": subject, // Use the current subject
-			},
-		}
-		// Serialize the credential to JSON
-		credentialJSON, err := json.Marshal(credential)
-		if err != nil {
-			log.Printf("Failed to marshal credential to JSON: %v", err)
-			http.Error(w, "Failed to process credential", http.StatusInternalServerError)
+	// A replayed request gets the job created for its Idempotency-Key, as it is now
+	if replayed {
+		var state string
+		if err := db.QueryRow(ctx, "SELECT state FROM issuance_jobs WHERE id = $1", jobID).Scan(&state); err != nil {
+			log.Printf("Failed to query issuance job %s: %v", jobID, err)
+			http.Error(w, "Failed to process request", http.StatusInternalServerError)
 			return
 		}
-		// Sign the credential
-		signature, err := signCredential(privateKey, credentialJSON)
-		if err != nil {
-			log.Printf("Failed to sign credential: %v", err)
-			http.Error(w, "Failed to issue credential", http.StatusInternalServerError)
-			return
-		}
-
-		// Attach proof to the credential
-		credential.Proof = Proof{
-			Type:               "Ed25519Signature2018",
-			Created:            time.Now().UTC().Format(time.RFC3339),
-			ProofValue:         base64.StdEncoding.EncodeToString(signature),
-			ProofPurpose:       "assertionMethod",
-			VerificationMethod: req.IssuerDid + "#keys-1",
-		}
+		log.Printf("Replayed issuance job %s for Idempotency-Key %q of %s", jobID, idempotencyKey, req.IssuerDid)
+		w.Header().Set("Idempotent-Replayed", "true")
+		writeJobAccepted(w, jobID, state)
+		return
+	}
 
-		// Serialize the proof to JSON
-		proofJSON, err := json.Marshal(credential.Proof)
-		if err != nil {
-			log.Printf("Failed to marshal proof to JSON: %v", err)
-			http.Error(w, "Failed to process credential", http.StatusInternalServerError)
-			return
+	// Enqueue the job for the worker
+	if err := jobQueue.Enqueue(ctx, job); err != nil {
+		log.Printf("Failed to enqueue issuance job %s: %v", job.ID, err)
+		if err := updateIssuanceJob(ctx, job.ID, jobStateFailed, 0, 0, "failed to enqueue job"); err != nil {
+			log.Printf("Failed to record outcome of issuance job %s: %v", job.ID, err)
 		}
-
-		log.Printf("Inserting credential with DID: %s", credential.Issuer) // Assuming you use Issuer as DID
-
-		// Loop through each subject in the request
-		for _, subject := range req.Subjects {
-			// Extract subjectID from the current subject
-			subjectID, ok := subject["id"].(string) // Assuming the subject has an "id" field
-			if !ok {
-				log.Printf("Subject ID is missing or not a string for subject: %+v", subject)
-				http.Error(w, "Invalid subject data", http.StatusBadRequest)
-				return
-			}
-
-			// Store the credential in the database for each subject
-			_, err = db.Exec(context.Background(),
-				"INSERT INTO verifiable_credentials (did, issuer, credential, subject, issuance_date, expiration_date, proof) VALUES ($1, $2, $3, $4, $5, $6, $7)",
-				subjectID,      // Use the extracted subject ID for each subject
-				req.IssuerDid,  // Issuer DID
-				credentialJSON, // Credential JSON
-				subject,        // Insert the current subject's properties
-				credential.IssuanceDate,
-				credential.ExpirationDate,
-				proofJSON, // Insert the proof JSON here
-			)
-
-			if err != nil {
-				if err.Error() == "ERROR: duplicate key value violates unique constraint \"verifiable_credentials_pkey\"" {
-					log.Printf("Duplicate key for subject %v. Attempting to regenerate credential ID.", subject)
-					// Optionally, you can generate a new credential ID and retry the insertion
-					continue // Skip or handle the error as needed
-				} else {
-					log.Printf("Error inserting credential for subject %v: %v", subject, err)
-				}
+		// Let the client retry with the same key
+		if idempotencyKey != "" {
+			if err := releaseIdempotencyKey(ctx, req.IssuerDid, idempotencyKey, job.ID); err != nil {
+				log.Printf("Failed to release Idempotency-Key of issuance job %s: %v", job.ID, err)
 			}
 		}
-
-		// Respond with the generated credential
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(credential); err != nil {
-			log.Printf("Failed to encode response: %v", err)
-			http.Error(w, "Failed to issue credential", http.StatusInternalServerError)
-			return
-		}
-
-		log.Printf("Credential issued successfully: %v", credential)
-	}
-}
-
-/*
-func loadCustomerSchema(s string) {
-	panic("unimplemented")
-}
-*/
-
-// Additional functions for Vault, parsing, signing, etc.
-
-// Function to retrieve the private key from HashiCorp Vault
-func getPrivateKeyFromVault(issuerDid string, client *api.Client) (string, error) {
-	// Assuming the path to the secret in Vault is structured as "secret/data/dids/<issuerDid>"
-	secretPath := fmt.Sprintf("secret/data/dids/%s", issuerDid)
-	log.Println("Secrets path --> We are looking for secrets here: ", secretPath)
-
-	// Fetch the secret from Vault
-	secret, err := client.Logical().Read(secretPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read secret from Vault: %w", err)
-	}
-	if secret == nil {
-		return "", fmt.Errorf("no secret found at path: %s", secretPath)
-	}
-
-	// Retrieve the base64-encoded private key from the secret data
-	data, ok := secret.Data["data"].(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("secret data is not in the expected format")
-	}
-
-	privateKeyBase64, ok := data["private_key"].(string)
-	if !ok {
-		return "", fmt.Errorf("private key not found in secret data")
+		http.Error(w, "Failed to process request", http.StatusInternalServerError)
+		return
 	}
 
-	return privateKeyBase64, nil
+	log.Printf("Queued issuance job %s for %d subjects of %s", job.ID, len(req.Subjects), req.IssuerDid)
+	writeJobAccepted(w, job.ID, jobStateQueued)
 }
 
-// Function to parse the base64-encoded Ed25519 private key
-func parseEd25519PrivateKeyFromBase64(base64Key string) ([]byte, error) {
-	// Decode the base64 string
-	privateKeyBytes, err := base64.StdEncoding.DecodeString(base64Key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 private key: %w", err)
-	}
-	return privateKeyBytes, nil
+// writeJobAccepted responds with the ID and state of an issuance job
+func writeJobAccepted(w http.ResponseWriter, jobID, state string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/v1/credential/jobs/"+jobID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"jobId": jobID, "state": state})
 }
 
-// Function to sign the credential using the Ed25519 private key
-func signCredential(privateKey []byte, credentialJSON []byte) ([]byte, error) {
-	// Use your signing logic here.
-	// Placeholder: In practice, use a library to sign with the Ed25519 key
-	signature := []byte("SIGNATURE") // Replace with actual signing logic
-
-	return signature, nil
+// writeInvalidSubjects responds with the field errors of the subjects that do not follow the
+// request's schema
+func writeInvalidSubjects(w http.ResponseWriter, schemaID string, invalid []SubjectErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":    "subjects do not follow the schema",
+		"schemaId": schemaID,
+		"subjects": invalid,
+	})
 }
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bradtumy/credential-service/dataintegrity"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// Idempotency of POST /v1/credential: a request carrying an Idempotency-Key that an issuer
+// already used within the retention window returns the original job instead of creating a new
+// one, and a subject with an external reference the issuer already issued a credential for
+// within the window gets that credential instead of a new one.
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// externalReferenceField is the subject field holding the caller's reference for the
+	// subject, e.g. an employee number. It is not a claim and is left out of the credential.
+	externalReferenceField = "externalReference"
+
+	defaultIdempotencyRetention = 24 * time.Hour
+	maxIdempotencyKeyLength     = 255
+)
+
+var (
+	// errIdempotencyKeyReused is returned when an Idempotency-Key is used again for a different
+	// request
+	errIdempotencyKeyReused = errors.New("Idempotency-Key was already used for a different request")
+	// errInvalidExternalReference is returned for external references that are not strings
+	errInvalidExternalReference = errors.New("externalReference must be a non-empty string")
+)
+
+// idempotencyRetention returns how long Idempotency-Keys and external references are remembered,
+// set with IDEMPOTENCY_RETENTION (e.g. "72h")
+func idempotencyRetention() time.Duration {
+	if value := os.Getenv("IDEMPOTENCY_RETENTION"); value != "" {
+		if retention, err := time.ParseDuration(value); err == nil && retention > 0 {
+			return retention
+		}
+	}
+	return defaultIdempotencyRetention
+}
+
+// externalReference returns the external reference of a subject, if it has one
+func externalReference(subject map[string]interface{}) (string, error) {
+	value, ok := subject[externalReferenceField]
+	if !ok {
+		return "", nil
+	}
+	reference, ok := value.(string)
+	if !ok || reference == "" {
+		return "", errInvalidExternalReference
+	}
+	return reference, nil
+}
+
+// subjectClaims returns the claims of a subject, without its external reference
+func subjectClaims(subject map[string]interface{}) map[string]interface{} {
+	if _, ok := subject[externalReferenceField]; !ok {
+		return subject
+	}
+	claims := make(map[string]interface{}, len(subject)-1)
+	for name, value := range subject {
+		if name != externalReferenceField {
+			claims[name] = value
+		}
+	}
+	return claims
+}
+
+// requestHash fingerprints an issuance request, to tell a replay from a different request that
+// reuses its Idempotency-Key
+func requestHash(req CredentialRequest) (string, error) {
+	canonical, err := dataintegrity.Canonicalize(req)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(canonical)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// claimIdempotencyKey records that the issuer's Idempotency-Key belongs to the new job, unless
+// it already belongs to a job of the retention window: that job's ID is returned then. A
+// concurrent claim of the same key waits for the transaction holding it.
+func claimIdempotencyKey(ctx context.Context, tx pgx.Tx, issuerDid, key, hash, jobID string) (string, error) {
+	now := time.Now().UTC()
+	var claimedJobID, claimedHash string
+	err := tx.QueryRow(ctx,
+		`INSERT INTO issuance_idempotency_keys (issuer_did, idempotency_key, request_hash, job_id, created_at, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (issuer_did, idempotency_key) DO UPDATE
+		 SET request_hash = EXCLUDED.request_hash, job_id = EXCLUDED.job_id, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		 WHERE issuance_idempotency_keys.expires_at <= EXCLUDED.created_at
+		 RETURNING job_id::text, request_hash`,
+		issuerDid, key, hash, jobID, now, now.Add(idempotencyRetention())).Scan(&claimedJobID, &claimedHash)
+	if err == nil {
+		return "", nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
+	}
+
+	// The key is taken and has not expired
+	err = tx.QueryRow(ctx,
+		"SELECT job_id::text, request_hash FROM issuance_idempotency_keys WHERE issuer_did = $1 AND idempotency_key = $2",
+		issuerDid, key).Scan(&claimedJobID, &claimedHash)
+	if err != nil {
+		return "", err
+	}
+	if claimedHash != hash {
+		return "", errIdempotencyKeyReused
+	}
+	return claimedJobID, nil
+}
+
+// releaseIdempotencyKey forgets the Idempotency-Key of a job that could not be queued, so the
+// request can be retried with it
+func releaseIdempotencyKey(ctx context.Context, issuerDid, key, jobID string) error {
+	_, err := db.Exec(ctx,
+		"DELETE FROM issuance_idempotency_keys WHERE issuer_did = $1 AND idempotency_key = $2 AND job_id = $3",
+		issuerDid, key, jobID)
+	return err
+}
+
+// claimExternalReference records that the issuer's external reference belongs to the new
+// credential, unless the issuer already issued a credential for it within the retention window:
+// that credential's ID is returned then. A concurrent claim of the same reference waits for the
+// transaction holding it, so only one credential is issued per reference.
+func claimExternalReference(ctx context.Context, tx pgx.Tx, issuerDid, reference string, credentialID uuid.UUID) (uuid.UUID, error) {
+	now := time.Now().UTC()
+	var claimed string
+	err := tx.QueryRow(ctx,
+		`INSERT INTO issuance_external_references (issuer_did, external_reference, credential_id, created_at, expires_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (issuer_did, external_reference) DO UPDATE
+		 SET credential_id = EXCLUDED.credential_id, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		 WHERE issuance_external_references.expires_at <= EXCLUDED.created_at
+		 RETURNING credential_id::text`,
+		issuerDid, reference, credentialID, now, now.Add(idempotencyRetention())).Scan(&claimed)
+	if err == nil {
+		return uuid.Nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, err
+	}
+
+	err = tx.QueryRow(ctx,
+		"SELECT credential_id::text FROM issuance_external_references WHERE issuer_did = $1 AND external_reference = $2",
+		issuerDid, reference).Scan(&claimed)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to look up external reference: %w", err)
+	}
+	return uuid.Parse(claimed)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExternalReference(t *testing.T) {
+	subject := map[string]interface{}{"id": "did:key:z6MkHolder", "name": "Jane Doe", "externalReference": "employee-42"}
+
+	reference, err := externalReference(subject)
+	if err != nil || reference != "employee-42" {
+		t.Errorf("Expected employee-42, got %q (%v)", reference, err)
+	}
+
+	claims := subjectClaims(subject)
+	if _, ok := claims["externalReference"]; ok || claims["name"] != "Jane Doe" || claims["id"] != "did:key:z6MkHolder" {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+	if _, ok := subject["externalReference"]; !ok {
+		t.Error("subjectClaims modified the subject")
+	}
+
+	if reference, err := externalReference(map[string]interface{}{"id": "did:key:z6MkHolder"}); err != nil || reference != "" {
+		t.Errorf("Expected no reference, got %q (%v)", reference, err)
+	}
+	for _, invalid := range []interface{}{"", 42, nil} {
+		if _, err := externalReference(map[string]interface{}{"externalReference": invalid}); !errors.Is(err, errInvalidExternalReference) {
+			t.Errorf("Expected errInvalidExternalReference for %v, got %v", invalid, err)
+		}
+	}
+}
+
+func TestRequestHash(t *testing.T) {
+	request := func(name string) CredentialRequest {
+		return CredentialRequest{
+			IssuerDid: "did:web:issuer.example.com",
+			Subjects:  []map[string]interface{}{{"id": "did:key:z6MkHolder", "name": name}},
+		}
+	}
+
+	first, err := requestHash(request("Jane Doe"))
+	if err != nil {
+		t.Fatalf("Failed to hash request: %v", err)
+	}
+	if again, _ := requestHash(request("Jane Doe")); again != first {
+		t.Errorf("Expected the same hash for the same request, got %s and %s", first, again)
+	}
+	if other, _ := requestHash(request("John Doe")); other == first {
+		t.Error("Expected a different hash for a different request")
+	}
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// States of an issuance job
+const (
+	jobStateQueued          = "queued"
+	jobStateRunning         = "running"
+	jobStateCompleted       = "completed"        // Every subject was issued a credential
+	jobStatePartiallyFailed = "partially_failed" // Some subjects were issued a credential
+	jobStateFailed          = "failed"           // No subject was issued a credential
+)
+
+// States of a subject of an issuance job
+const (
+	subjectStatePending = "pending"
+	subjectStateIssued  = "issued"
+	subjectStateFailed  = "failed"
+)
+
+var (
+	// errIssuerDeactivated is returned for jobs whose issuer DID was deactivated
+	errIssuerDeactivated = errors.New("issuer DID is deactivated")
+	// errResolverUnavailable is returned when resolver-service cannot be reached or fails, a
+	// condition jobs are retried on
+	errResolverUnavailable = errors.New("resolver-service unavailable")
+)
+
+// verifiableCredentialType is the base type of every credential
+const verifiableCredentialType = "VerifiableCredential"
+
+// issuanceJob is the message the handler enqueues and the worker processes: one credential is
+// issued for each subject of the request
+type issuanceJob struct {
+	ID      string            `json:"id"`
+	Request CredentialRequest `json:"request"`
+
+	// Context and Type of the job's credentials, those of the request's schema followed by the
+	// request's own. Jobs queued without them issue credentials with the base context and type.
+	Context []string `json:"@context,omitempty"`
+	Type    []string `json:"type,omitempty"`
+
+	// Validity of the job's credentials. Jobs queued without it issue credentials valid for
+	// defaultCredentialLifetime.
+	Validity validityPeriod `json:"validity"`
+
+	// DataModel is the version of the data model the credentials are issued in, 1.1 if empty
+	DataModel string `json:"dataModel,omitempty"`
+
+	// CredentialStatus are the credentialStatus entries of the job's credentials, the request's
+	// or else the issuer's
+	CredentialStatus []map[string]interface{} `json:"credentialStatus,omitempty"`
+}
+
+// resolverServiceURL returns the base URL of resolver-service
+func resolverServiceURL() string {
+	if baseURL := os.Getenv("RESOLVER_SERVICE_URL"); baseURL != "" {
+		return baseURL
+	}
+	return "http://resolver-service:8080"
+}
+
+// resolveIssuer resolves the issuer DID and returns the verification method it signs with, the
+// first assertionMethod of its DID document. Deactivated issuers are refused, their keys may be
+// compromised.
+func resolveIssuer(ctx context.Context, issuerDid string) (string, error) {
+	resolverURL := fmt.Sprintf("%s/v1/dids/resolver?did=%s", resolverServiceURL(), url.QueryEscape(issuerDid))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, resolverURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to fetch DID document: %v", errResolverUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return "", fmt.Errorf("%w: %s", errResolverUnavailable, resp.Status)
+	}
+	// Deactivated DIDs still resolve, with 410 Gone, so they can be refused below
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusGone {
+		return "", fmt.Errorf("received non-OK response from resolver: %s", resp.Status)
+	}
+
+	var resolution DIDResolutionResult
+	if err := json.NewDecoder(resp.Body).Decode(&resolution); err != nil || resolution.DIDDocument == nil {
+		return "", fmt.Errorf("failed to decode DID document: %v", err)
+	}
+	if deactivated, _ := resolution.DIDDocumentMetadata["deactivated"].(bool); deactivated {
+		return "", fmt.Errorf("%w: %s", errIssuerDeactivated, issuerDid)
+	}
+
+	return assertionMethodID(resolution.DIDDocument)
+}
+
+// subjectID returns the DID of a subject, which the credential is stored under
+func subjectID(subject map[string]interface{}) (string, error) {
+	id, ok := subject["id"].(string)
+	if !ok || id == "" {
+		return "", errors.New("subject id is missing or not a string")
+	}
+	return id, nil
+}
+
+// appendUnique appends the values that are not in list yet, in order
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// credentialContexts returns the @context of credentials of a data model with additional
+// contexts: the data model's base context comes first, as it requires. Base contexts of the
+// other data model are left out, the two do not mix.
+func credentialContexts(dataModel string, additional ...[]string) []string {
+	contexts := []string{baseContext(dataModel)}
+	for _, list := range additional {
+		for _, contextURL := range list {
+			if contextURL != credentialsContextV1 && contextURL != credentialsContextV2 {
+				contexts = appendUnique(contexts, contextURL)
+			}
+		}
+	}
+	return contexts
+}
+
+// credentialTypes returns the type of credentials with additional types: VerifiableCredential
+// comes first
+func credentialTypes(additional ...[]string) []string {
+	types := []string{verifiableCredentialType}
+	for _, list := range additional {
+		types = appendUnique(types, list...)
+	}
+	return types
+}
+
+// validateContexts checks that additional contexts are absolute URLs
+func validateContexts(contexts []string) error {
+	for _, contextURL := range contexts {
+		if u, err := url.Parse(contextURL); err != nil || !u.IsAbs() {
+			return fmt.Errorf("@context %q is not an absolute URL", contextURL)
+		}
+	}
+	return nil
+}
+
+// validateTypes checks that additional types are names without whitespace
+func validateTypes(types []string) error {
+	for _, credentialType := range types {
+		if credentialType == "" || strings.ContainsAny(credentialType, " \t\r\n") {
+			return fmt.Errorf("type %q is not a valid credential type", credentialType)
+		}
+	}
+	return nil
+}
+
+// newCredential builds the unsigned credential of one subject of a job, in the job's data model.
+// The subject's claims are the credentialSubject, whose id binds the credential to the holder's
+// DID; a credential issued with a schema references it as its credentialSchema. When the
+// credential becomes valid is its issuanceDate in the 1.1 data model and its validFrom in 2.0;
+// credentials that never expire have no expirationDate or validUntil. The job's status entries
+// are its credentialStatus, which the proof covers.
+func newCredential(credentialID uuid.UUID, job issuanceJob, claims map[string]interface{}, issuedAt time.Time) (VerifiableCredential, error) {
+	validFrom, validUntil, err := job.Validity.window(issuedAt)
+	if err != nil {
+		return VerifiableCredential{}, err
+	}
+
+	credential := VerifiableCredential{
+		Context:           credentialContexts(job.DataModel, job.Context),
+		Type:              credentialTypes(job.Type),
+		ID:                "urn:uuid:" + credentialID.String(),
+		Issuer:            job.Request.IssuerDid,
+		CredentialSubject: claims,
+		CredentialStatus:  credentialStatus(job.CredentialStatus, job.DataModel),
+	}
+	from := validFrom.UTC().Format(time.RFC3339)
+	var until string
+	if !validUntil.IsZero() {
+		until = validUntil.UTC().Format(time.RFC3339)
+	}
+	if job.DataModel == dataModelV2 {
+		credential.ValidFrom, credential.ValidUntil = from, until
+	} else {
+		credential.IssuanceDate, credential.ExpirationDate = from, until
+	}
+	if job.Request.SchemaID != "" {
+		credential.CredentialSchema = credentialSchemaFor(job.Request.SchemaID)
+	}
+	return credential, nil
+}
+
+// issueCredentialForSubject signs the credential of the subject at a position of the job and
+// stores it under the job. A subject with an external reference the issuer already issued a
+// credential for gets that credential instead, which is reported as reused. The subject is
+// marked issued in the same transaction, so a retried job never issues it twice.
+func issueCredentialForSubject(ctx context.Context, job issuanceJob, position int, verificationMethod string, subject map[string]interface{}) (uuid.UUID, bool, error) {
+	issuerDid := job.Request.IssuerDid
+	holderDid, err := subjectID(subject)
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	reference, err := externalReference(subject)
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	claims := subjectClaims(subject)
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	credentialID := uuid.New()
+	if reference != "" {
+		existing, err := claimExternalReference(ctx, tx, issuerDid, reference, credentialID)
+		if err != nil {
+			return uuid.Nil, false, fmt.Errorf("failed to claim external reference: %w", err)
+		}
+		if existing != uuid.Nil {
+			if err := markSubjectIssued(ctx, tx, job.ID, position, existing, true); err != nil {
+				return uuid.Nil, false, fmt.Errorf("failed to record subject: %w", err)
+			}
+			if err := tx.Commit(ctx); err != nil {
+				return uuid.Nil, false, fmt.Errorf("failed to record subject: %w", err)
+			}
+			return existing, true, nil
+		}
+	}
+
+	credential, err := newCredential(credentialID, job, claims, time.Now())
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	proof, err := createDataIntegrityProof(ctx, credential, keyStore, verificationMethod)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to sign credential: %w", err)
+	}
+	credential.Proof = proof
+
+	credentialJSON, err := json.Marshal(credential)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to marshal credential: %w", err)
+	}
+	proofJSON, err := json.Marshal(credential.Proof)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to marshal proof: %w", err)
+	}
+
+	// Credentials that never expire have no expiration date
+	issuanceDate, until := credential.validityDates()
+	var expirationDate *string
+	if until != "" {
+		expirationDate = &until
+	}
+	_, err = tx.Exec(ctx,
+		"INSERT INTO verifiable_credentials (id, job_id, did, issuer, credential, subject, issuance_date, expiration_date, proof) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		credentialID, job.ID, holderDid, issuerDid, credentialJSON, claims, issuanceDate, expirationDate, proofJSON)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to store credential: %w", err)
+	}
+	if err := markSubjectIssued(ctx, tx, job.ID, position, credentialID, false); err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to record subject: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to store credential: %w", err)
+	}
+
+	return credentialID, false, nil
+}
+
+// processIssuanceJob issues the credentials of a job and records the outcome of each subject. A
+// job whose issuer cannot sign fails as a whole; otherwise each subject is issued on its own.
+//
+// An error is returned when the job could not be finished because of a failure that may be
+// temporary, such as resolver-service, the key store or the database being unavailable. The job
+// can then be processed again: subjects that were already issued a credential are skipped.
+func processIssuanceJob(ctx context.Context, job issuanceJob) error {
+	log.Printf("Processing issuance job %s for %d subjects", job.ID, len(job.Request.Subjects))
+	if err := markIssuanceJob(ctx, job.ID, jobStateRunning, ""); err != nil {
+		return fmt.Errorf("failed to mark issuance job running: %w", err)
+	}
+
+	alreadyIssued, err := issuedSubjects(ctx, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load subjects of issuance job: %w", err)
+	}
+
+	verificationMethod, err := resolveIssuer(ctx, job.Request.IssuerDid)
+	if err != nil {
+		log.Printf("Issuance job %s failed: %v", job.ID, err)
+		for position := range job.Request.Subjects {
+			if alreadyIssued[position] {
+				continue
+			}
+			if err := recordSubjectFailure(ctx, job.ID, position, err); err != nil {
+				log.Printf("Failed to record subject %d of issuance job %s: %v", position, job.ID, err)
+			}
+		}
+		state := jobStateFailed
+		if len(alreadyIssued) > 0 {
+			state = jobStatePartiallyFailed
+		}
+		if err := updateIssuanceJob(ctx, job.ID, state, len(alreadyIssued), len(job.Request.Subjects)-len(alreadyIssued), err.Error()); err != nil {
+			log.Printf("Failed to record outcome of issuance job %s: %v", job.ID, err)
+		}
+		if errors.Is(err, errResolverUnavailable) {
+			return err
+		}
+		return nil
+	}
+
+	issued, failed := len(alreadyIssued), 0
+	var lastErr error
+	for position, subject := range job.Request.Subjects {
+		if alreadyIssued[position] {
+			continue
+		}
+		credentialID, reused, err := issueCredentialForSubject(ctx, job, position, verificationMethod, subject)
+		switch {
+		case err != nil:
+			log.Printf("Failed to issue credential for subject %d of job %s: %v", position, job.ID, err)
+			failed++
+			lastErr = err
+			if err := recordSubjectFailure(ctx, job.ID, position, err); err != nil {
+				log.Printf("Failed to record subject %d of issuance job %s: %v", position, job.ID, err)
+			}
+		case reused:
+			log.Printf("Reused credential %s of the external reference of subject %d of job %s", credentialID, position, job.ID)
+			issued++
+		default:
+			log.Printf("Issued credential %s for job %s", credentialID, job.ID)
+			issued++
+		}
+	}
+
+	state := jobStateCompleted
+	switch {
+	case issued == 0:
+		state = jobStateFailed
+	case failed > 0:
+		state = jobStatePartiallyFailed
+	}
+	if err := updateIssuanceJob(ctx, job.ID, state, issued, failed, ""); err != nil {
+		return fmt.Errorf("failed to record outcome of issuance job: %w", err)
+	}
+	log.Printf("Issuance job %s %s: %d issued, %d failed", job.ID, state, issued, failed)
+
+	// Subjects only fail to be issued when signing or storing their credential failed
+	if lastErr != nil {
+		return fmt.Errorf("%d subjects failed: %w", failed, lastErr)
+	}
+	return nil
+}
+
+// issuedSubjects returns the positions of the subjects of a job that were issued a credential
+func issuedSubjects(ctx context.Context, jobID string) (map[int]bool, error) {
+	rows, err := db.Query(ctx, "SELECT position FROM issuance_job_subjects WHERE job_id = $1 AND state = $2", jobID, subjectStateIssued)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	issued := map[int]bool{}
+	for rows.Next() {
+		var position int
+		if err := rows.Scan(&position); err != nil {
+			return nil, err
+		}
+		issued[position] = true
+	}
+	return issued, rows.Err()
+}
+
+// createIssuanceJob records a new job in the queued state, with a pending entry for each subject.
+// A request with an Idempotency-Key the issuer used before within the retention window creates
+// no job; the ID of the job the key belongs to is returned instead, with replayed set.
+func createIssuanceJob(ctx context.Context, job issuanceJob, idempotencyKey string) (jobID string, replayed bool, err error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if idempotencyKey != "" {
+		hash, err := requestHash(job.Request)
+		if err != nil {
+			return "", false, err
+		}
+		existing, err := claimIdempotencyKey(ctx, tx, job.Request.IssuerDid, idempotencyKey, hash, job.ID)
+		if err != nil {
+			return "", false, err
+		}
+		if existing != "" {
+			return existing, true, nil
+		}
+	}
+
+	createdAt := time.Now().UTC()
+	_, err = tx.Exec(ctx,
+		"INSERT INTO issuance_jobs (id, issuer_did, state, subject_count, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $5)",
+		job.ID, job.Request.IssuerDid, jobStateQueued, len(job.Request.Subjects), createdAt)
+	if err != nil {
+		return "", false, err
+	}
+	for position, subject := range job.Request.Subjects {
+		holderDid, _ := subjectID(subject)
+		reference, _ := externalReference(subject)
+		_, err = tx.Exec(ctx,
+			"INSERT INTO issuance_job_subjects (job_id, position, subject_id, external_reference, state, updated_at) VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)",
+			job.ID, position, holderDid, reference, subjectStatePending, createdAt)
+		if err != nil {
+			return "", false, err
+		}
+	}
+	return job.ID, false, tx.Commit(ctx)
+}
+
+// updateIssuanceJob records the state of a job and how many of its credentials were issued.
+// jobError explains why a job failed as a whole.
+func updateIssuanceJob(ctx context.Context, jobID, state string, issued, failed int, jobError string) error {
+	_, err := db.Exec(ctx,
+		"UPDATE issuance_jobs SET state = $2, issued_count = $3, failed_count = $4, error = NULLIF($5, ''), updated_at = $6 WHERE id = $1",
+		jobID, state, issued, failed, jobError, time.Now().UTC())
+	return err
+}
+
+// markIssuanceJob records the state of a job without changing its counts
+func markIssuanceJob(ctx context.Context, jobID, state, jobError string) error {
+	_, err := db.Exec(ctx,
+		"UPDATE issuance_jobs SET state = $2, error = NULLIF($3, ''), updated_at = $4 WHERE id = $1",
+		jobID, state, jobError, time.Now().UTC())
+	return err
+}
+
+// markSubjectIssued records the credential issued for a subject of a job, and whether it was
+// reused from the subject's external reference, in the transaction that stored the credential
+func markSubjectIssued(ctx context.Context, tx pgx.Tx, jobID string, position int, credentialID uuid.UUID, reused bool) error {
+	_, err := tx.Exec(ctx,
+		"UPDATE issuance_job_subjects SET state = $3, credential_id = $4, reused = $5, error = NULL, updated_at = $6 WHERE job_id = $1 AND position = $2",
+		jobID, position, subjectStateIssued, credentialID, reused, time.Now().UTC())
+	return err
+}
+
+// recordSubjectFailure records why issuing the credential of a subject of a job failed
+func recordSubjectFailure(ctx context.Context, jobID string, position int, issueErr error) error {
+	_, err := db.Exec(ctx,
+		"UPDATE issuance_job_subjects SET state = $3, credential_id = NULL, reused = false, error = $4, updated_at = $5 WHERE job_id = $1 AND position = $2",
+		jobID, position, subjectStateFailed, issueErr.Error(), time.Now().UTC())
+	return err
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewCredential(t *testing.T) {
+	credentialID := uuid.New()
+	issuedAt := time.Date(2024, 9, 5, 12, 0, 0, 0, time.UTC)
+	claims := map[string]interface{}{"id": "did:key:z6MkHolder", "name": "Jane Doe"}
+	job := issuanceJob{ID: "job-1", Request: CredentialRequest{IssuerDid: "did:key:z6MkIssuer"}}
+
+	credential, err := newCredential(credentialID, job, claims, issuedAt)
+	if err != nil {
+		t.Fatalf("Failed to build credential: %v", err)
+	}
+
+	if credential.ID != "urn:uuid:"+credentialID.String() {
+		t.Errorf("Expected credential ID urn:uuid:%s, got %s", credentialID, credential.ID)
+	}
+	if credential.Issuer != "did:key:z6MkIssuer" {
+		t.Errorf("Unexpected issuer %s", credential.Issuer)
+	}
+	if credential.IssuanceDate != "2024-09-05T12:00:00Z" || credential.ExpirationDate != "2025-09-05T12:00:00Z" {
+		t.Errorf("Unexpected validity %s - %s", credential.IssuanceDate, credential.ExpirationDate)
+	}
+	if credential.CredentialSubject["id"] != "did:key:z6MkHolder" || credential.CredentialSubject["name"] != "Jane Doe" {
+		t.Errorf("Subject missing from credential: %+v", credential.CredentialSubject)
+	}
+	// Jobs queued without types and contexts get the base ones
+	if !reflect.DeepEqual(credential.Context, []string{credentialsContextV1}) || !reflect.DeepEqual(credential.Type, []string{verifiableCredentialType}) {
+		t.Errorf("Unexpected context %v and type %v", credential.Context, credential.Type)
+	}
+	if credential.CredentialSchema != nil {
+		t.Errorf("Expected no credentialSchema, got %+v", credential.CredentialSchema)
+	}
+}
+
+func TestNewCredentialTypesAndSchema(t *testing.T) {
+	t.Setenv("SCHEMA_SERVICE_URL", "https://schemas.example.com")
+	job := issuanceJob{
+		ID:      "job-1",
+		Request: CredentialRequest{IssuerDid: "did:key:z6MkIssuer", SchemaID: "7"},
+		Context: credentialContexts(dataModelV1, []string{"https://example.com/contexts/employee/v1"}),
+		Type:    credentialTypes([]string{"EmployeeBadge"}),
+	}
+
+	credential, err := newCredential(uuid.New(), job, map[string]interface{}{"id": "did:key:z6MkHolder"}, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to build credential: %v", err)
+	}
+
+	if !reflect.DeepEqual(credential.Context, []string{credentialsContextV1, "https://example.com/contexts/employee/v1"}) {
+		t.Errorf("Unexpected context %v", credential.Context)
+	}
+	if !reflect.DeepEqual(credential.Type, []string{verifiableCredentialType, "EmployeeBadge"}) {
+		t.Errorf("Unexpected type %v", credential.Type)
+	}
+	if credential.CredentialSchema == nil || credential.CredentialSchema.ID != "https://schemas.example.com/v1/schemas/7/json-schema" {
+		t.Errorf("Unexpected credentialSchema %+v", credential.CredentialSchema)
+	}
+}
+
+func TestCredentialContextsAndTypes(t *testing.T) {
+	contexts := credentialContexts(dataModelV1,
+		[]string{"https://example.com/schema/v1", credentialsContextV1},
+		[]string{"https://example.com/request/v1", "https://example.com/schema/v1"},
+	)
+	expected := []string{credentialsContextV1, "https://example.com/schema/v1", "https://example.com/request/v1"}
+	if !reflect.DeepEqual(contexts, expected) {
+		t.Errorf("Expected contexts %v, got %v", expected, contexts)
+	}
+
+	types := credentialTypes(nil, []string{"EmployeeBadge", verifiableCredentialType, "EmployeeBadge"})
+	if !reflect.DeepEqual(types, []string{verifiableCredentialType, "EmployeeBadge"}) {
+		t.Errorf("Unexpected types %v", types)
+	}
+}
+
+func TestValidateContextsAndTypes(t *testing.T) {
+	if err := validateContexts([]string{"https://example.com/contexts/v1", "urn:example:context"}); err != nil {
+		t.Errorf("Expected valid contexts, got %v", err)
+	}
+	for _, contextURL := range []string{"", "contexts/v1", "example.com/contexts/v1"} {
+		if err := validateContexts([]string{contextURL}); err == nil {
+			t.Errorf("Expected an error for @context %q", contextURL)
+		}
+	}
+
+	if err := validateTypes([]string{"EmployeeBadge", "https://example.com#Badge"}); err != nil {
+		t.Errorf("Expected valid types, got %v", err)
+	}
+	for _, credentialType := range []string{"", "Employee Badge"} {
+		if err := validateTypes([]string{credentialType}); err == nil {
+			t.Errorf("Expected an error for type %q", credentialType)
+		}
+	}
+}
+
+func TestSubjectID(t *testing.T) {
+	if id, err := subjectID(map[string]interface{}{"id": "did:key:z6MkHolder"}); err != nil || id != "did:key:z6MkHolder" {
+		t.Errorf("Expected did:key:z6MkHolder, got %q (%v)", id, err)
+	}
+	for _, subject := range []map[string]interface{}{{}, {"id": ""}, {"id": 42}} {
+		if _, err := subjectID(subject); err == nil {
+			t.Errorf("Expected an error for subject %+v", subject)
+		}
+	}
+}
+
+// newTestResolver serves a single resolution result as resolver-service would
+func newTestResolver(t *testing.T, status int, resolution DIDResolutionResult) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resolution)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("RESOLVER_SERVICE_URL", server.URL)
+}
+
+func TestResolveIssuer(t *testing.T) {
+	did := "did:web:issuer.example.com"
+	newTestResolver(t, http.StatusOK, DIDResolutionResult{
+		DIDDocument: map[string]interface{}{
+			"id":              did,
+			"assertionMethod": []interface{}{"#keys-1"},
+		},
+	})
+
+	verificationMethod, err := resolveIssuer(context.Background(), did)
+	if err != nil {
+		t.Fatalf("Failed to resolve issuer: %v", err)
+	}
+	if verificationMethod != did+"#keys-1" {
+		t.Errorf("Expected %s#keys-1, got %s", did, verificationMethod)
+	}
+}
+
+func TestResolveIssuerDeactivated(t *testing.T) {
+	did := "did:web:issuer.example.com"
+	newTestResolver(t, http.StatusGone, DIDResolutionResult{
+		DIDDocument:         map[string]interface{}{"id": did, "assertionMethod": []interface{}{"#keys-1"}},
+		DIDDocumentMetadata: map[string]interface{}{"deactivated": true},
+	})
+
+	if _, err := resolveIssuer(context.Background(), did); !errors.Is(err, errIssuerDeactivated) {
+		t.Errorf("Expected errIssuerDeactivated, got %v", err)
+	}
+}
+
+func TestResolveIssuerUnavailable(t *testing.T) {
+	newTestResolver(t, http.StatusBadGateway, DIDResolutionResult{})
+
+	if _, err := resolveIssuer(context.Background(), "did:web:issuer.example.com"); !errors.Is(err, errResolverUnavailable) {
+		t.Errorf("Expected errResolverUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// Page sizes of GET /v1/credential/jobs
+const (
+	defaultJobPageSize = 50
+	maxJobPageSize     = 200
+)
+
+var errInvalidJobFilter = errors.New("invalid job filter")
+
+// IssuanceJob is an issuance job as reported by GET /v1/credential/jobs/{id}. Listings leave out
+// the results of the subjects.
+type IssuanceJob struct {
+	ID           string          `json:"id"`
+	IssuerDid    string          `json:"issuerDid"`
+	State        string          `json:"state"` // queued, running, completed, partially_failed or failed
+	SubjectCount int             `json:"subjectCount"`
+	IssuedCount  int             `json:"issuedCount"`
+	FailedCount  int             `json:"failedCount"`
+	Error        string          `json:"error,omitempty"` // Why the job failed as a whole
+	Subjects     []SubjectResult `json:"subjects,omitempty"`
+	CreatedAt    string          `json:"createdAt"`
+	UpdatedAt    string          `json:"updatedAt"`
+}
+
+// SubjectResult is the outcome of one subject of a job, in the order of the request
+type SubjectResult struct {
+	Index             int    `json:"index"`
+	SubjectID         string `json:"subjectId"`
+	ExternalReference string `json:"externalReference,omitempty"`
+	State             string `json:"state"` // pending, issued or failed
+	CredentialID      string `json:"credentialId,omitempty"`
+	Reused            bool   `json:"reused,omitempty"` // The credential was issued earlier for the external reference
+	Error             string `json:"error,omitempty"`
+}
+
+// IssuanceJobList is a page of issuance jobs, newest first. NextCursor is empty on the last page.
+type IssuanceJobList struct {
+	Jobs       []IssuanceJob `json:"jobs"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// jobListFilter selects a page of jobs for GET /v1/credential/jobs
+type jobListFilter struct {
+	IssuerDid string
+	State     string
+	Limit     int
+	Before    *jobCursor // Last job of the previous page
+}
+
+// jobCursor is the position of a job in the listing order
+type jobCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// jobColumns are the columns scanned by scanIssuanceJob
+const jobColumns = "id::text, issuer_did, state, subject_count, issued_count, failed_count, error, created_at, updated_at"
+
+// scanIssuanceJob reads a job selected with jobColumns
+func scanIssuanceJob(row pgx.Row) (IssuanceJob, time.Time, error) {
+	var job IssuanceJob
+	var jobError *string
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&job.ID, &job.IssuerDid, &job.State, &job.SubjectCount, &job.IssuedCount, &job.FailedCount, &jobError, &createdAt, &updatedAt)
+	if err != nil {
+		return IssuanceJob{}, time.Time{}, err
+	}
+	if jobError != nil {
+		job.Error = *jobError
+	}
+	job.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+	job.UpdatedAt = updatedAt.UTC().Format(time.RFC3339)
+	return job, createdAt, nil
+}
+
+// loadSubjectResults reads the per-subject results of a job
+func loadSubjectResults(ctx context.Context, jobID uuid.UUID) ([]SubjectResult, error) {
+	rows, err := db.Query(ctx,
+		"SELECT position, subject_id, external_reference, state, credential_id::text, reused, error FROM issuance_job_subjects WHERE job_id = $1 ORDER BY position",
+		jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SubjectResult{}
+	for rows.Next() {
+		var result SubjectResult
+		var reference, credentialID, subjectError *string
+		if err := rows.Scan(&result.Index, &result.SubjectID, &reference, &result.State, &credentialID, &result.Reused, &subjectError); err != nil {
+			return nil, err
+		}
+		if reference != nil {
+			result.ExternalReference = *reference
+		}
+		if credentialID != nil {
+			result.CredentialID = *credentialID
+		}
+		if subjectError != nil {
+			result.Error = *subjectError
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// getIssuanceJob reports the state of an issuance job and the outcome of each of its subjects
+func getIssuanceJob(w http.ResponseWriter, r *http.Request) {
+	jobID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Issuance job not found", http.StatusNotFound)
+		return
+	}
+
+	ctx := context.Background()
+	job, _, err := scanIssuanceJob(db.QueryRow(ctx, "SELECT "+jobColumns+" FROM issuance_jobs WHERE id = $1", jobID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Issuance job not found", http.StatusNotFound)
+		} else {
+			log.Printf("Failed to query issuance job: %v", err)
+			http.Error(w, "Failed to retrieve issuance job", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	job.Subjects, err = loadSubjectResults(ctx, jobID)
+	if err != nil {
+		log.Printf("Failed to query subjects of issuance job: %v", err)
+		http.Error(w, "Failed to retrieve issuance job", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// listIssuanceJobs returns a page of issuance jobs, newest first, optionally filtered by issuer
+// DID and state
+func listIssuanceJobs(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseJobListFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	query, args := filter.sql()
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		log.Printf("Failed to query issuance jobs: %v", err)
+		http.Error(w, "Failed to retrieve issuance jobs", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	list := IssuanceJobList{Jobs: []IssuanceJob{}}
+	var last jobCursor
+	for rows.Next() {
+		job, createdAt, err := scanIssuanceJob(rows)
+		if err != nil {
+			log.Printf("Failed to scan issuance job: %v", err)
+			http.Error(w, "Failed to retrieve issuance jobs", http.StatusInternalServerError)
+			return
+		}
+		if len(list.Jobs) == filter.Limit {
+			list.NextCursor = encodeJobCursor(last)
+			break
+		}
+		list.Jobs = append(list.Jobs, job)
+		last = jobCursor{CreatedAt: createdAt, ID: uuid.MustParse(job.ID)}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Failed to read issuance jobs: %v", err)
+		http.Error(w, "Failed to retrieve issuance jobs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// parseJobListFilter reads the filter from the query parameters issuerDid, state, limit and cursor
+func parseJobListFilter(r *http.Request) (jobListFilter, error) {
+	query := r.URL.Query()
+	filter := jobListFilter{
+		IssuerDid: query.Get("issuerDid"),
+		State:     query.Get("state"),
+		Limit:     defaultJobPageSize,
+	}
+
+	switch filter.State {
+	case "", jobStateQueued, jobStateRunning, jobStateCompleted, jobStatePartiallyFailed, jobStateFailed:
+	default:
+		return jobListFilter{}, fmt.Errorf("%w: unknown state %q", errInvalidJobFilter, filter.State)
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxJobPageSize {
+			return jobListFilter{}, fmt.Errorf("%w: limit must be between 1 and %d", errInvalidJobFilter, maxJobPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		before, err := decodeJobCursor(cursor)
+		if err != nil {
+			return jobListFilter{}, err
+		}
+		filter.Before = &before
+	}
+
+	return filter, nil
+}
+
+// sql returns the query selecting the filtered page, newest first. One row more than the page
+// size is selected to tell whether another page follows.
+func (f jobListFilter) sql() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	if f.Before != nil {
+		where("(created_at, id) < ($%d, $%d)", f.Before.CreatedAt, f.Before.ID)
+	}
+	if f.IssuerDid != "" {
+		where("issuer_did = $%d", f.IssuerDid)
+	}
+	if f.State != "" {
+		where("state = $%d", f.State)
+	}
+
+	query := "SELECT " + jobColumns + " FROM issuance_jobs"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, f.Limit+1)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+	return query, args
+}
+
+// encodeJobCursor returns the opaque cursor of the page following the given job
+func encodeJobCursor(cursor jobCursor) string {
+	raw := strconv.FormatInt(cursor.CreatedAt.UnixNano(), 10) + "." + cursor.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeJobCursor(cursor string) (jobCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return jobCursor{}, fmt.Errorf("%w: invalid cursor", errInvalidJobFilter)
+	}
+	parts := strings.SplitN(string(raw), ".", 2)
+	if len(parts) != 2 {
+		return jobCursor{}, fmt.Errorf("%w: invalid cursor", errInvalidJobFilter)
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return jobCursor{}, fmt.Errorf("%w: invalid cursor", errInvalidJobFilter)
+	}
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return jobCursor{}, fmt.Errorf("%w: invalid cursor", errInvalidJobFilter)
+	}
+	return jobCursor{CreatedAt: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
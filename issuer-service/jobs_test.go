@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseJobListFilter(t *testing.T) {
+	cursor := jobCursor{CreatedAt: time.Date(2024, 9, 5, 12, 0, 0, 123456000, time.UTC), ID: uuid.New()}
+	r := httptest.NewRequest("GET", "/v1/credential/jobs?issuerDid=did:web:issuer.example.com&state=partially_failed&limit=10&cursor="+encodeJobCursor(cursor), nil)
+
+	filter, err := parseJobListFilter(r)
+	if err != nil {
+		t.Fatalf("Failed to parse filter: %v", err)
+	}
+	if filter.IssuerDid != "did:web:issuer.example.com" || filter.State != jobStatePartiallyFailed || filter.Limit != 10 {
+		t.Errorf("Unexpected filter %+v", filter)
+	}
+	if filter.Before == nil || !filter.Before.CreatedAt.Equal(cursor.CreatedAt) || filter.Before.ID != cursor.ID {
+		t.Errorf("Cursor did not round trip: %+v", filter.Before)
+	}
+
+	query, args := filter.sql()
+	if !strings.Contains(query, "(created_at, id) < ($1, $2)") || !strings.Contains(query, "issuer_did = $3") ||
+		!strings.Contains(query, "state = $4") || !strings.HasSuffix(query, "ORDER BY created_at DESC, id DESC LIMIT $5") {
+		t.Errorf("Unexpected query %s", query)
+	}
+	if len(args) != 5 || args[4] != 11 {
+		t.Errorf("Unexpected arguments %v", args)
+	}
+}
+
+func TestParseJobListFilterDefaults(t *testing.T) {
+	filter, err := parseJobListFilter(httptest.NewRequest("GET", "/v1/credential/jobs", nil))
+	if err != nil {
+		t.Fatalf("Failed to parse filter: %v", err)
+	}
+	if filter.Limit != defaultJobPageSize || filter.Before != nil {
+		t.Errorf("Unexpected filter %+v", filter)
+	}
+
+	query, args := filter.sql()
+	if strings.Contains(query, "WHERE") || len(args) != 1 {
+		t.Errorf("Unexpected query %s %v", query, args)
+	}
+}
+
+func TestParseJobListFilterInvalid(t *testing.T) {
+	for _, query := range []string{"state=done", "limit=0", "limit=201", "cursor=***", "cursor=bm90LWEtY3Vyc29y"} {
+		r := httptest.NewRequest("GET", "/v1/credential/jobs?"+query, nil)
+		if _, err := parseJobListFilter(r); !errors.Is(err, errInvalidJobFilter) {
+			t.Errorf("Expected errInvalidJobFilter for %s, got %v", query, err)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import "github.com/bradtumy/credential-service/keystore"
+
+// Key types of the keys in the key store
+const (
+	keyTypeEd25519    = keystore.Ed25519
+	keyTypeP256       = keystore.P256
+	keyTypeSecp256k1  = keystore.Secp256k1
+	keyTypeBLS12381G2 = keystore.BLS12381G2
+)
@@ -2,18 +2,22 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"net/http"
-
 	"os"
 
+	"github.com/bradtumy/credential-service/keystore"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/streadway/amqp"
 )
 
 var db *pgxpool.Pool
 
+// jobQueue delivers issuance jobs from the handlers to the worker
+var jobQueue IssuanceQueue
+
+// baseSchema holds the properties every credential has, which subjects cannot claim
+var baseSchema BaseSchema
+
 func initDB() {
 	var err error
 	db, err = pgxpool.Connect(context.Background(), os.Getenv("DATABASE_URL"))
@@ -25,44 +29,33 @@ func initDB() {
 }
 
 func main() {
-
-	// debug
-
-	rabbitmqHost := os.Getenv("RABBITMQ_HOST")
-	rabbitmqPort := os.Getenv("RABBITMQ_PORT")
-	rabbitmqUser := os.Getenv("RABBITMQ_USER") // Add your RabbitMQ username here
-	rabbitmqPass := os.Getenv("RABBITMQ_PASS") // Add your RabbitMQ password here
-
-	// Attempt to connect to RabbitMQ
-	log.Println("In Main Function")
-	conn, err := amqp.Dial(fmt.Sprintf("amqp://%s:%s@%s:%s/", rabbitmqUser, rabbitmqPass, rabbitmqHost, rabbitmqPort))
+	// Open the key store holding the issuers' private keys
+	var err error
+	keyStore, err = keystore.NewFromEnv()
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		log.Fatalf("Failed to open key store: %v", err)
 	}
-	defer conn.Close()
 
-	// If successful, log a success message
-	log.Println("Successfully connected to RabbitMQ with the provided credentials.")
+	// Connect to PostgreSQL database before the worker needs it
+	initDB()
 
-	// end debug
+	// Open the queue issuance jobs are processed from
+	jobQueue, err = newIssuanceQueueFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to open issuance queue: %v", err)
+	}
 
-	go startCredentialIssuanceWorker() // Start the worker in the background
-
-	// Connect to PostgreSQL database
-	initDB()
+	go startCredentialIssuanceWorker(jobQueue) // Start the worker in the background
 
 	// Initialize routes
 	route := InitializeRoutes()
 
 	// Load the base schema at startup
-	baseSchema, err := loadBaseSchema("configs/base-schema.json")
-
+	baseSchema, err = loadBaseSchema("configs/base-schema.json")
 	if err != nil {
 		log.Fatalf("Error loading base schema: %v", err)
 	}
-
-	// Use the base schema as needed in your application
-	log.Printf("Loaded Base Schema: %+v", baseSchema)
+	log.Printf("Loaded base schema with %d properties", len(baseSchema))
 
 	// Start HTTP server
 	log.Println("Credential service running on port 8080...")
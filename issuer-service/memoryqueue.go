@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryQueue is an IssuanceQueue in process memory, for tests and single-process deployments.
+// Jobs that are queued or dead-lettered are lost when the process exits.
+type memoryQueue struct {
+	mu      sync.Mutex
+	pending []*memoryJob // Ordered by availableAt
+	dead    []*memoryJob // Ordered by deadLetteredAt
+	wake    chan struct{}
+
+	// retryDelay returns how long the nth retry of a job waits
+	retryDelay func(retry int) time.Duration
+}
+
+// memoryJob is a job of a memoryQueue
+type memoryJob struct {
+	job            issuanceJob
+	retries        int
+	availableAt    time.Time
+	reason         string
+	deadLetteredAt time.Time
+}
+
+// newMemoryQueue creates an empty in-memory queue
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{wake: make(chan struct{}, 1), retryDelay: retryDelay}
+}
+
+// schedule adds a job to the pending jobs and wakes a waiting consumer. The caller holds q.mu.
+func (q *memoryQueue) schedule(entry *memoryJob) {
+	i := sort.Search(len(q.pending), func(i int) bool { return q.pending[i].availableAt.After(entry.availableAt) })
+	q.pending = append(q.pending, nil)
+	copy(q.pending[i+1:], q.pending[i:])
+	q.pending[i] = entry
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Enqueue adds a job that is available right away
+func (q *memoryQueue) Enqueue(ctx context.Context, job issuanceJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.schedule(&memoryJob{job: job, availableAt: time.Now()})
+	return nil
+}
+
+// next removes and returns the first available job, or reports how long to wait for one
+func (q *memoryQueue) next() (*memoryJob, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return nil, time.Hour
+	}
+	if wait := time.Until(q.pending[0].availableAt); wait > 0 {
+		return nil, wait
+	}
+	entry := q.pending[0]
+	q.pending = q.pending[1:]
+	return entry, 0
+}
+
+// Consume delivers jobs as they become available until ctx is cancelled
+func (q *memoryQueue) Consume(ctx context.Context, handle jobHandler) error {
+	for {
+		entry, wait := q.next()
+		if entry == nil {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-q.wake:
+			case <-timer.C:
+			}
+			timer.Stop()
+			continue
+		}
+
+		result := handle(ctx, entry.job, entry.retries)
+
+		q.mu.Lock()
+		switch result.Outcome {
+		case jobRetry:
+			entry.retries++
+			entry.availableAt = time.Now().Add(q.retryDelay(entry.retries))
+			q.schedule(entry)
+		case jobDeadLetter:
+			entry.reason = result.Reason
+			entry.deadLetteredAt = time.Now()
+			q.dead = append(q.dead, entry)
+		}
+		q.mu.Unlock()
+	}
+}
+
+// DeadLetters lists the dead-lettered jobs, oldest first
+func (q *memoryQueue) DeadLetters(ctx context.Context, limit int) ([]DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	deadLetters := []DeadLetter{}
+	for _, entry := range q.dead {
+		if len(deadLetters) == limit {
+			break
+		}
+		deadLetters = append(deadLetters, DeadLetter{
+			JobID:          entry.job.ID,
+			IssuerDid:      entry.job.Request.IssuerDid,
+			SubjectCount:   len(entry.job.Request.Subjects),
+			Retries:        entry.retries,
+			Reason:         entry.reason,
+			DeadLetteredAt: entry.deadLetteredAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return deadLetters, nil
+}
+
+// Replay makes dead-lettered jobs available again, without retries
+func (q *memoryQueue) Replay(ctx context.Context, jobIDs []string) ([]string, error) {
+	wanted := map[string]bool{}
+	for _, jobID := range jobIDs {
+		wanted[jobID] = true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	replayed := []string{}
+	dead := q.dead[:0]
+	for _, entry := range q.dead {
+		if len(wanted) > 0 && !wanted[entry.job.ID] {
+			dead = append(dead, entry)
+			continue
+		}
+		q.schedule(&memoryJob{job: entry.job, availableAt: time.Now()})
+		replayed = append(replayed, entry.job.ID)
+	}
+	q.dead = dead
+	return replayed, nil
+}
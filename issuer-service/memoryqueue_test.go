@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// consumeInBackground runs Consume until the test ends
+func consumeInBackground(t *testing.T, queue IssuanceQueue, handle jobHandler) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		queue.Consume(ctx, handle)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func newTestJob(id string) issuanceJob {
+	return issuanceJob{ID: id, Request: CredentialRequest{
+		IssuerDid: "did:web:issuer.example.com",
+		Subjects:  []map[string]interface{}{{"id": "did:key:z6MkHolder"}},
+	}}
+}
+
+func TestMemoryQueueRetries(t *testing.T) {
+	queue := newMemoryQueue()
+	queue.retryDelay = func(retry int) time.Duration { return time.Millisecond }
+
+	attempts := make(chan int, maxIssuanceRetries)
+	consumeInBackground(t, queue, func(ctx context.Context, job issuanceJob, retries int) jobResult {
+		attempts <- retries
+		if retries < 2 {
+			return jobResult{Outcome: jobRetry, Reason: "resolver-service unavailable"}
+		}
+		return jobResult{Outcome: jobDone}
+	})
+
+	if err := queue.Enqueue(context.Background(), newTestJob("job-1")); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	for expected := 0; expected <= 2; expected++ {
+		select {
+		case retries := <-attempts:
+			if retries != expected {
+				t.Errorf("Expected delivery with %d retries, got %d", expected, retries)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Job was not delivered with %d retries", expected)
+		}
+	}
+
+	select {
+	case retries := <-attempts:
+		t.Errorf("Job delivered again after it was done, with %d retries", retries)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMemoryQueueDeadLetters(t *testing.T) {
+	queue := newMemoryQueue()
+
+	delivered := make(chan string, 4)
+	var replayed atomic.Bool
+	consumeInBackground(t, queue, func(ctx context.Context, job issuanceJob, retries int) jobResult {
+		delivered <- job.ID
+		if job.ID == "job-1" && !replayed.Load() {
+			return jobResult{Outcome: jobDeadLetter, Reason: "retries exhausted"}
+		}
+		return jobResult{Outcome: jobDone}
+	})
+
+	ctx := context.Background()
+	queue.Enqueue(ctx, newTestJob("job-1"))
+	select {
+	case <-delivered:
+	case <-time.After(time.Second):
+		t.Fatal("Job was not delivered")
+	}
+
+	var deadLetters []DeadLetter
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if deadLetters, _ = queue.DeadLetters(ctx, 10); len(deadLetters) > 0 {
+			break
+		}
+	}
+	if len(deadLetters) != 1 || deadLetters[0].JobID != "job-1" || deadLetters[0].Reason != "retries exhausted" ||
+		deadLetters[0].IssuerDid != "did:web:issuer.example.com" || deadLetters[0].SubjectCount != 1 {
+		t.Fatalf("Unexpected dead letters %+v", deadLetters)
+	}
+
+	// Replaying other jobs leaves the dead letter alone
+	if ids, _ := queue.Replay(ctx, []string{"job-2"}); len(ids) != 0 {
+		t.Errorf("Expected nothing to be replayed, got %v", ids)
+	}
+
+	replayed.Store(true)
+	ids, err := queue.Replay(ctx, nil)
+	if err != nil || len(ids) != 1 || ids[0] != "job-1" {
+		t.Fatalf("Expected job-1 to be replayed, got %v (%v)", ids, err)
+	}
+	select {
+	case id := <-delivered:
+		if id != "job-1" {
+			t.Errorf("Expected job-1 to be delivered again, got %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Replayed job was not delivered")
+	}
+	if deadLetters, _ := queue.DeadLetters(ctx, 10); len(deadLetters) != 0 {
+		t.Errorf("Expected no dead letters after replay, got %+v", deadLetters)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// IssuerPolicy is the issuance policy of an issuer, managed at /v1/admin/issuers/{did}/policy
+type IssuerPolicy struct {
+	IssuerDid   string `json:"issuerDid"`
+	MaxLifetime string `json:"maxLifetime,omitempty"` // ISO 8601 duration credentials may be valid for at most
+	DataModel   string `json:"dataModel,omitempty"`   // Data model of credentials whose request names none
+	UpdatedAt   string `json:"updatedAt,omitempty"`
+
+	// CredentialStatus are the credentialStatus entries of credentials whose request names none
+	CredentialStatus []map[string]interface{} `json:"credentialStatus,omitempty"`
+}
+
+// loadIssuerPolicy returns the policy of an issuer, which is empty if none was set
+func loadIssuerPolicy(ctx context.Context, issuerDid string) (IssuerPolicy, error) {
+	policy := IssuerPolicy{IssuerDid: issuerDid}
+	var maxLifetime, dataModel *string
+	var credentialStatus []byte
+	var updatedAt time.Time
+	err := db.QueryRow(ctx, "SELECT max_lifetime, data_model, credential_status, updated_at FROM issuer_policies WHERE issuer_did = $1", issuerDid).
+		Scan(&maxLifetime, &dataModel, &credentialStatus, &updatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, err
+	}
+	if maxLifetime != nil {
+		policy.MaxLifetime = *maxLifetime
+	}
+	if dataModel != nil {
+		policy.DataModel = *dataModel
+	}
+	if credentialStatus != nil {
+		if err := json.Unmarshal(credentialStatus, &policy.CredentialStatus); err != nil {
+			return policy, err
+		}
+	}
+	policy.UpdatedAt = updatedAt.UTC().Format(time.RFC3339)
+	return policy, nil
+}
+
+// getIssuerPolicy reports the policy of an issuer
+func getIssuerPolicy(w http.ResponseWriter, r *http.Request) {
+	issuerDid := mux.Vars(r)["did"]
+	policy, err := loadIssuerPolicy(context.Background(), issuerDid)
+	if err != nil {
+		log.Printf("Failed to load policy of %s: %v", issuerDid, err)
+		http.Error(w, "Failed to load issuer policy", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// putIssuerPolicy replaces the policy of an issuer. Credentials that were already issued are not
+// affected.
+func putIssuerPolicy(w http.ResponseWriter, r *http.Request) {
+	issuerDid := mux.Vars(r)["did"]
+
+	var policy IssuerPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if policy.MaxLifetime != "" {
+		if _, err := parseISODuration(policy.MaxLifetime); err != nil {
+			http.Error(w, "Invalid maxLifetime: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if policy.DataModel != "" {
+		if err := validateDataModel(policy.DataModel); err != nil {
+			http.Error(w, "Invalid dataModel: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Without a data model of the issuer's own, entries are checked as 2.0 entries; requests for
+	// 1.1 credentials check them again
+	statusDataModel := policy.DataModel
+	if statusDataModel == "" {
+		statusDataModel = dataModelV2
+	}
+	if err := validateCredentialStatus(policy.CredentialStatus, statusDataModel); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var credentialStatus []byte
+	if len(policy.CredentialStatus) > 0 {
+		var err error
+		if credentialStatus, err = json.Marshal(policy.CredentialStatus); err != nil {
+			http.Error(w, "Invalid credentialStatus", http.StatusBadRequest)
+			return
+		}
+	}
+
+	updatedAt := time.Now().UTC()
+	_, err := db.Exec(context.Background(),
+		`INSERT INTO issuer_policies (issuer_did, max_lifetime, data_model, credential_status, updated_at) VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5)
+		 ON CONFLICT (issuer_did) DO UPDATE SET max_lifetime = EXCLUDED.max_lifetime, data_model = EXCLUDED.data_model,
+		 credential_status = EXCLUDED.credential_status, updated_at = EXCLUDED.updated_at`,
+		issuerDid, policy.MaxLifetime, policy.DataModel, credentialStatus, updatedAt)
+	if err != nil {
+		log.Printf("Failed to store policy of %s: %v", issuerDid, err)
+		http.Error(w, "Failed to store issuer policy", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Updated policy of %s: maximum lifetime %q, data model %q, %d credentialStatus entries", issuerDid, policy.MaxLifetime, policy.DataModel, len(policy.CredentialStatus))
+
+	policy.IssuerDid = issuerDid
+	policy.UpdatedAt = updatedAt.Format(time.RFC3339)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
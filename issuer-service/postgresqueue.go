@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// postgresQueuePollInterval is how long an idle worker waits before looking for jobs again
+const postgresQueuePollInterval = time.Second
+
+// postgresQueue is an IssuanceQueue in the issuance_queue table, for deployments without
+// RabbitMQ. Workers claim a job with SELECT ... FOR UPDATE SKIP LOCKED and keep the row locked
+// while processing it, so each job is processed by one worker at a time and the job of a crashed
+// worker is claimed again once its transaction was rolled back.
+type postgresQueue struct {
+	db           *pgxpool.Pool
+	pollInterval time.Duration
+}
+
+// newPostgresQueue creates a queue in the issuance_queue table of the database
+func newPostgresQueue(db *pgxpool.Pool) *postgresQueue {
+	return &postgresQueue{db: db, pollInterval: postgresQueuePollInterval}
+}
+
+// Enqueue inserts a job that is available right away
+func (q *postgresQueue) Enqueue(ctx context.Context, job issuanceJob) error {
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	now := time.Now().UTC()
+	_, err = q.db.Exec(ctx,
+		"INSERT INTO issuance_queue (job_id, job, retries, available_at, created_at) VALUES ($1, $2, 0, $3, $3)",
+		job.ID, jobJSON, now)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue issuance job: %w", err)
+	}
+	return nil
+}
+
+// Consume polls for available jobs until ctx is cancelled
+func (q *postgresQueue) Consume(ctx context.Context, handle jobHandler) error {
+	for {
+		found, err := q.processNext(ctx, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("Failed to process issuance queue: %v", err)
+		}
+		if !found || err != nil {
+			if !sleepContext(ctx, q.pollInterval) {
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// processNext claims the next available job, processes it and records its outcome. It reports
+// whether a job was available.
+func (q *postgresQueue) processNext(ctx context.Context, handle jobHandler) (bool, error) {
+	tx, err := q.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var jobID uuid.UUID
+	var jobJSON []byte
+	var retries int
+	err = tx.QueryRow(ctx,
+		"SELECT job_id, job, retries FROM issuance_queue WHERE dead_lettered_at IS NULL AND available_at <= $1 ORDER BY available_at LIMIT 1 FOR UPDATE SKIP LOCKED",
+		time.Now().UTC()).Scan(&jobID, &jobJSON, &retries)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var result jobResult
+	var job issuanceJob
+	if err := json.Unmarshal(jobJSON, &job); err != nil || job.ID == "" {
+		log.Printf("Failed to decode issuance job %s: %v", jobID, err)
+		result = jobResult{Outcome: jobDeadLetter, Reason: fmt.Sprintf("undecodable issuance job: %v", err)}
+	} else {
+		result = handle(ctx, job, retries)
+	}
+
+	now := time.Now().UTC()
+	switch result.Outcome {
+	case jobRetry:
+		_, err = tx.Exec(ctx, "UPDATE issuance_queue SET retries = $2, available_at = $3 WHERE job_id = $1",
+			jobID, retries+1, now.Add(retryDelay(retries+1)))
+	case jobDeadLetter:
+		_, err = tx.Exec(ctx, "UPDATE issuance_queue SET dead_lettered_at = $2, dead_letter_reason = $3 WHERE job_id = $1",
+			jobID, now, result.Reason)
+	default:
+		_, err = tx.Exec(ctx, "DELETE FROM issuance_queue WHERE job_id = $1", jobID)
+	}
+	if err != nil {
+		return true, fmt.Errorf("failed to record outcome of issuance job %s: %w", jobID, err)
+	}
+	return true, tx.Commit(ctx)
+}
+
+// DeadLetters lists the dead-lettered jobs, oldest first
+func (q *postgresQueue) DeadLetters(ctx context.Context, limit int) ([]DeadLetter, error) {
+	rows, err := q.db.Query(ctx,
+		"SELECT job_id::text, job, retries, dead_letter_reason, dead_lettered_at FROM issuance_queue WHERE dead_lettered_at IS NOT NULL ORDER BY dead_lettered_at LIMIT $1",
+		limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deadLetters := []DeadLetter{}
+	for rows.Next() {
+		var deadLetter DeadLetter
+		var jobJSON []byte
+		var reason *string
+		var deadLetteredAt time.Time
+		if err := rows.Scan(&deadLetter.JobID, &jobJSON, &deadLetter.Retries, &reason, &deadLetteredAt); err != nil {
+			return nil, err
+		}
+		if reason != nil {
+			deadLetter.Reason = *reason
+		}
+		deadLetter.DeadLetteredAt = deadLetteredAt.UTC().Format(time.RFC3339)
+
+		var job issuanceJob
+		if json.Unmarshal(jobJSON, &job) == nil {
+			deadLetter.IssuerDid = job.Request.IssuerDid
+			deadLetter.SubjectCount = len(job.Request.Subjects)
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	return deadLetters, rows.Err()
+}
+
+// Replay makes dead-lettered jobs available again, without retries
+func (q *postgresQueue) Replay(ctx context.Context, jobIDs []string) ([]string, error) {
+	// Job IDs that are not UUIDs cannot be in the queue
+	ids := []string{}
+	for _, jobID := range jobIDs {
+		if id, err := uuid.Parse(jobID); err == nil {
+			ids = append(ids, id.String())
+		}
+	}
+	if len(jobIDs) > 0 && len(ids) == 0 {
+		return []string{}, nil
+	}
+
+	rows, err := q.db.Query(ctx,
+		`UPDATE issuance_queue SET dead_lettered_at = NULL, dead_letter_reason = NULL, retries = 0, available_at = $1
+		 WHERE dead_lettered_at IS NOT NULL AND (cardinality($2::text[]) = 0 OR job_id::text = ANY($2::text[]))
+		 RETURNING job_id::text`,
+		time.Now().UTC(), ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replayed := []string{}
+	for rows.Next() {
+		var jobID string
+		if err := rows.Scan(&jobID); err != nil {
+			return nil, err
+		}
+		replayed = append(replayed, jobID)
+	}
+	return replayed, rows.Err()
+}
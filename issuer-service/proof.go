@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bradtumy/credential-service/dataintegrity"
+	"github.com/mr-tron/base58"
+)
+
+// Data Integrity proof parameters of the credentials we issue
+const (
+	proofTypeDataIntegrity = dataintegrity.ProofType
+	proofPurposeAssertion  = "assertionMethod"
+
+	// multibaseBase58BTC is the multibase prefix for base58btc encoded values
+	multibaseBase58BTC = 'z'
+)
+
+// proofHashData builds the JCS cryptosuites' signing input of a credential, see
+// dataintegrity.HashData
+func proofHashData(credential VerifiableCredential, proof Proof) ([]byte, error) {
+	document, err := dataintegrity.UnsecuredDocument(credential)
+	if err != nil {
+		return nil, err
+	}
+	return dataintegrity.HashData(dataintegrity.ProofConfig{
+		Context:            credential.Context,
+		Type:               proof.Type,
+		Cryptosuite:        proof.Cryptosuite,
+		Created:            proof.Created,
+		ProofPurpose:       proof.ProofPurpose,
+		VerificationMethod: proof.VerificationMethod,
+	}, document)
+}
+
+// createDataIntegrityProof signs the credential with the verification method's key and returns
+// the resulting proof, using the key type's cryptosuite and a base58btc multibase proof value.
+func createDataIntegrityProof(ctx context.Context, credential VerifiableCredential, signer Signer, verificationMethod string) (Proof, error) {
+	key, err := signer.PublicKey(ctx, verificationMethod)
+	if err != nil {
+		return Proof{}, err
+	}
+	cryptosuite, ok := dataintegrity.Cryptosuite(key.Type)
+	if !ok {
+		return Proof{}, fmt.Errorf("unsupported key type: %q", key.Type)
+	}
+
+	proof := Proof{
+		Type:               proofTypeDataIntegrity,
+		Cryptosuite:        cryptosuite,
+		Created:            time.Now().UTC().Format(time.RFC3339),
+		ProofPurpose:       proofPurposeAssertion,
+		VerificationMethod: verificationMethod,
+	}
+
+	hashData, err := proofHashData(credential, proof)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	signature, err := signer.Sign(ctx, verificationMethod, hashData)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	proof.ProofValue = string(multibaseBase58BTC) + base58.Encode(signature)
+	return proof, nil
+}
+
+// assertionMethodID picks the verification method the issuer signs with from its DID document.
+// It prefers the first assertionMethod reference and falls back to the document's key list.
+func assertionMethodID(didDocument map[string]interface{}) (string, error) {
+	did, _ := didDocument["id"].(string)
+
+	for _, field := range []string{"assertionMethod", "verificationMethod", "publicKey"} {
+		entries, ok := didDocument[field].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, entry := range entries {
+			var id string
+			switch method := entry.(type) {
+			case string:
+				id = method
+			case map[string]interface{}:
+				id, _ = method["id"].(string)
+			}
+			if id == "" {
+				continue
+			}
+			// Relative references such as "#keys-1" are scoped to the document's DID
+			if strings.HasPrefix(id, "#") {
+				id = did + id
+			}
+			return id, nil
+		}
+	}
+
+	return "", errors.New("DID document has no verification method for assertions")
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/bradtumy/credential-service/dataintegrity"
+	"github.com/bradtumy/credential-service/keystore"
+	"github.com/cloudflare/circl/sign/bls"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/mr-tron/base58"
+)
+
+// verifyDataIntegrityProof checks the credential's proof against the given raw public key, the
+// way verifiers check the credentials we issue
+func verifyDataIntegrityProof(credential VerifiableCredential, keyType string, publicKey []byte) error {
+	proof := credential.Proof
+	if cryptosuite, _ := dataintegrity.Cryptosuite(keyType); proof.Type != proofTypeDataIntegrity || proof.Cryptosuite != cryptosuite {
+		return fmt.Errorf("unsupported proof type %q with cryptosuite %q for %s key", proof.Type, proof.Cryptosuite, keyType)
+	}
+	if len(proof.ProofValue) < 2 || proof.ProofValue[0] != multibaseBase58BTC {
+		return errors.New("proof value is not base58btc multibase encoded")
+	}
+
+	signature, err := base58.Decode(proof.ProofValue[1:])
+	if err != nil {
+		return fmt.Errorf("failed to decode proof value: %w", err)
+	}
+
+	hashData, err := proofHashData(credential, proof)
+	if err != nil {
+		return err
+	}
+
+	return verifyHashData(keyType, publicKey, hashData, signature)
+}
+
+// verifyHashData checks a proof signature against a raw public key: compressed points for P-256,
+// secp256k1 and BLS12-381 G2 keys. ECDSA signatures are over the SHA-256 digest of the hash data
+// and encoded as the 64 byte concatenation r || s.
+func verifyHashData(keyType string, publicKey, data, signature []byte) error {
+	valid := false
+
+	switch keyType {
+	case keyTypeEd25519:
+		if len(publicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid Ed25519 public key length: %d", len(publicKey))
+		}
+		valid = ed25519.Verify(publicKey, data, signature)
+
+	case keyTypeP256:
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), publicKey)
+		if x == nil {
+			return errors.New("invalid P-256 public key")
+		}
+		if len(signature) != 64 {
+			return fmt.Errorf("invalid P-256 signature length: %d", len(signature))
+		}
+		digest := sha256.Sum256(data)
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		valid = ecdsa.Verify(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, digest[:], r, s)
+
+	case keyTypeSecp256k1:
+		k1PublicKey, err := secp256k1.ParsePubKey(publicKey)
+		if err != nil {
+			return fmt.Errorf("invalid secp256k1 public key: %w", err)
+		}
+		if len(signature) != 64 {
+			return fmt.Errorf("invalid secp256k1 signature length: %d", len(signature))
+		}
+		var r, s secp256k1.ModNScalar
+		if r.SetByteSlice(signature[:32]) || s.SetByteSlice(signature[32:]) {
+			return errors.New("invalid secp256k1 signature")
+		}
+		digest := sha256.Sum256(data)
+		valid = secp256k1ecdsa.NewSignature(&r, &s).Verify(digest[:], k1PublicKey)
+
+	case keyTypeBLS12381G2:
+		var blsPublicKey bls.PublicKey[bls.KeyG2SigG1]
+		if err := blsPublicKey.UnmarshalBinary(publicKey); err != nil {
+			return fmt.Errorf("invalid BLS12-381 public key: %w", err)
+		}
+		valid = bls.Verify(&blsPublicKey, data, signature)
+
+	default:
+		return fmt.Errorf("unsupported key type: %q", keyType)
+	}
+
+	if !valid {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// newTestSigner imports a private key into an in-memory key store for the verification method
+func newTestSigner(t *testing.T, keyID, keyType string, privateKey []byte) Signer {
+	t.Helper()
+
+	ctx := context.Background()
+	store := keystore.NewMemory()
+	key, err := store.Import(ctx, keyType, privateKey)
+	if err != nil {
+		t.Fatalf("Failed to import %s key: %v", keyType, err)
+	}
+	if err := store.Assign(ctx, key, keyID); err != nil {
+		t.Fatalf("Failed to assign %s key: %v", keyType, err)
+	}
+	return store
+}
+
+// newTestIssuer generates an Ed25519 key pair and a did:key DID document for it
+func newTestIssuer(t *testing.T) (ed25519.PrivateKey, map[string]interface{}) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	// did:key identifiers are the multibase encoding of the multicodec-prefixed public key
+	multibaseKey := "z" + base58.Encode(append([]byte{0xed, 0x01}, publicKey...))
+	did := "did:key:" + multibaseKey
+	keyID := did + "#" + multibaseKey
+
+	didDocument := map[string]interface{}{
+		"@context": []interface{}{"https://www.w3.org/ns/did/v1"},
+		"id":       did,
+		"verificationMethod": []interface{}{
+			map[string]interface{}{
+				"id":                 keyID,
+				"type":               "Ed25519VerificationKey2020",
+				"controller":         did,
+				"publicKeyMultibase": multibaseKey,
+			},
+		},
+		"assertionMethod": []interface{}{keyID},
+	}
+
+	return privateKey, didDocument
+}
+
+// publicKeyFromDIDDocument extracts the Ed25519 public key of a verification method
+func publicKeyFromDIDDocument(didDocument map[string]interface{}, keyID string) (ed25519.PublicKey, error) {
+	methods, _ := didDocument["verificationMethod"].([]interface{})
+	for _, entry := range methods {
+		method, _ := entry.(map[string]interface{})
+		if method["id"] != keyID {
+			continue
+		}
+		multibaseKey, _ := method["publicKeyMultibase"].(string)
+		if !strings.HasPrefix(multibaseKey, "z") {
+			return nil, errors.New("public key is not base58btc multibase encoded")
+		}
+		decoded, err := base58.Decode(multibaseKey[1:])
+		if err != nil {
+			return nil, err
+		}
+		if len(decoded) != 2+ed25519.PublicKeySize || decoded[0] != 0xed || decoded[1] != 0x01 {
+			return nil, errors.New("public key is not a multicodec Ed25519 key")
+		}
+		return ed25519.PublicKey(decoded[2:]), nil
+	}
+	return nil, fmt.Errorf("verification method %s not found", keyID)
+}
+
+func newTestCredential(issuer string) VerifiableCredential {
+	return VerifiableCredential{
+		Context:        []string{"https://www.w3.org/2018/credentials/v1"},
+		Type:           []string{"VerifiableCredential"},
+		ID:             "urn:uuid:3978344f-8596-4c3a-a978-8fcaba3903c5",
+		Issuer:         issuer,
+		IssuanceDate:   "2024-10-01T00:00:00Z",
+		ExpirationDate: "2025-10-01T00:00:00Z",
+		CredentialSubject: map[string]interface{}{
+			"id":    "did:example:holder",
+			"name":  "Jane Doe",
+			"email": "jane.doe@example.com",
+			"age":   42,
+		},
+	}
+}
+
+func TestCreateDataIntegrityProofRoundTrip(t *testing.T) {
+	privateKey, didDocument := newTestIssuer(t)
+	issuerDid := didDocument["id"].(string)
+
+	verificationMethod, err := assertionMethodID(didDocument)
+	if err != nil {
+		t.Fatalf("Expected a verification method, got %v", err)
+	}
+
+	credential := newTestCredential(issuerDid)
+	signer := newTestSigner(t, verificationMethod, keyTypeEd25519, privateKey)
+	proof, err := createDataIntegrityProof(context.Background(), credential, signer, verificationMethod)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	credential.Proof = proof
+
+	if proof.Type != "DataIntegrityProof" || proof.Cryptosuite != "eddsa-jcs-2022" {
+		t.Errorf("Unexpected proof type %q / cryptosuite %q", proof.Type, proof.Cryptosuite)
+	}
+	if !strings.HasPrefix(proof.ProofValue, "z") {
+		t.Errorf("Expected base58btc multibase proof value, got %q", proof.ProofValue)
+	}
+	if proof.VerificationMethod != verificationMethod {
+		t.Errorf("Expected verification method %s, got %s", verificationMethod, proof.VerificationMethod)
+	}
+
+	publicKey, err := publicKeyFromDIDDocument(didDocument, proof.VerificationMethod)
+	if err != nil {
+		t.Fatalf("Failed to resolve public key: %v", err)
+	}
+	if err := verifyDataIntegrityProof(credential, keyTypeEd25519, publicKey); err != nil {
+		t.Errorf("Expected signature to verify, got %v", err)
+	}
+}
+
+func TestDataIntegrityProofRejectsTampering(t *testing.T) {
+	privateKey, didDocument := newTestIssuer(t)
+	verificationMethod, _ := assertionMethodID(didDocument)
+	publicKey, err := publicKeyFromDIDDocument(didDocument, verificationMethod)
+	if err != nil {
+		t.Fatalf("Failed to resolve public key: %v", err)
+	}
+
+	credential := newTestCredential(didDocument["id"].(string))
+	signer := newTestSigner(t, verificationMethod, keyTypeEd25519, privateKey)
+	proof, err := createDataIntegrityProof(context.Background(), credential, signer, verificationMethod)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	credential.Proof = proof
+
+	tampered := credential
+	tampered.ExpirationDate = "2099-01-01T00:00:00Z"
+	if err := verifyDataIntegrityProof(tampered, keyTypeEd25519, publicKey); err == nil {
+		t.Error("Expected verification to fail for a modified credential")
+	}
+
+	tampered = credential
+	tampered.Proof.VerificationMethod = didDocument["id"].(string) + "#keys-2"
+	if err := verifyDataIntegrityProof(tampered, keyTypeEd25519, publicKey); err == nil {
+		t.Error("Expected verification to fail for a modified proof configuration")
+	}
+
+	otherKey, _ := newTestIssuer(t)
+	if err := verifyDataIntegrityProof(credential, keyTypeEd25519, otherKey.Public().(ed25519.PublicKey)); err == nil {
+		t.Error("Expected verification to fail with a different public key")
+	}
+}
+
+func TestDataIntegrityProofKeyTypes(t *testing.T) {
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate P-256 key: %v", err)
+	}
+	k1Key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate secp256k1 key: %v", err)
+	}
+	blsKey, err := bls.KeyGen[bls.KeyG2SigG1](make([]byte, 32), nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate BLS12-381 key: %v", err)
+	}
+	blsPrivateKey, _ := blsKey.MarshalBinary()
+	blsPublicKey, _ := blsKey.PublicKey().MarshalBinary()
+
+	tests := []struct {
+		keyType     string
+		privateKey  []byte
+		publicKey   []byte
+		cryptosuite string
+	}{
+		{
+			keyType:     keyTypeP256,
+			privateKey:  p256Key.D.FillBytes(make([]byte, 32)),
+			publicKey:   elliptic.MarshalCompressed(elliptic.P256(), p256Key.X, p256Key.Y),
+			cryptosuite: "ecdsa-jcs-2019",
+		},
+		{
+			keyType:     keyTypeSecp256k1,
+			privateKey:  k1Key.Serialize(),
+			publicKey:   k1Key.PubKey().SerializeCompressed(),
+			cryptosuite: "ecdsa-jcs-2019",
+		},
+		{
+			keyType:     keyTypeBLS12381G2,
+			privateKey:  blsPrivateKey,
+			publicKey:   blsPublicKey,
+			cryptosuite: "bls12381-jcs-2024",
+		},
+	}
+
+	for _, tt := range tests {
+		credential := newTestCredential("did:key:issuer")
+		signer := newTestSigner(t, "did:key:issuer#key-1", tt.keyType, tt.privateKey)
+		proof, err := createDataIntegrityProof(context.Background(), credential, signer, "did:key:issuer#key-1")
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.keyType, err)
+		}
+		if proof.Cryptosuite != tt.cryptosuite {
+			t.Errorf("%s: expected cryptosuite %s, got %s", tt.keyType, tt.cryptosuite, proof.Cryptosuite)
+		}
+		credential.Proof = proof
+
+		if err := verifyDataIntegrityProof(credential, tt.keyType, tt.publicKey); err != nil {
+			t.Errorf("%s: expected signature to verify, got %v", tt.keyType, err)
+		}
+
+		tampered := credential
+		tampered.ExpirationDate = "2099-01-01T00:00:00Z"
+		if err := verifyDataIntegrityProof(tampered, tt.keyType, tt.publicKey); err == nil {
+			t.Errorf("%s: expected verification to fail for a modified credential", tt.keyType)
+		}
+	}
+}
+
+func TestCreateDataIntegrityProofUnknownKey(t *testing.T) {
+	_, err := createDataIntegrityProof(context.Background(), newTestCredential("did:key:issuer"), keystore.NewMemory(), "did:key:issuer#key-1")
+	if !errors.Is(err, keystore.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound for a verification method without a key, got %v", err)
+	}
+}
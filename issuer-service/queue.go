@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Backends accepted in the ISSUANCE_QUEUE environment variable
+const (
+	QueueBackendRabbitMQ = "rabbitmq"
+	QueueBackendPostgres = "postgres"
+	QueueBackendMemory   = "memory"
+)
+
+// Retry policy of failed jobs: the nth retry waits retryBaseDelay * 2^(n-1)
+const (
+	maxIssuanceRetries = 5
+	retryBaseDelay     = 5 * time.Second
+)
+
+// retryDelay returns how long the nth retry of a job waits
+func retryDelay(retry int) time.Duration {
+	return retryBaseDelay << (retry - 1)
+}
+
+// jobOutcome is what a queue does with a job once it was processed
+type jobOutcome int
+
+const (
+	jobDone       jobOutcome = iota // Remove the job from the queue
+	jobRetry                        // Deliver the job again after the delay of its next retry
+	jobDeadLetter                   // Keep the job as a dead letter until it is replayed
+)
+
+// jobResult is the outcome of processing a job, with the reason it is retried or dead-lettered
+type jobResult struct {
+	Outcome jobOutcome
+	Reason  string
+}
+
+// jobHandler processes a job that was retried the given number of times
+type jobHandler func(ctx context.Context, job issuanceJob, retries int) jobResult
+
+// maxDeadLetterBatch bounds how many dead-lettered jobs are listed or replayed at once
+const maxDeadLetterBatch = 1000
+
+// DeadLetter is a dead-lettered issuance job as reported by GET /v1/admin/dead-letters
+type DeadLetter struct {
+	JobID          string `json:"jobId"`
+	IssuerDid      string `json:"issuerDid,omitempty"`
+	SubjectCount   int    `json:"subjectCount"`
+	Retries        int    `json:"retries"`
+	Reason         string `json:"reason"`
+	DeadLetteredAt string `json:"deadLetteredAt"`
+}
+
+// IssuanceQueue delivers issuance jobs to the worker. Jobs are delivered at least once: a job
+// is only removed once its handler returned, so the jobs of a crashed worker are delivered again
+// by the backends that outlive the process.
+type IssuanceQueue interface {
+	// Enqueue adds a job to the queue
+	Enqueue(ctx context.Context, job issuanceJob) error
+	// Consume delivers jobs to handle, one at a time, until ctx is cancelled. Jobs are retried
+	// and dead-lettered as their handler decides.
+	Consume(ctx context.Context, handle jobHandler) error
+	// DeadLetters lists up to limit dead-lettered jobs, oldest first
+	DeadLetters(ctx context.Context, limit int) ([]DeadLetter, error)
+	// Replay moves the dead-lettered jobs with the given IDs, or all of them, back to the queue
+	// with a fresh retry budget, and returns the IDs of the replayed jobs
+	Replay(ctx context.Context, jobIDs []string) ([]string, error)
+}
+
+// newIssuanceQueueFromEnv creates the IssuanceQueue selected by the ISSUANCE_QUEUE environment
+// variable:
+//
+//   - "rabbitmq" (default): RabbitMQ at RABBITMQ_HOST and RABBITMQ_PORT, with RABBITMQ_USER and
+//     RABBITMQ_PASS
+//   - "postgres": the issuance_queue table of the service's database
+//   - "memory": process memory, for tests and single-process deployments; queued jobs are lost
+//     when the process exits
+func newIssuanceQueueFromEnv() (IssuanceQueue, error) {
+	switch backend := os.Getenv("ISSUANCE_QUEUE"); backend {
+	case "", QueueBackendRabbitMQ:
+		return newRabbitMQQueue(rabbitMQURL()), nil
+	case QueueBackendPostgres:
+		return newPostgresQueue(db), nil
+	case QueueBackendMemory:
+		return newMemoryQueue(), nil
+	default:
+		return nil, fmt.Errorf("unknown ISSUANCE_QUEUE backend %q", backend)
+	}
+}
+
+// sleepContext waits for the duration and reports whether ctx is still live afterwards
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
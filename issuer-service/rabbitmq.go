@@ -1,73 +1,403 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
-// Enqueue bulk issuance requests to RabbitMQ
-func enqueueBulkIssuance(req CredentialRequest) error {
+// Queues and exchanges of bulk issuance. Jobs that fail are delayed in the retry queue of their
+// attempt, which dead-letters them back to the issuance queue once their delay expired; jobs that
+// exhausted their retries are published to the dead-letter exchange and kept in the dead-letter
+// queue until they are replayed.
+const (
+	issuanceQueue      = "credential_issuance_queue"
+	issuanceRetryQueue = "credential_issuance_retry" // Suffixed with the number of the retry
+	deadLetterExchange = "credential_issuance.dlx"
+	deadLetterQueue    = "credential_issuance_dead_letter"
 
+	retryCountHeader       = "x-retry-count"
+	deadLetterReasonHeader = "x-dead-letter-reason"
+)
+
+// Delay before the worker reconnects to RabbitMQ, doubled after every failed attempt
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// retryQueueName returns the queue delaying the nth retry of a job
+func retryQueueName(retry int) string {
+	return fmt.Sprintf("%s.%d", issuanceRetryQueue, retry)
+}
+
+// rabbitMQURL builds the AMQP URL from RABBITMQ_HOST, RABBITMQ_PORT, RABBITMQ_USER and RABBITMQ_PASS
+func rabbitMQURL() string {
 	rabbitmqHost := os.Getenv("RABBITMQ_HOST")
 	rabbitmqPort := os.Getenv("RABBITMQ_PORT")
-	rabbitmqUser := os.Getenv("RABBITMQ_USER") // Add your RabbitMQ username here
-	rabbitmqPass := os.Getenv("RABBITMQ_PASS") // Add your RabbitMQ password here
+	rabbitmqUser := os.Getenv("RABBITMQ_USER")
+	rabbitmqPass := os.Getenv("RABBITMQ_PASS")
+	return fmt.Sprintf("amqp://%s:%s@%s:%s/", rabbitmqUser, rabbitmqPass, rabbitmqHost, rabbitmqPort)
+}
+
+// rabbitMQQueue is an IssuanceQueue in RabbitMQ. Its connection is shared by the publisher and
+// the worker, each on channels of their own. It is opened on first use and re-established on the
+// next use once the connection reported that it closed; a failing channel leaves it alone.
+type rabbitMQQueue struct {
+	url  string
+	mu   sync.Mutex
+	conn *amqp.Connection
+}
 
-	// Attempt to connect to RabbitMQ
+// newRabbitMQQueue creates a queue in the broker at url, without connecting to it yet
+func newRabbitMQQueue(url string) *rabbitMQQueue {
+	return &rabbitMQQueue{url: url}
+}
+
+// channel opens a channel, connecting to the broker and declaring the issuance queues first if
+// the service is not connected
+func (q *rabbitMQQueue) channel() (*amqp.Channel, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 
-	log.Println("In enqueueBulkIssuance Function: ")
+	if q.conn == nil || q.conn.IsClosed() {
+		conn, err := amqp.Dial(q.url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+		}
+		if err := declareIssuanceQueues(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		log.Println("Connected to RabbitMQ")
+		q.conn = conn
+		go q.forgetOnClose(conn, conn.NotifyClose(make(chan *amqp.Error, 1)))
+	}
 
-	conn, err := amqp.Dial(fmt.Sprintf("amqp://%s:%s@%s:%s/", rabbitmqUser, rabbitmqPass, rabbitmqHost, rabbitmqPort))
+	ch, err := q.conn.Channel()
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		return nil, fmt.Errorf("failed to open a channel: %w", err)
 	}
-	defer conn.Close()
+	return ch, nil
+}
 
-	// If successful, log a success message
-	log.Println("Successfully connected to RabbitMQ with the provided credentials.")
+// forgetOnClose waits for a connection to close, so the next channel is opened on a new one
+func (q *rabbitMQQueue) forgetOnClose(conn *amqp.Connection, closed <-chan *amqp.Error) {
+	if err := <-closed; err != nil {
+		log.Printf("RabbitMQ connection closed: %v", err)
+	}
 
-	// You can also verify other actions, like checking for existing queues, etc.
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.conn == conn {
+		q.conn = nil
+	}
+}
 
+// declareIssuanceQueues declares the issuance, retry and dead-letter queues. The issuance queue
+// is declared as before, without arguments, so existing brokers accept the declaration.
+func declareIssuanceQueues(conn *amqp.Connection) error {
 	ch, err := conn.Channel()
 	if err != nil {
-		return fmt.Errorf("failed to open a channel: %v", err)
+		return fmt.Errorf("failed to open a channel: %w", err)
 	}
 	defer ch.Close()
 
-	q, err := ch.QueueDeclare(
-		"credential_issuance_queue", // Name of the queue
-		true,                        // Durable
-		false,                       // Delete when unused
-		false,                       // Exclusive
-		false,                       // No-wait
-		nil,                         // Arguments
-	)
+	if _, err := ch.QueueDeclare(issuanceQueue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", issuanceQueue, err)
+	}
+
+	for retry := 1; retry <= maxIssuanceRetries; retry++ {
+		_, err := ch.QueueDeclare(retryQueueName(retry), true, false, false, false, amqp.Table{
+			"x-message-ttl":             int32(retryDelay(retry) / time.Millisecond),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": issuanceQueue,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to declare queue %s: %w", retryQueueName(retry), err)
+		}
+	}
+
+	if err := ch.ExchangeDeclare(deadLetterExchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare exchange %s: %w", deadLetterExchange, err)
+	}
+	if _, err := ch.QueueDeclare(deadLetterQueue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", deadLetterQueue, err)
+	}
+	if err := ch.QueueBind(deadLetterQueue, issuanceQueue, deadLetterExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind queue %s: %w", deadLetterQueue, err)
+	}
+	return nil
+}
+
+// publish publishes a persistent message and waits for the broker to confirm it. A publish that
+// fails, e.g. because the broker restarted, is tried once more on a new channel.
+func (q *rabbitMQQueue) publish(exchange, routingKey string, msg amqp.Publishing) error {
+	msg.DeliveryMode = amqp.Persistent
+	err := q.publishOnce(exchange, routingKey, msg)
 	if err != nil {
-		return fmt.Errorf("failed to declare a queue: %v", err)
+		log.Printf("Failed to publish to RabbitMQ, retrying: %v", err)
+		err = q.publishOnce(exchange, routingKey, msg)
 	}
+	return err
+}
 
-	body, err := json.Marshal(req)
+func (q *rabbitMQQueue) publishOnce(exchange, routingKey string, msg amqp.Publishing) error {
+	ch, err := q.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+
+	if err := ch.Publish(exchange, routingKey, false, false, msg); err != nil {
+		return fmt.Errorf("failed to publish a message: %w", err)
+	}
+	if confirmation, ok := <-confirms; !ok || !confirmation.Ack {
+		return errors.New("broker did not confirm the message")
+	}
+	return nil
+}
+
+// Enqueue publishes a job to the issuance queue
+func (q *rabbitMQQueue) Enqueue(ctx context.Context, job issuanceJob) error {
+	body, err := json.Marshal(job)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	err = ch.Publish(
-		"",     // Exchange
-		q.Name, // Routing key
-		false,  // Mandatory
-		false,  // Immediate
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-		})
+	return q.publish("", issuanceQueue, amqp.Publishing{
+		ContentType: "application/json",
+		MessageId:   job.ID,
+		Timestamp:   time.Now().UTC(),
+		Body:        body,
+	})
+}
+
+// Consume processes issuance jobs until ctx is cancelled, reconnecting whenever the connection
+// to the broker is lost
+func (q *rabbitMQQueue) Consume(ctx context.Context, handle jobHandler) error {
+	delay := minReconnectDelay
+	for {
+		consumed, err := q.consume(ctx, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if consumed {
+			delay = minReconnectDelay
+		}
+		log.Printf("Issuance worker disconnected from RabbitMQ: %v; reconnecting in %v", err, delay)
+		if !sleepContext(ctx, delay) {
+			return ctx.Err()
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// consume processes issuance jobs until the connection to the broker is lost or ctx is
+// cancelled. It reports whether it got as far as consuming.
+func (q *rabbitMQQueue) consume(ctx context.Context, handle jobHandler) (bool, error) {
+	ch, err := q.channel()
 	if err != nil {
-		return fmt.Errorf("failed to publish a message: %v", err)
+		return false, err
 	}
+	defer ch.Close()
 
-	log.Printf("Enqueued bulk issuance request: %+v", req)
-	return nil
+	// Jobs are acknowledged once processed, so one unacknowledged job at a time is enough
+	if err := ch.Qos(1, 0, false); err != nil {
+		return false, fmt.Errorf("failed to set prefetch count: %w", err)
+	}
+
+	msgs, err := ch.Consume(
+		issuanceQueue,
+		"",
+		false, // Acknowledged manually, so jobs of a crashed worker are redelivered
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to register a consumer: %w", err)
+	}
+
+	// Process issuance jobs one at a time
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case msg, ok := <-msgs:
+			if !ok {
+				return true, errors.New("delivery channel closed")
+			}
+			q.handleMessage(ctx, msg, handle)
+		}
+	}
+}
+
+// handleMessage processes the job of a message and settles the message: it is acknowledged
+// once the job is done or handed on to a retry queue or the dead-letter exchange
+func (q *rabbitMQQueue) handleMessage(ctx context.Context, msg amqp.Delivery, handle jobHandler) {
+	var job issuanceJob
+	if err := json.Unmarshal(msg.Body, &job); err != nil || job.ID == "" {
+		log.Printf("Failed to decode issuance job: %v", err)
+		settle(msg, q.deadLetter(msg, fmt.Sprintf("undecodable issuance job: %v", err)))
+		return
+	}
+
+	result := handle(ctx, job, retryCount(msg))
+	switch result.Outcome {
+	case jobRetry:
+		retry := retryCount(msg) + 1
+		settle(msg, q.publish("", retryQueueName(retry), amqp.Publishing{
+			ContentType: msg.ContentType,
+			MessageId:   msg.MessageId,
+			Timestamp:   msg.Timestamp,
+			Headers:     amqp.Table{retryCountHeader: int32(retry)},
+			Body:        msg.Body,
+		}))
+	case jobDeadLetter:
+		settle(msg, q.deadLetter(msg, result.Reason))
+	default:
+		settle(msg, nil)
+	}
+}
+
+// settle acknowledges a message once it was handed on, or returns it to the queue if that failed
+func settle(msg amqp.Delivery, handOffErr error) {
+	if handOffErr != nil {
+		log.Printf("Failed to hand on issuance message %s, requeueing it: %v", msg.MessageId, handOffErr)
+		if err := msg.Nack(false, true); err != nil {
+			log.Printf("Failed to requeue issuance message %s: %v", msg.MessageId, err)
+		}
+		return
+	}
+	if err := msg.Ack(false); err != nil {
+		log.Printf("Failed to acknowledge issuance message %s: %v", msg.MessageId, err)
+	}
+}
+
+// retryCount returns how often the job of a message was retried
+func retryCount(msg amqp.Delivery) int {
+	switch count := msg.Headers[retryCountHeader].(type) {
+	case int32:
+		return int(count)
+	case int64:
+		return int(count)
+	}
+	return 0
+}
+
+// deadLetter publishes a message to the dead-letter exchange with the reason it could not be
+// processed
+func (q *rabbitMQQueue) deadLetter(msg amqp.Delivery, reason string) error {
+	return q.publish(deadLetterExchange, issuanceQueue, amqp.Publishing{
+		ContentType: msg.ContentType,
+		MessageId:   msg.MessageId,
+		Timestamp:   time.Now().UTC(),
+		Headers: amqp.Table{
+			retryCountHeader:       int32(retryCount(msg)),
+			deadLetterReasonHeader: reason,
+		},
+		Body: msg.Body,
+	})
+}
+
+// newDeadLetter describes a message of the dead-letter queue
+func newDeadLetter(msg amqp.Delivery) DeadLetter {
+	deadLetter := DeadLetter{
+		JobID:          msg.MessageId,
+		Retries:        retryCount(msg),
+		DeadLetteredAt: msg.Timestamp.UTC().Format(time.RFC3339),
+	}
+	deadLetter.Reason, _ = msg.Headers[deadLetterReasonHeader].(string)
+
+	var job issuanceJob
+	if json.Unmarshal(msg.Body, &job) == nil {
+		deadLetter.JobID = job.ID
+		deadLetter.IssuerDid = job.Request.IssuerDid
+		deadLetter.SubjectCount = len(job.Request.Subjects)
+	}
+	return deadLetter
+}
+
+// DeadLetters lists messages of the dead-letter queue without removing them
+func (q *rabbitMQQueue) DeadLetters(ctx context.Context, limit int) ([]DeadLetter, error) {
+	ch, err := q.channel()
+	if err != nil {
+		return nil, err
+	}
+	// Closing the channel returns every message read below to the queue, in order
+	defer ch.Close()
+
+	deadLetters := []DeadLetter{}
+	for len(deadLetters) < limit {
+		msg, ok, err := ch.Get(deadLetterQueue, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dead-letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+		deadLetters = append(deadLetters, newDeadLetter(msg))
+	}
+	return deadLetters, nil
+}
+
+// Replay publishes dead-lettered jobs to the issuance queue again, without a retry count
+func (q *rabbitMQQueue) Replay(ctx context.Context, jobIDs []string) ([]string, error) {
+	wanted := map[string]bool{}
+	for _, jobID := range jobIDs {
+		wanted[jobID] = true
+	}
+
+	ch, err := q.channel()
+	if err != nil {
+		return nil, err
+	}
+	// Closing the channel returns the messages that were read but not replayed to the queue
+	defer ch.Close()
+
+	replayed := []string{}
+	for read := 0; read < maxDeadLetterBatch; read++ {
+		msg, ok, err := ch.Get(deadLetterQueue, false)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to read dead-letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+		deadLetter := newDeadLetter(msg)
+		if len(wanted) > 0 && !wanted[deadLetter.JobID] {
+			continue
+		}
+
+		err = q.publish("", issuanceQueue, amqp.Publishing{
+			ContentType: msg.ContentType,
+			MessageId:   msg.MessageId,
+			Timestamp:   time.Now().UTC(),
+			Body:        msg.Body,
+		})
+		if err != nil {
+			return replayed, fmt.Errorf("failed to replay issuance job %s: %w", deadLetter.JobID, err)
+		}
+		if err := msg.Ack(false); err != nil {
+			return replayed, fmt.Errorf("failed to remove issuance job %s from the dead-letter queue: %w", deadLetter.JobID, err)
+		}
+		replayed = append(replayed, deadLetter.JobID)
+	}
+	return replayed, nil
 }
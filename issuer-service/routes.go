@@ -26,6 +26,15 @@ func InitializeRoutes() *mux.Router {
 	// Version 1 routes
 	v1 := r.PathPrefix("/v1").Subrouter()
 	v1.Handle("/credential", LoggingMiddleware(http.HandlerFunc(issueCredential))).Methods("POST", "GET")
+	v1.Handle("/credential/jobs", LoggingMiddleware(http.HandlerFunc(listIssuanceJobs))).Methods("GET")
+	v1.Handle("/credential/jobs/{id}", LoggingMiddleware(http.HandlerFunc(getIssuanceJob))).Methods("GET")
+
+	// Admin routes, to be restricted to operators by the gateway
+	admin := v1.PathPrefix("/admin").Subrouter()
+	admin.Handle("/dead-letters", LoggingMiddleware(http.HandlerFunc(listDeadLetters))).Methods("GET")
+	admin.Handle("/dead-letters/replay", LoggingMiddleware(http.HandlerFunc(replayDeadLettersHandler))).Methods("POST")
+	admin.Handle("/issuers/{did}/policy", LoggingMiddleware(http.HandlerFunc(getIssuerPolicy))).Methods("GET")
+	admin.Handle("/issuers/{did}/policy", LoggingMiddleware(http.HandlerFunc(putIssuerPolicy))).Methods("PUT")
 
 	return r
 }
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// credentialSchemaType is the type of the credentialSchema of issued credentials, a JSON Schema
+// rendered by schema-service
+const credentialSchemaType = "JsonSchema"
+
+var (
+	// errSchemaNotFound is returned for schemaIds schema-service does not know
+	errSchemaNotFound = errors.New("schema not found")
+	// errSchemaServiceUnavailable is returned when schema-service cannot be reached or fails
+	errSchemaServiceUnavailable = errors.New("schema-service unavailable")
+)
+
+// CredentialSchema is the credentialSchema of a credential, the JSON Schema its claims follow
+type CredentialSchema struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// FieldError is a claim of a subject that does not follow the schema
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// SubjectErrors are the field errors of the subject at Index of an issuance request
+type SubjectErrors struct {
+	Index  int          `json:"index"`
+	ID     string       `json:"id,omitempty"`
+	Errors []FieldError `json:"errors"`
+}
+
+// schemaServiceURL returns the base URL of schema-service. Credentials reference their schema
+// under it, so verifiers must be able to reach it too.
+func schemaServiceURL() string {
+	if baseURL := os.Getenv("SCHEMA_SERVICE_URL"); baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	return "http://schema-service:8080"
+}
+
+// credentialSchemaFor returns the credentialSchema of credentials issued with a schema
+func credentialSchemaFor(schemaID string) *CredentialSchema {
+	return &CredentialSchema{
+		ID:   fmt.Sprintf("%s/v1/schemas/%s/json-schema", schemaServiceURL(), url.PathEscape(schemaID)),
+		Type: credentialSchemaType,
+	}
+}
+
+// fetchSchema fetches a schema from schema-service
+func fetchSchema(ctx context.Context, schemaID string) (Schema, error) {
+	var schema Schema
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	schemaURL := fmt.Sprintf("%s/v1/schemas/%s", schemaServiceURL(), url.PathEscape(schemaID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, schemaURL, nil)
+	if err != nil {
+		return schema, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return schema, fmt.Errorf("%w: failed to fetch schema: %v", errSchemaServiceUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return schema, fmt.Errorf("%w: %s", errSchemaNotFound, schemaID)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return schema, fmt.Errorf("%w: %s", errSchemaServiceUnavailable, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&schema); err != nil {
+		return schema, fmt.Errorf("%w: failed to decode schema: %v", errSchemaServiceUnavailable, err)
+	}
+	return schema, nil
+}
+
+// validateSubject checks the claims of a subject against a schema: required properties must be
+// present, present properties must have the property's type, and claims the schema does not
+// define are refused, except for the subject's id. Claims named after a property of the base
+// schema are refused too, the credential itself carries those.
+func validateSubject(schema Schema, base BaseSchema, subject map[string]interface{}) []FieldError {
+	claims := subjectClaims(subject)
+	fieldErrors := []FieldError{}
+
+	defined := map[string]bool{}
+	for _, property := range schema.Properties {
+		defined[property.Name] = true
+		value, present := claims[property.Name]
+		if !present || value == nil {
+			if property.Required {
+				fieldErrors = append(fieldErrors, FieldError{Field: property.Name, Message: "is required"})
+			}
+			continue
+		}
+		if message := checkType(property.Type, value); message != "" {
+			fieldErrors = append(fieldErrors, FieldError{Field: property.Name, Message: message})
+		}
+	}
+
+	// Report the remaining claims in a stable order
+	names := make([]string, 0, len(claims))
+	for name := range claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch {
+		case base.Reserves(name):
+			fieldErrors = append(fieldErrors, FieldError{Field: name, Message: "is reserved for the credential"})
+		case !defined[name] && name != "id":
+			fieldErrors = append(fieldErrors, FieldError{Field: name, Message: "is not defined in the schema"})
+		}
+	}
+	return fieldErrors
+}
+
+// checkType reports how a claim value does not have a property type, or "" if it does. Values
+// are as decoded from JSON, numbers being float64.
+func checkType(propertyType string, value interface{}) string {
+	switch strings.ToLower(propertyType) {
+	case "string":
+		if _, ok := value.(string); !ok {
+			return "must be a string"
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return "must be a number"
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != math.Trunc(number) {
+			return "must be an integer"
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return "must be a boolean"
+		}
+	case "object":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return "must be an object"
+		}
+	case "array":
+		if _, ok := value.([]interface{}); !ok {
+			return "must be an array"
+		}
+	case "date":
+		if text, ok := value.(string); !ok || !parses("2006-01-02", text) {
+			return "must be a date (YYYY-MM-DD)"
+		}
+	case "date-time":
+		if text, ok := value.(string); !ok || !parses(time.RFC3339, text) {
+			return "must be an RFC 3339 date-time"
+		}
+	default:
+		return fmt.Sprintf("has unsupported schema type %q", propertyType)
+	}
+	return ""
+}
+
+// parses reports whether text is a time in the layout
+func parses(layout, text string) bool {
+	_, err := time.Parse(layout, text)
+	return err == nil
+}
+
+// validateSubjects checks every subject of a request against a schema and returns the subjects
+// that do not follow it
+func validateSubjects(schema Schema, base BaseSchema, subjects []map[string]interface{}) []SubjectErrors {
+	invalid := []SubjectErrors{}
+	for i, subject := range subjects {
+		if fieldErrors := validateSubject(schema, base, subject); len(fieldErrors) > 0 {
+			id, _ := subjectID(subject)
+			invalid = append(invalid, SubjectErrors{Index: i, ID: id, Errors: fieldErrors})
+		}
+	}
+	return invalid
+}
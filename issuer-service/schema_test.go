@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var testSchema = Schema{
+	ID:         "7",
+	SchemaName: "EmployeeBadge",
+	Properties: []Property{
+		{Name: "name", Type: "string", Required: true},
+		{Name: "employeeNumber", Type: "integer", Required: true},
+		{Name: "salaryBand", Type: "number"},
+		{Name: "manager", Type: "boolean"},
+		{Name: "address", Type: "object"},
+		{Name: "roles", Type: "array"},
+		{Name: "startDate", Type: "date"},
+		{Name: "badgePrintedAt", Type: "date-time"},
+	},
+}
+
+var testBaseSchema = BaseSchema{"issuer": {Name: "issuer", Type: "string", Required: true}}
+
+func TestValidateSubject(t *testing.T) {
+	valid := map[string]interface{}{
+		"id":                "did:key:z6MkHolder",
+		"externalReference": "employee-42",
+		"name":              "Jane Doe",
+		"employeeNumber":    float64(42),
+		"salaryBand":        3.5,
+		"manager":           true,
+		"address":           map[string]interface{}{"city": "Ottawa"},
+		"roles":             []interface{}{"admin"},
+		"startDate":         "2024-09-05",
+		"badgePrintedAt":    "2024-09-05T12:00:00Z",
+	}
+	if fieldErrors := validateSubject(testSchema, testBaseSchema, valid); len(fieldErrors) != 0 {
+		t.Errorf("Expected a valid subject, got %+v", fieldErrors)
+	}
+
+	invalid := map[string]interface{}{
+		"id":             "did:key:z6MkHolder",
+		"employeeNumber": 42.5,
+		"salaryBand":     "3",
+		"manager":        "yes",
+		"address":        "Ottawa",
+		"roles":          "admin",
+		"startDate":      "05/09/2024",
+		"badgePrintedAt": "2024-09-05",
+		"nickname":       "JD",
+		"issuer":         "did:key:z6MkOther",
+	}
+	expected := []FieldError{
+		{Field: "name", Message: "is required"},
+		{Field: "employeeNumber", Message: "must be an integer"},
+		{Field: "salaryBand", Message: "must be a number"},
+		{Field: "manager", Message: "must be a boolean"},
+		{Field: "address", Message: "must be an object"},
+		{Field: "roles", Message: "must be an array"},
+		{Field: "startDate", Message: "must be a date (YYYY-MM-DD)"},
+		{Field: "badgePrintedAt", Message: "must be an RFC 3339 date-time"},
+		{Field: "issuer", Message: "is reserved for the credential"},
+		{Field: "nickname", Message: "is not defined in the schema"},
+	}
+	if fieldErrors := validateSubject(testSchema, testBaseSchema, invalid); !reflect.DeepEqual(fieldErrors, expected) {
+		t.Errorf("Unexpected field errors:\n got %+v\nwant %+v", fieldErrors, expected)
+	}
+}
+
+func TestValidateSubjectUnsupportedType(t *testing.T) {
+	schema := Schema{Properties: []Property{{Name: "photo", Type: "binary"}}}
+	fieldErrors := validateSubject(schema, nil, map[string]interface{}{"id": "did:key:z6MkHolder", "photo": "..."})
+	if len(fieldErrors) != 1 || fieldErrors[0].Field != "photo" {
+		t.Errorf("Expected an unsupported type error, got %+v", fieldErrors)
+	}
+}
+
+func TestValidateSubjects(t *testing.T) {
+	subjects := []map[string]interface{}{
+		{"id": "did:key:z6MkFirst", "name": "Jane Doe", "employeeNumber": float64(1)},
+		{"id": "did:key:z6MkSecond", "name": "John Doe"},
+	}
+	invalid := validateSubjects(testSchema, testBaseSchema, subjects)
+	if len(invalid) != 1 || invalid[0].Index != 1 || invalid[0].ID != "did:key:z6MkSecond" ||
+		len(invalid[0].Errors) != 1 || invalid[0].Errors[0].Field != "employeeNumber" {
+		t.Errorf("Unexpected invalid subjects %+v", invalid)
+	}
+}
+
+// newTestSchemaService serves schemas as schema-service would
+func newTestSchemaService(t *testing.T, status int, schema Schema) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/schemas/"+schema.ID {
+			http.Error(w, "Schema not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(schema)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("SCHEMA_SERVICE_URL", server.URL)
+}
+
+func TestFetchSchema(t *testing.T) {
+	newTestSchemaService(t, http.StatusOK, testSchema)
+
+	schema, err := fetchSchema(context.Background(), "7")
+	if err != nil {
+		t.Fatalf("Failed to fetch schema: %v", err)
+	}
+	if !reflect.DeepEqual(schema, testSchema) {
+		t.Errorf("Unexpected schema %+v", schema)
+	}
+
+	if _, err := fetchSchema(context.Background(), "8"); !errors.Is(err, errSchemaNotFound) {
+		t.Errorf("Expected errSchemaNotFound, got %v", err)
+	}
+}
+
+func TestFetchSchemaUnavailable(t *testing.T) {
+	newTestSchemaService(t, http.StatusInternalServerError, testSchema)
+	if _, err := fetchSchema(context.Background(), "7"); !errors.Is(err, errSchemaServiceUnavailable) {
+		t.Errorf("Expected errSchemaServiceUnavailable, got %v", err)
+	}
+
+	t.Setenv("SCHEMA_SERVICE_URL", "http://127.0.0.1:1")
+	if _, err := fetchSchema(context.Background(), "7"); !errors.Is(err, errSchemaServiceUnavailable) {
+		t.Errorf("Expected errSchemaServiceUnavailable, got %v", err)
+	}
+}
+
+func TestCredentialSchemaFor(t *testing.T) {
+	t.Setenv("SCHEMA_SERVICE_URL", "https://schemas.example.com/")
+	expected := &CredentialSchema{ID: "https://schemas.example.com/v1/schemas/7/json-schema", Type: "JsonSchema"}
+	if credentialSchema := credentialSchemaFor("7"); !reflect.DeepEqual(credentialSchema, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, credentialSchema)
+	}
+}
+
+func TestLoadBaseSchema(t *testing.T) {
+	base, err := loadBaseSchema("configs/base-schema.json")
+	if err != nil {
+		t.Fatalf("Failed to load base schema: %v", err)
+	}
+	if !base.Reserves("issuer") || base["issuer"].Name != "issuer" || base.Reserves("name") {
+		t.Errorf("Unexpected base schema %+v", base)
+	}
+}
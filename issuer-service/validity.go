@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultCredentialLifetime is how long credentials are valid when neither the request nor its
+// schema says otherwise
+const defaultCredentialLifetime = "P1Y"
+
+// maxValidFromBackdate is how far before the request a requested validFrom may lie
+const maxValidFromBackdate = 24 * time.Hour
+
+var (
+	// errInvalidValidity is returned for validity periods that cannot be honoured as requested
+	errInvalidValidity = errors.New("invalid validity period")
+	// errLifetimeExceeded is returned for validity periods longer than the issuer allows
+	errLifetimeExceeded = errors.New("validity period exceeds the issuer's maximum credential lifetime")
+)
+
+// validityPeriod is when the credentials of a job are valid, as the handler resolved it from the
+// request, its schema and the issuer's policy. Times are RFC 3339. Jobs accepted before the
+// handler resolved fixed times may still have an empty ValidFrom and a Lifetime.
+type validityPeriod struct {
+	ValidFrom    string `json:"validFrom,omitempty"`    // Issuance time when empty
+	ValidUntil   string `json:"validUntil,omitempty"`   // ValidFrom plus Lifetime when empty
+	Lifetime     string `json:"lifetime,omitempty"`     // ISO 8601 duration, defaultCredentialLifetime when empty
+	NeverExpires bool   `json:"neverExpires,omitempty"` // The credentials have no expiration date
+}
+
+// window returns when a credential issued at issuedAt is valid. until is zero for credentials
+// that never expire.
+func (v validityPeriod) window(issuedAt time.Time) (from, until time.Time, err error) {
+	from = issuedAt
+	if v.ValidFrom != "" {
+		if from, err = time.Parse(time.RFC3339, v.ValidFrom); err != nil {
+			return from, until, fmt.Errorf("%w: validFrom: %v", errInvalidValidity, err)
+		}
+	}
+	switch {
+	case v.NeverExpires:
+		return from, until, nil
+	case v.ValidUntil != "":
+		until, err = time.Parse(time.RFC3339, v.ValidUntil)
+		if err != nil {
+			return from, until, fmt.Errorf("%w: validUntil: %v", errInvalidValidity, err)
+		}
+	default:
+		lifetime := v.Lifetime
+		if lifetime == "" {
+			lifetime = defaultCredentialLifetime
+		}
+		d, err := parseISODuration(lifetime)
+		if err != nil {
+			return from, until, fmt.Errorf("%w: lifetime: %v", errInvalidValidity, err)
+		}
+		until = d.addTo(from)
+	}
+	return from, until, nil
+}
+
+// resolveValidity works out the validity period of a request's credentials, accepted at now. The
+// request's validFrom, validUntil and neverExpires come first; otherwise the credentials are
+// valid from now for the schema's default lifetime, or defaultCredentialLifetime. validFrom may
+// lie at most maxValidFromBackdate before now. An issuer with a maximum lifetime cannot issue
+// credentials valid for longer, nor credentials that never expire; a default lifetime longer than
+// the maximum is shortened to it.
+//
+// The period is returned as fixed times, so the credentials get exactly the dates the limits were
+// checked against, however long the job waits in the queue.
+func resolveValidity(req CredentialRequest, schemaLifetime, maxLifetime string, now time.Time) (validityPeriod, error) {
+	validity := validityPeriod{ValidFrom: req.ValidFrom, ValidUntil: req.ValidUntil, Lifetime: schemaLifetime, NeverExpires: req.NeverExpires}
+	if req.NeverExpires && req.ValidUntil != "" {
+		return validity, fmt.Errorf("%w: neverExpires cannot be combined with validUntil", errInvalidValidity)
+	}
+
+	from, until, err := validity.window(now)
+	if err != nil {
+		return validity, err
+	}
+	if !until.IsZero() && !until.After(from) {
+		return validity, fmt.Errorf("%w: validUntil must be after validFrom", errInvalidValidity)
+	}
+	if from.Before(now.Add(-maxValidFromBackdate)) {
+		return validity, fmt.Errorf("%w: validFrom lies more than %v in the past", errInvalidValidity, maxValidFromBackdate)
+	}
+
+	if maxLifetime != "" {
+		limit, err := parseISODuration(maxLifetime)
+		if err != nil {
+			return validity, fmt.Errorf("invalid maximum lifetime of %s: %v", req.IssuerDid, err)
+		}
+		latest := limit.addTo(from)
+		switch {
+		case req.NeverExpires:
+			return validity, fmt.Errorf("%w of %s: credentials must expire", errLifetimeExceeded, limit)
+		case req.ValidUntil != "":
+			if until.After(latest) {
+				return validity, fmt.Errorf("%w of %s", errLifetimeExceeded, limit)
+			}
+		case until.After(latest):
+			until = latest
+		}
+	}
+
+	resolved := validityPeriod{ValidFrom: from.UTC().Format(time.RFC3339), NeverExpires: req.NeverExpires}
+	if !until.IsZero() {
+		resolved.ValidUntil = until.UTC().Format(time.RFC3339)
+	}
+	return resolved, nil
+}
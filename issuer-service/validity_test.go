@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestResolveValidity(t *testing.T) {
+	now := time.Date(2024, 9, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		req            CredentialRequest
+		schemaLifetime string
+		maxLifetime    string
+		from, until    string // Expected window, until empty for credentials that never expire
+		err            error
+	}{
+		{name: "default lifetime", from: "2024-09-05T12:00:00Z", until: "2025-09-05T12:00:00Z"},
+		{name: "schema lifetime", schemaLifetime: "P90D", from: "2024-09-05T12:00:00Z", until: "2024-12-04T12:00:00Z"},
+		{
+			name: "requested period",
+			req:  CredentialRequest{ValidFrom: "2024-10-01T00:00:00Z", ValidUntil: "2024-12-31T23:59:59Z"},
+			from: "2024-10-01T00:00:00Z", until: "2024-12-31T23:59:59Z",
+		},
+		{
+			name: "requested start with schema lifetime", req: CredentialRequest{ValidFrom: "2024-10-01T00:00:00Z"},
+			schemaLifetime: "P1M", from: "2024-10-01T00:00:00Z", until: "2024-11-01T00:00:00Z",
+		},
+		{name: "never expires", req: CredentialRequest{NeverExpires: true}, from: "2024-09-05T12:00:00Z"},
+		{name: "default shortened to maximum", maxLifetime: "P6M", from: "2024-09-05T12:00:00Z", until: "2025-03-05T12:00:00Z"},
+		{
+			name: "requested period within maximum", req: CredentialRequest{ValidUntil: "2025-03-05T12:00:00Z"},
+			maxLifetime: "P6M", from: "2024-09-05T12:00:00Z", until: "2025-03-05T12:00:00Z",
+		},
+		{name: "requested period beyond maximum", req: CredentialRequest{ValidUntil: "2025-03-05T12:00:01Z"}, maxLifetime: "P6M", err: errLifetimeExceeded},
+		{name: "never expires beyond maximum", req: CredentialRequest{NeverExpires: true}, maxLifetime: "P10Y", err: errLifetimeExceeded},
+		{name: "backdated validFrom", req: CredentialRequest{ValidFrom: "2024-09-05T00:00:00Z"}, from: "2024-09-05T00:00:00Z", until: "2025-09-05T00:00:00Z"},
+		{name: "validFrom backdated too far", req: CredentialRequest{ValidFrom: "2024-09-04T11:59:59Z"}, err: errInvalidValidity},
+		{name: "invalid validFrom", req: CredentialRequest{ValidFrom: "2024-10-01"}, err: errInvalidValidity},
+		{name: "invalid validUntil", req: CredentialRequest{ValidUntil: "tomorrow"}, err: errInvalidValidity},
+		{name: "validUntil before validFrom", req: CredentialRequest{ValidFrom: "2024-10-01T00:00:00Z", ValidUntil: "2024-09-30T00:00:00Z"}, err: errInvalidValidity},
+		{name: "never expires with validUntil", req: CredentialRequest{NeverExpires: true, ValidUntil: "2025-01-01T00:00:00Z"}, err: errInvalidValidity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validity, err := resolveValidity(tt.req, tt.schemaLifetime, tt.maxLifetime, now)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to resolve validity: %v", err)
+			}
+
+			// The worker issuing the credentials later gets the same window
+			from, until, err := validity.window(now.Add(time.Hour))
+			if err != nil {
+				t.Fatalf("Failed to work out validity window: %v", err)
+			}
+			if from.Format(time.RFC3339) != tt.from {
+				t.Errorf("Expected validity from %s, got %s", tt.from, from.Format(time.RFC3339))
+			}
+			switch {
+			case tt.until == "" && !until.IsZero():
+				t.Errorf("Expected no expiration, got %s", until.Format(time.RFC3339))
+			case tt.until != "" && until.Format(time.RFC3339) != tt.until:
+				t.Errorf("Expected validity until %s, got %s", tt.until, until.Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func TestNewCredentialNeverExpires(t *testing.T) {
+	issuedAt := time.Date(2024, 9, 5, 12, 0, 0, 0, time.UTC)
+	job := issuanceJob{
+		Request:  CredentialRequest{IssuerDid: "did:key:z6MkIssuer"},
+		Validity: validityPeriod{ValidFrom: "2024-10-01T00:00:00Z", NeverExpires: true},
+	}
+
+	credential, err := newCredential(uuid.New(), job, map[string]interface{}{"id": "did:key:z6MkHolder"}, issuedAt)
+	if err != nil {
+		t.Fatalf("Failed to build credential: %v", err)
+	}
+	if credential.IssuanceDate != "2024-10-01T00:00:00Z" || credential.ExpirationDate != "" {
+		t.Errorf("Unexpected validity %q - %q", credential.IssuanceDate, credential.ExpirationDate)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+func TestRetryDelay(t *testing.T) {
+	expected := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second}
+	for retry := 1; retry <= maxIssuanceRetries; retry++ {
+		if delay := retryDelay(retry); delay != expected[retry-1] {
+			t.Errorf("Expected retry %d to wait %v, got %v", retry, expected[retry-1], delay)
+		}
+	}
+	if retryQueueName(2) != "credential_issuance_retry.2" {
+		t.Errorf("Unexpected retry queue %s", retryQueueName(2))
+	}
+}
+
+func TestRetryCount(t *testing.T) {
+	for _, tc := range []struct {
+		headers  amqp.Table
+		expected int
+	}{
+		{nil, 0},
+		{amqp.Table{retryCountHeader: int32(3)}, 3},
+		{amqp.Table{retryCountHeader: int64(4)}, 4},
+		{amqp.Table{retryCountHeader: "5"}, 0},
+	} {
+		if count := retryCount(amqp.Delivery{Headers: tc.headers}); count != tc.expected {
+			t.Errorf("Expected %d retries for %v, got %d", tc.expected, tc.headers, count)
+		}
+	}
+}
+
+func TestNewDeadLetter(t *testing.T) {
+	deadLetteredAt := time.Date(2024, 9, 5, 12, 0, 0, 0, time.UTC)
+	deadLetter := newDeadLetter(amqp.Delivery{
+		MessageId: "3f1c9a52-6d0e-4b7e-9a43-51f2a1b0c9d8",
+		Timestamp: deadLetteredAt,
+		Headers: amqp.Table{
+			retryCountHeader:       int32(maxIssuanceRetries),
+			deadLetterReasonHeader: "resolver-service unavailable",
+		},
+		Body: []byte(`{"id":"3f1c9a52-6d0e-4b7e-9a43-51f2a1b0c9d8","request":{"issuerDid":"did:web:issuer.example.com","subject":[{"id":"did:key:z6MkHolder"}]}}`),
+	})
+
+	expected := DeadLetter{
+		JobID:          "3f1c9a52-6d0e-4b7e-9a43-51f2a1b0c9d8",
+		IssuerDid:      "did:web:issuer.example.com",
+		SubjectCount:   1,
+		Retries:        maxIssuanceRetries,
+		Reason:         "resolver-service unavailable",
+		DeadLetteredAt: "2024-09-05T12:00:00Z",
+	}
+	if deadLetter != expected {
+		t.Errorf("Expected %+v, got %+v", expected, deadLetter)
+	}
+
+	// Undecodable jobs are still listed under their message ID
+	deadLetter = newDeadLetter(amqp.Delivery{MessageId: "broken", Body: []byte("{")})
+	if deadLetter.JobID != "broken" || deadLetter.SubjectCount != 0 {
+		t.Errorf("Unexpected dead letter %+v", deadLetter)
+	}
+}
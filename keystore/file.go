@@ -0,0 +1,244 @@
+package keystore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt parameters deriving the file encryption key from the passphrase
+const (
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	scryptKeySize = 32
+	saltSize      = 16
+)
+
+// encryptedFile is the on-disk format of the file backend: the JSON encoded key store state,
+// encrypted with AES-256-GCM under a key derived from the passphrase and salt
+type encryptedFile struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// fileStore keeps keys in an encrypted file, for development and edge deployments without
+// Vault. The file is read on every operation and replaced atomically on every change, so
+// several processes on one host can share it as long as they do not write concurrently.
+type fileStore struct {
+	mu         sync.Mutex
+	path       string
+	passphrase string
+}
+
+// NewFile creates a KeyStore backed by the encrypted file at path, creating the file if it does
+// not exist. The passphrase must be the same every time the file is opened.
+func NewFile(path, passphrase string) (KeyStore, error) {
+	if path == "" {
+		return nil, errors.New("key store file path is required")
+	}
+	if passphrase == "" {
+		return nil, errors.New("key store passphrase is required")
+	}
+
+	store := &fileStore{path: path, passphrase: passphrase}
+	// Fail early on a wrong passphrase or unreadable file
+	if _, err := store.load(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// load reads and decrypts the file, returning an empty state when it does not exist yet
+func (f *fileStore) load() (*localState, error) {
+	raw, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return newLocalState(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key store file: %w", err)
+	}
+
+	var file encryptedFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode key store file: %w", err)
+	}
+	aead, err := f.cipher(file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid key store file nonce")
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt key store file: wrong passphrase or corrupted file")
+	}
+
+	state := newLocalState()
+	if err := json.Unmarshal(plaintext, state); err != nil {
+		return nil, fmt.Errorf("failed to decode key store: %w", err)
+	}
+	return state, nil
+}
+
+// save encrypts the state with a fresh salt and nonce and replaces the file
+func (f *fileStore) save(state *localState) error {
+	plaintext, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode key store: %w", err)
+	}
+
+	file := encryptedFile{Salt: make([]byte, saltSize)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	aead, err := f.cipher(file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+
+	raw, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write key store file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(raw); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write key store file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write key store file: %w", err)
+	}
+	if err := os.Rename(temp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to write key store file: %w", err)
+	}
+	return nil
+}
+
+// cipher derives the AES-256-GCM cipher for a salt
+func (f *fileStore) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(f.passphrase), salt, scryptN, scryptR, scryptP, scryptKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key store encryption key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// read runs fn on the current state
+func (f *fileStore) read(fn func(*localState) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, err := f.load()
+	if err != nil {
+		return err
+	}
+	return fn(state)
+}
+
+// update runs fn on the current state and saves the state if fn succeeds
+func (f *fileStore) update(fn func(*localState) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, err := f.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(state); err != nil {
+		return err
+	}
+	return f.save(state)
+}
+
+func (f *fileStore) Create(ctx context.Context, keyType string) (Key, error) {
+	privateKey, err := generatePrivateKey(keyType)
+	if err != nil {
+		return Key{}, err
+	}
+	return f.Import(ctx, keyType, privateKey)
+}
+
+func (f *fileStore) Import(ctx context.Context, keyType string, privateKey []byte) (key Key, err error) {
+	err = f.update(func(state *localState) error {
+		key, err = state.importKey(keyType, append([]byte{}, privateKey...))
+		return err
+	})
+	return key, err
+}
+
+func (f *fileStore) Rotate(ctx context.Context, keyID string) (key Key, err error) {
+	err = f.update(func(state *localState) error {
+		key, err = state.rotate(keyID)
+		return err
+	})
+	return key, err
+}
+
+func (f *fileStore) Assign(ctx context.Context, key Key, keyIDs ...string) error {
+	return f.update(func(state *localState) error {
+		return state.assign(key, keyIDs...)
+	})
+}
+
+func (f *fileStore) Unassign(ctx context.Context, keyIDs ...string) error {
+	return f.update(func(state *localState) error {
+		state.unassign(keyIDs...)
+		return nil
+	})
+}
+
+func (f *fileStore) PublicKey(ctx context.Context, keyID string) (key Key, err error) {
+	err = f.read(func(state *localState) error {
+		key, err = state.publicKey(keyID)
+		return err
+	})
+	return key, err
+}
+
+func (f *fileStore) Sign(ctx context.Context, keyID string, data []byte) (signature []byte, err error) {
+	err = f.read(func(state *localState) error {
+		signature, err = state.sign(keyID, data)
+		return err
+	})
+	return signature, err
+}
+
+func (f *fileStore) Discard(ctx context.Context, key Key) error {
+	return f.update(func(state *localState) error {
+		delete(state.Keys, key.Name)
+		return nil
+	})
+}
+
+func (f *fileStore) Destroy(ctx context.Context, did string) error {
+	return f.update(func(state *localState) error {
+		state.destroy(did)
+		return nil
+	})
+}
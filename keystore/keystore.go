@@ -0,0 +1,109 @@
+// Package keystore holds the private keys of DID verification methods for did-service,
+// issuer-service and holder-service. A KeyStore creates keys, records which verification
+// methods use them, and signs with them, so the services never handle private key material
+// themselves. The backend is chosen with the KEYSTORE environment variable, see NewFromEnv.
+package keystore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Key types a KeyStore can create
+const (
+	Ed25519    = "Ed25519"
+	P256       = "P-256"
+	Secp256k1  = "secp256k1"
+	BLS12381G2 = "BLS12-381-G2"
+)
+
+// Backends accepted in the KEYSTORE environment variable
+const (
+	BackendVaultTransit = "vault-transit"
+	BackendVaultKV      = "vault-kv"
+	BackendFile         = "file"
+	BackendMemory       = "memory"
+)
+
+var (
+	// ErrKeyNotFound is returned when no key is assigned to a verification method
+	ErrKeyNotFound = errors.New("no key assigned to verification method")
+	// ErrUnsupportedKeyType is returned for key types a KeyStore cannot create or use
+	ErrUnsupportedKeyType = errors.New("unsupported key type")
+)
+
+// Key is a version of a key held by a KeyStore. PublicKey is the raw public key: 32 bytes for
+// Ed25519, a compressed point for P-256 and secp256k1, and a 96 byte compressed G2 point for
+// BLS12-381.
+type Key struct {
+	Name      string // Name of the key in the store
+	Version   int    // Version of the key material, incremented by Rotate
+	Type      string // Key type, e.g. "Ed25519"
+	PublicKey []byte
+}
+
+// KeyStore creates, uses and destroys the private keys of DID verification methods. Keys are
+// created on their own and assigned to verification method IDs ("<did>#<fragment>") once those
+// are known, since did:key and did:peer identifiers are derived from the public key.
+type KeyStore interface {
+	// Create generates a new key of the given type
+	Create(ctx context.Context, keyType string) (Key, error)
+	// Import stores existing private key material: the 64 byte Ed25519 private key, or the
+	// 32 byte secret scalar of the other key types
+	Import(ctx context.Context, keyType string, privateKey []byte) (Key, error)
+	// Rotate creates a new version of the key assigned to a verification method. The new
+	// version is used once it is assigned to a verification method.
+	Rotate(ctx context.Context, keyID string) (Key, error)
+	// Assign records that verification methods sign with the given key version
+	Assign(ctx context.Context, key Key, keyIDs ...string) error
+	// Unassign forgets the key versions of verification methods, e.g. after the DID document
+	// listing them could not be stored. The keys themselves are kept.
+	Unassign(ctx context.Context, keyIDs ...string) error
+	// PublicKey returns the key version assigned to a verification method
+	PublicKey(ctx context.Context, keyID string) (Key, error)
+	// Sign signs data with the key version assigned to a verification method
+	Sign(ctx context.Context, keyID string, data []byte) ([]byte, error)
+	// Discard destroys a key that was never assigned, e.g. after its DID could not be stored
+	Discard(ctx context.Context, key Key) error
+	// Destroy permanently destroys every key assigned to a DID's verification methods
+	Destroy(ctx context.Context, did string) error
+}
+
+// NewFromEnv creates the KeyStore selected by the KEYSTORE environment variable:
+//
+//   - "vault-transit" (default): non-exportable Vault Transit keys, with the key types Transit
+//     does not support stored in Vault KV
+//   - "vault-kv": private keys stored in Vault KV v2
+//   - "file": an encrypted file at KEYSTORE_FILE, keyed with KEYSTORE_PASSPHRASE
+//   - "memory": process memory, for tests and local development
+//
+// Vault backends read VAULT_ADDR and VAULT_TOKEN, and the mount paths from VAULT_KV_MOUNT
+// ("secret" by default) and VAULT_TRANSIT_MOUNT ("transit" by default).
+func NewFromEnv() (KeyStore, error) {
+	switch backend := os.Getenv("KEYSTORE"); backend {
+	case "", BackendVaultTransit, BackendVaultKV:
+		return NewVault(VaultConfig{
+			KVMount:      strings.Trim(os.Getenv("VAULT_KV_MOUNT"), "/"),
+			TransitMount: strings.Trim(os.Getenv("VAULT_TRANSIT_MOUNT"), "/"),
+			Transit:      backend != BackendVaultKV,
+		})
+	case BackendFile:
+		return NewFile(os.Getenv("KEYSTORE_FILE"), os.Getenv("KEYSTORE_PASSPHRASE"))
+	case BackendMemory:
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown KEYSTORE backend %q", backend)
+	}
+}
+
+// splitKeyID splits a verification method ID into its DID and fragment
+func splitKeyID(keyID string) (did, fragment string, err error) {
+	did, fragment, ok := strings.Cut(keyID, "#")
+	if !ok || did == "" || fragment == "" {
+		return "", "", fmt.Errorf("invalid verification method ID %q", keyID)
+	}
+	return did, fragment, nil
+}
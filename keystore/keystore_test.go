@@ -0,0 +1,277 @@
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudflare/circl/sign/bls"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+const testDID = "did:example:123"
+
+// verify checks a signature made by a KeyStore against the public key it reported
+func verify(t *testing.T, key Key, data, signature []byte) {
+	t.Helper()
+
+	var ok bool
+	switch key.Type {
+	case Ed25519:
+		ok = ed25519.Verify(ed25519.PublicKey(key.PublicKey), data, signature)
+	case P256:
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), key.PublicKey)
+		digest := sha256.Sum256(data)
+		ok = len(signature) == 64 && ecdsa.Verify(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, digest[:],
+			new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:]))
+	case Secp256k1:
+		publicKey, err := secp256k1.ParsePubKey(key.PublicKey)
+		if err != nil {
+			t.Fatalf("Invalid secp256k1 public key: %v", err)
+		}
+		var r, s secp256k1.ModNScalar
+		r.SetByteSlice(signature[:32])
+		s.SetByteSlice(signature[32:])
+		digest := sha256.Sum256(data)
+		ok = secp256k1ecdsa.NewSignature(&r, &s).Verify(digest[:], publicKey)
+	case BLS12381G2:
+		var publicKey bls.PublicKey[bls.KeyG2SigG1]
+		if err := publicKey.UnmarshalBinary(key.PublicKey); err != nil {
+			t.Fatalf("Invalid BLS12-381 public key: %v", err)
+		}
+		ok = bls.Verify(&publicKey, data, signature)
+	}
+	if !ok {
+		t.Errorf("%s signature does not verify", key.Type)
+	}
+}
+
+// testKeyStore runs the operations every KeyStore supports against store
+func testKeyStore(t *testing.T, store KeyStore) {
+	ctx := context.Background()
+	data := []byte("hash data")
+
+	for _, keyType := range []string{Ed25519, P256, Secp256k1, BLS12381G2} {
+		keyID := testDID + "#" + keyType
+
+		key, err := store.Create(ctx, keyType)
+		if err != nil {
+			t.Fatalf("Failed to create %s key: %v", keyType, err)
+		}
+		if _, err := store.PublicKey(ctx, keyID); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Expected ErrKeyNotFound before the key is assigned, got %v", err)
+		}
+		if err := store.Assign(ctx, key, keyID); err != nil {
+			t.Fatalf("Failed to assign %s key: %v", keyType, err)
+		}
+
+		stored, err := store.PublicKey(ctx, keyID)
+		if err != nil {
+			t.Fatalf("Failed to read %s public key: %v", keyType, err)
+		}
+		if stored.Type != keyType || !bytes.Equal(stored.PublicKey, key.PublicKey) {
+			t.Errorf("Expected the created %s key back, got %+v", keyType, stored)
+		}
+		signature, err := store.Sign(ctx, keyID, data)
+		if err != nil {
+			t.Fatalf("Failed to sign with %s key: %v", keyType, err)
+		}
+		verify(t, key, data, signature)
+
+		// The old version keeps signing until the new one is assigned
+		rotated, err := store.Rotate(ctx, keyID)
+		if err != nil {
+			t.Fatalf("Failed to rotate %s key: %v", keyType, err)
+		}
+		if rotated.Name != key.Name || rotated.Version != key.Version+1 || bytes.Equal(rotated.PublicKey, key.PublicKey) {
+			t.Errorf("Expected a new version of %s, got %+v", key.Name, rotated)
+		}
+		if stored, _ := store.PublicKey(ctx, keyID); !bytes.Equal(stored.PublicKey, key.PublicKey) {
+			t.Error("Expected the previous version before the rotated key is assigned")
+		}
+		if err := store.Assign(ctx, rotated, keyID); err != nil {
+			t.Fatalf("Failed to assign rotated %s key: %v", keyType, err)
+		}
+		signature, err = store.Sign(ctx, keyID, data)
+		if err != nil {
+			t.Fatalf("Failed to sign with rotated %s key: %v", keyType, err)
+		}
+		verify(t, rotated, data, signature)
+
+		// Unassigning forgets the verification method but keeps the key
+		unassignedID := testDID + "#unassigned-" + keyType
+		if err := store.Assign(ctx, rotated, unassignedID); err != nil {
+			t.Fatalf("Failed to assign %s key: %v", keyType, err)
+		}
+		if err := store.Unassign(ctx, unassignedID); err != nil {
+			t.Fatalf("Failed to unassign %s key: %v", keyType, err)
+		}
+		if _, err := store.PublicKey(ctx, unassignedID); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Expected ErrKeyNotFound after Unassign, got %v", err)
+		}
+		if _, err := store.Sign(ctx, unassignedID, data); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Expected signing with a retired key ID to fail with ErrKeyNotFound, got %v", err)
+		}
+		if _, err := store.Sign(ctx, keyID, data); err != nil {
+			t.Errorf("Expected %s to keep signing, got %v", keyID, err)
+		}
+	}
+
+	if _, err := store.Create(ctx, "RSA"); !errors.Is(err, ErrUnsupportedKeyType) {
+		t.Errorf("Expected ErrUnsupportedKeyType, got %v", err)
+	}
+	if err := store.Assign(ctx, Key{Name: "unknown", Version: 1}, testDID+"#key-1"); err == nil {
+		t.Error("Expected an error assigning a key that does not exist")
+	}
+
+	if err := store.Destroy(ctx, testDID); err != nil {
+		t.Fatalf("Failed to destroy keys: %v", err)
+	}
+	if _, err := store.Sign(ctx, testDID+"#"+Ed25519, data); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound after Destroy, got %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testKeyStore(t, NewMemory())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store, err := NewFile(path, "correct horse")
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	testKeyStore(t, store)
+
+	// Keys survive reopening the file
+	ctx := context.Background()
+	key, err := store.Create(ctx, Ed25519)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.Assign(ctx, key, testDID+"#key-1"); err != nil {
+		t.Fatalf("Failed to assign key: %v", err)
+	}
+	reopened, err := NewFile(path, "correct horse")
+	if err != nil {
+		t.Fatalf("Failed to reopen file store: %v", err)
+	}
+	if stored, err := reopened.PublicKey(ctx, testDID+"#key-1"); err != nil || !bytes.Equal(stored.PublicKey, key.PublicKey) {
+		t.Errorf("Expected the key back after reopening, got %+v (%v)", stored, err)
+	}
+
+	if _, err := NewFile(path, "wrong passphrase"); err == nil {
+		t.Error("Expected an error opening the file with the wrong passphrase")
+	}
+	if _, err := NewFile(path, ""); err == nil {
+		t.Error("Expected an error without a passphrase")
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory()
+
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	key, err := store.Import(ctx, Ed25519, privateKey)
+	if err != nil {
+		t.Fatalf("Failed to import key: %v", err)
+	}
+	if !bytes.Equal(key.PublicKey, privateKey.Public().(ed25519.PublicKey)) {
+		t.Error("Expected the imported key's public key")
+	}
+
+	if _, err := store.Import(ctx, Ed25519, privateKey[:32]); err == nil {
+		t.Error("Expected an error importing a truncated private key")
+	}
+}
+
+func TestParseKeyLocation(t *testing.T) {
+	tests := []struct {
+		location string
+		expected keyLocation
+	}{
+		{"", keyLocation{kind: locationKV, name: "dids/" + testDID}},
+		{"3", keyLocation{kind: locationKV, name: "dids/" + testDID, version: 3}},
+		{"transit:dids-abc", keyLocation{kind: locationTransit, name: "dids-abc"}},
+		{"transit:dids-abc:2", keyLocation{kind: locationTransit, name: "dids-abc", version: 2}},
+		{"kv:keys/dids-abc:4", keyLocation{kind: locationKV, name: "keys/dids-abc", version: 4}},
+	}
+	for _, test := range tests {
+		location, err := parseKeyLocation(testDID, test.location)
+		if err != nil || location != test.expected {
+			t.Errorf("parseKeyLocation(%q) = %+v, %v; expected %+v", test.location, location, err, test.expected)
+		}
+	}
+
+	for _, invalid := range []string{"kv:keys/dids-abc", "kv:keys/dids-abc:0", "file:dids-abc:1"} {
+		if _, err := parseKeyLocation(testDID, invalid); err == nil {
+			t.Errorf("Expected an error for key location %q", invalid)
+		}
+	}
+}
+
+func TestDecodeTransitPublicKey(t *testing.T) {
+	edPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	decoded, err := decodeTransitPublicKey(Ed25519, base64.StdEncoding.EncodeToString(edPublicKey))
+	if err != nil || !bytes.Equal(decoded, edPublicKey) {
+		t.Errorf("Expected the Ed25519 public key back, got %x (%v)", decoded, err)
+	}
+
+	ecPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate P-256 key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&ecPrivateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal P-256 key: %v", err)
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	decoded, err = decodeTransitPublicKey(P256, string(pemKey))
+	expected := elliptic.MarshalCompressed(elliptic.P256(), ecPrivateKey.X, ecPrivateKey.Y)
+	if err != nil || !bytes.Equal(decoded, expected) {
+		t.Errorf("Expected the compressed P-256 public key, got %x (%v)", decoded, err)
+	}
+
+	if _, err := decodeTransitPublicKey(P256, base64.StdEncoding.EncodeToString(edPublicKey)); err == nil {
+		t.Error("Expected an error for a public key that is not PEM encoded")
+	}
+	if _, err := decodeTransitPublicKey(Secp256k1, ""); err == nil {
+		t.Error("Expected an error for a key type Transit does not support")
+	}
+}
+
+func TestDecodeTransitSignature(t *testing.T) {
+	signature := bytes.Repeat([]byte{0xfb}, 64)
+
+	decoded, err := decodeTransitSignature(Ed25519, "vault:v1:"+base64.StdEncoding.EncodeToString(signature))
+	if err != nil || !bytes.Equal(decoded, signature) {
+		t.Errorf("Expected the Ed25519 signature back, got %x (%v)", decoded, err)
+	}
+	decoded, err = decodeTransitSignature(P256, "vault:v2:"+base64.RawURLEncoding.EncodeToString(signature))
+	if err != nil || !bytes.Equal(decoded, signature) {
+		t.Errorf("Expected the P-256 signature back, got %x (%v)", decoded, err)
+	}
+	if _, err := decodeTransitSignature(Ed25519, base64.StdEncoding.EncodeToString(signature)); err == nil {
+		t.Error("Expected an error for a signature without the vault prefix")
+	}
+}
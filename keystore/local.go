@@ -0,0 +1,139 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/circl/sign/bls"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// Private keys generated outside Vault Transit are kept as the 64 byte Ed25519 private key (seed
+// and public key), or as the 32 byte secret scalar of the other key types.
+
+// generatePrivateKey generates private key material of the given type
+func generatePrivateKey(keyType string) ([]byte, error) {
+	switch keyType {
+	case Ed25519:
+		_, privateKey, err := ed25519.GenerateKey(nil)
+		return privateKey, err
+
+	case P256:
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return privateKey.D.FillBytes(make([]byte, 32)), nil
+
+	case Secp256k1:
+		privateKey, err := secp256k1.GeneratePrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		return privateKey.Serialize(), nil
+
+	case BLS12381G2:
+		ikm := make([]byte, 32)
+		if _, err := rand.Read(ikm); err != nil {
+			return nil, err
+		}
+		privateKey, err := bls.KeyGen[bls.KeyG2SigG1](ikm, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		return privateKey.MarshalBinary()
+
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedKeyType, keyType)
+	}
+}
+
+// publicKeyOf derives the raw public key of private key material
+func publicKeyOf(keyType string, privateKey []byte) ([]byte, error) {
+	if err := checkPrivateKey(keyType, privateKey); err != nil {
+		return nil, err
+	}
+
+	switch keyType {
+	case Ed25519:
+		return []byte(ed25519.PrivateKey(privateKey).Public().(ed25519.PublicKey)), nil
+
+	case P256:
+		x, y := elliptic.P256().ScalarBaseMult(privateKey)
+		return elliptic.MarshalCompressed(elliptic.P256(), x, y), nil
+
+	case Secp256k1:
+		return secp256k1.PrivKeyFromBytes(privateKey).PubKey().SerializeCompressed(), nil
+
+	default: // BLS12381G2
+		var blsPrivateKey bls.PrivateKey[bls.KeyG2SigG1]
+		if err := blsPrivateKey.UnmarshalBinary(privateKey); err != nil {
+			return nil, fmt.Errorf("invalid BLS12-381 private key: %w", err)
+		}
+		return blsPrivateKey.PublicKey().MarshalBinary()
+	}
+}
+
+// checkPrivateKey checks the length of private key material for its key type
+func checkPrivateKey(keyType string, privateKey []byte) error {
+	size := 32
+	switch keyType {
+	case Ed25519:
+		size = ed25519.PrivateKeySize
+	case P256, Secp256k1, BLS12381G2:
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedKeyType, keyType)
+	}
+
+	if len(privateKey) != size {
+		return fmt.Errorf("invalid %s private key length: %d", keyType, len(privateKey))
+	}
+	return nil
+}
+
+// signLocal signs data with private key material. ECDSA signatures are over the SHA-256 digest
+// of the data and encoded as the 64 byte concatenation r || s; BLS12-381 signatures are G1 points.
+func signLocal(keyType string, privateKey, data []byte) ([]byte, error) {
+	if err := checkPrivateKey(keyType, privateKey); err != nil {
+		return nil, err
+	}
+
+	switch keyType {
+	case Ed25519:
+		return ed25519.Sign(ed25519.PrivateKey(privateKey), data), nil
+
+	case P256:
+		x, y := elliptic.P256().ScalarBaseMult(privateKey)
+		ecPrivateKey := &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y},
+			D:         new(big.Int).SetBytes(privateKey),
+		}
+
+		digest := sha256.Sum256(data)
+		r, s, err := ecdsa.Sign(rand.Reader, ecPrivateKey, digest[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign with P-256 key: %w", err)
+		}
+		return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...), nil
+
+	case Secp256k1:
+		digest := sha256.Sum256(data)
+		signature := secp256k1ecdsa.Sign(secp256k1.PrivKeyFromBytes(privateKey), digest[:])
+		r, s := signature.R(), signature.S()
+		rBytes, sBytes := r.Bytes(), s.Bytes()
+		return append(rBytes[:], sBytes[:]...), nil
+
+	default: // BLS12381G2
+		var blsPrivateKey bls.PrivateKey[bls.KeyG2SigG1]
+		if err := blsPrivateKey.UnmarshalBinary(privateKey); err != nil {
+			return nil, fmt.Errorf("invalid BLS12-381 private key: %w", err)
+		}
+		return bls.Sign(&blsPrivateKey, data), nil
+	}
+}
@@ -0,0 +1,209 @@
+package keystore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// keyRecord is a key of the memory and file backends: its type and the private key material of
+// each version, starting at version 1
+type keyRecord struct {
+	Type     string   `json:"type"`
+	Versions [][]byte `json:"versions"`
+}
+
+// assignment is the key version a verification method signs with
+type assignment struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+}
+
+// localState is the content of the memory and file backends
+type localState struct {
+	Keys        map[string]*keyRecord `json:"keys"`
+	Assignments map[string]assignment `json:"assignments"`
+}
+
+func newLocalState() *localState {
+	return &localState{Keys: map[string]*keyRecord{}, Assignments: map[string]assignment{}}
+}
+
+// newKeyName returns a random key name
+func newKeyName() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return "dids-" + hex.EncodeToString(random), nil
+}
+
+func (s *localState) importKey(keyType string, privateKey []byte) (Key, error) {
+	publicKey, err := publicKeyOf(keyType, privateKey)
+	if err != nil {
+		return Key{}, err
+	}
+	name, err := newKeyName()
+	if err != nil {
+		return Key{}, err
+	}
+
+	s.Keys[name] = &keyRecord{Type: keyType, Versions: [][]byte{privateKey}}
+	return Key{Name: name, Version: 1, Type: keyType, PublicKey: publicKey}, nil
+}
+
+func (s *localState) rotate(keyID string) (Key, error) {
+	current, ok := s.Assignments[keyID]
+	if !ok {
+		return Key{}, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+	record := s.Keys[current.Name]
+
+	privateKey, err := generatePrivateKey(record.Type)
+	if err != nil {
+		return Key{}, err
+	}
+	publicKey, err := publicKeyOf(record.Type, privateKey)
+	if err != nil {
+		return Key{}, err
+	}
+
+	record.Versions = append(record.Versions, privateKey)
+	return Key{Name: current.Name, Version: len(record.Versions), Type: record.Type, PublicKey: publicKey}, nil
+}
+
+func (s *localState) assign(key Key, keyIDs ...string) error {
+	record, ok := s.Keys[key.Name]
+	if !ok || key.Version < 1 || key.Version > len(record.Versions) {
+		return fmt.Errorf("key %s version %d does not exist", key.Name, key.Version)
+	}
+	for _, keyID := range keyIDs {
+		if _, _, err := splitKeyID(keyID); err != nil {
+			return err
+		}
+		s.Assignments[keyID] = assignment{Name: key.Name, Version: key.Version}
+	}
+	return nil
+}
+
+func (s *localState) unassign(keyIDs ...string) {
+	for _, keyID := range keyIDs {
+		delete(s.Assignments, keyID)
+	}
+}
+
+// privateKey returns the key type and private key material assigned to a verification method
+func (s *localState) privateKey(keyID string) (assignment, string, []byte, error) {
+	assigned, ok := s.Assignments[keyID]
+	if !ok {
+		return assignment{}, "", nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+	record, ok := s.Keys[assigned.Name]
+	if !ok || assigned.Version > len(record.Versions) {
+		return assignment{}, "", nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+	return assigned, record.Type, record.Versions[assigned.Version-1], nil
+}
+
+func (s *localState) publicKey(keyID string) (Key, error) {
+	assigned, keyType, privateKey, err := s.privateKey(keyID)
+	if err != nil {
+		return Key{}, err
+	}
+	publicKey, err := publicKeyOf(keyType, privateKey)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{Name: assigned.Name, Version: assigned.Version, Type: keyType, PublicKey: publicKey}, nil
+}
+
+func (s *localState) sign(keyID string, data []byte) ([]byte, error) {
+	_, keyType, privateKey, err := s.privateKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return signLocal(keyType, privateKey, data)
+}
+
+func (s *localState) destroy(did string) {
+	for keyID, assigned := range s.Assignments {
+		if strings.HasPrefix(keyID, did+"#") {
+			delete(s.Keys, assigned.Name)
+			delete(s.Assignments, keyID)
+		}
+	}
+}
+
+// memoryStore keeps keys in process memory. Keys are lost on restart and not shared between
+// services, so it is only suitable for tests and single-process development.
+type memoryStore struct {
+	mu    sync.Mutex
+	state *localState
+}
+
+// NewMemory creates an empty in-memory KeyStore
+func NewMemory() KeyStore {
+	return &memoryStore{state: newLocalState()}
+}
+
+func (m *memoryStore) Create(ctx context.Context, keyType string) (Key, error) {
+	privateKey, err := generatePrivateKey(keyType)
+	if err != nil {
+		return Key{}, err
+	}
+	return m.Import(ctx, keyType, privateKey)
+}
+
+func (m *memoryStore) Import(ctx context.Context, keyType string, privateKey []byte) (Key, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.importKey(keyType, append([]byte{}, privateKey...))
+}
+
+func (m *memoryStore) Rotate(ctx context.Context, keyID string) (Key, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.rotate(keyID)
+}
+
+func (m *memoryStore) Assign(ctx context.Context, key Key, keyIDs ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.assign(key, keyIDs...)
+}
+
+func (m *memoryStore) Unassign(ctx context.Context, keyIDs ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.unassign(keyIDs...)
+	return nil
+}
+
+func (m *memoryStore) PublicKey(ctx context.Context, keyID string) (Key, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.publicKey(keyID)
+}
+
+func (m *memoryStore) Sign(ctx context.Context, keyID string, data []byte) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.sign(keyID, data)
+}
+
+func (m *memoryStore) Discard(ctx context.Context, key Key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.state.Keys, key.Name)
+	return nil
+}
+
+func (m *memoryStore) Destroy(ctx context.Context, did string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.destroy(did)
+	return nil
+}
@@ -0,0 +1,598 @@
+package keystore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// VaultConfig configures the Vault backends
+type VaultConfig struct {
+	// Client is the Vault client to use, created from VAULT_ADDR and VAULT_TOKEN when nil
+	Client *api.Client
+	// KVMount is the mount path of the KV v2 secrets engine, "secret" by default
+	KVMount string
+	// TransitMount is the mount path of the Transit secrets engine, "transit" by default
+	TransitMount string
+	// Transit creates Ed25519 and P-256 keys as non-exportable Transit keys. Other key types,
+	// and all keys when Transit is false, are stored in KV.
+	Transit bool
+}
+
+// Prefixes of the key locations recorded in a DID's KV metadata
+const (
+	locationKV      = "kv"
+	locationTransit = "transit"
+)
+
+// transitKeyTypes maps the key types Vault Transit can hold to its own key type names. Transit
+// has no secp256k1 or BLS12-381 keys.
+var transitKeyTypes = map[string]string{
+	Ed25519: "ed25519",
+	P256:    "ecdsa-p256",
+}
+
+// vaultStore keeps keys in Vault. The custom metadata of "<kv>/metadata/dids/<did>" maps the
+// fragment of each verification method to the location of its key version:
+//
+//   - "kv:keys/<name>:<version>": a version of the KV secret "<kv>/data/keys/<name>"
+//   - "transit:<name>:<version>": a version of the Transit key <name>
+//
+// Locations recorded by earlier releases are still read: a bare version number of the DID's own
+// secret "<kv>/data/dids/<did>", and "transit:<name>" for the latest version of a Transit key.
+// DIDs without any recorded locations use the latest version of their own secret.
+type vaultStore struct {
+	client     *api.Client
+	kvMount    string
+	transit    string
+	useTransit bool
+}
+
+// keyLocation is a parsed key location. Version 0 is the latest version.
+type keyLocation struct {
+	kind    string // locationKV or locationTransit
+	name    string // KV secret path below the mount, or Transit key name
+	version int
+}
+
+// NewVault creates a KeyStore backed by Vault
+func NewVault(config VaultConfig) (KeyStore, error) {
+	client := config.Client
+	if client == nil {
+		vaultConfig := api.DefaultConfig()
+		if err := vaultConfig.ReadEnvironment(); err != nil {
+			return nil, fmt.Errorf("failed to read Vault environment: %w", err)
+		}
+		var err error
+		client, err = api.NewClient(vaultConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Vault client: %w", err)
+		}
+	}
+
+	store := &vaultStore{
+		client:     client,
+		kvMount:    config.KVMount,
+		transit:    config.TransitMount,
+		useTransit: config.Transit,
+	}
+	if store.kvMount == "" {
+		store.kvMount = "secret"
+	}
+	if store.transit == "" {
+		store.transit = "transit"
+	}
+	return store, nil
+}
+
+// keyName returns the name of a key: its location without the version
+func keyName(kind, name string) string {
+	return kind + ":" + name
+}
+
+// parseKeyName splits a key name into its location kind and the name within it
+func parseKeyName(name string) (keyLocation, error) {
+	kind, rest, ok := strings.Cut(name, ":")
+	if !ok || rest == "" || (kind != locationKV && kind != locationTransit) {
+		return keyLocation{}, fmt.Errorf("invalid Vault key name %q", name)
+	}
+	return keyLocation{kind: kind, name: rest}, nil
+}
+
+// parseKeyLocation parses a key location recorded for a verification method of did
+func parseKeyLocation(did, location string) (keyLocation, error) {
+	if location == "" {
+		return keyLocation{kind: locationKV, name: "dids/" + did}, nil
+	}
+	if version, err := strconv.Atoi(location); err == nil {
+		return keyLocation{kind: locationKV, name: "dids/" + did, version: version}, nil
+	}
+
+	// Transit locations written before key versions were recorded have no version
+	if name, ok := strings.CutPrefix(location, locationTransit+":"); ok && !strings.Contains(name, ":") {
+		return keyLocation{kind: locationTransit, name: name}, nil
+	}
+
+	separator := strings.LastIndex(location, ":")
+	version, err := strconv.Atoi(location[separator+1:])
+	if separator < 0 || err != nil || version < 1 {
+		return keyLocation{}, fmt.Errorf("invalid key location %q", location)
+	}
+	parsed, err := parseKeyName(location[:separator])
+	if err != nil {
+		return keyLocation{}, err
+	}
+	parsed.version = version
+	return parsed, nil
+}
+
+func (v *vaultStore) Create(ctx context.Context, keyType string) (Key, error) {
+	if _, ok := transitKeyTypes[keyType]; ok && v.useTransit {
+		return v.createTransitKey(ctx, keyType)
+	}
+
+	privateKey, err := generatePrivateKey(keyType)
+	if err != nil {
+		return Key{}, err
+	}
+	return v.Import(ctx, keyType, privateKey)
+}
+
+// createTransitKey creates a non-exportable Transit key under a random name
+func (v *vaultStore) createTransitKey(ctx context.Context, keyType string) (Key, error) {
+	name, err := newKeyName()
+	if err != nil {
+		return Key{}, err
+	}
+
+	_, err = v.client.Logical().WriteWithContext(ctx, v.transitPath("keys", name), map[string]interface{}{
+		"type":                   transitKeyTypes[keyType],
+		"exportable":             false,
+		"allow_plaintext_backup": false,
+	})
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to create Transit key: %w", err)
+	}
+	return v.transitKey(ctx, keyLocation{kind: locationTransit, name: name, version: 1})
+}
+
+func (v *vaultStore) Import(ctx context.Context, keyType string, privateKey []byte) (Key, error) {
+	publicKey, err := publicKeyOf(keyType, privateKey)
+	if err != nil {
+		return Key{}, err
+	}
+	name, err := newKeyName()
+	if err != nil {
+		return Key{}, err
+	}
+
+	version, err := v.writeKVKey(ctx, "keys/"+name, keyType, privateKey)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{Name: keyName(locationKV, "keys/"+name), Version: version, Type: keyType, PublicKey: publicKey}, nil
+}
+
+// writeKVKey writes private key material as a new version of a KV secret
+func (v *vaultStore) writeKVKey(ctx context.Context, path, keyType string, privateKey []byte) (int, error) {
+	secret, err := v.client.Logical().WriteWithContext(ctx, v.kvPath("data", path), map[string]interface{}{
+		"data": map[string]interface{}{
+			"private_key": base64.StdEncoding.EncodeToString(privateKey),
+			"key_type":    keyType,
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to write private key to Vault: %w", err)
+	}
+	if secret == nil || secret.Data["version"] == nil {
+		return 0, fmt.Errorf("vault did not report a version for %s", path)
+	}
+	return strconv.Atoi(fmt.Sprint(secret.Data["version"]))
+}
+
+func (v *vaultStore) Rotate(ctx context.Context, keyID string) (Key, error) {
+	did, _, err := splitKeyID(keyID)
+	if err != nil {
+		return Key{}, err
+	}
+	location, err := v.location(ctx, keyID)
+	if err != nil {
+		return Key{}, err
+	}
+
+	if location.kind == locationTransit {
+		if _, err := v.client.Logical().WriteWithContext(ctx, v.transitPath("keys", location.name, "rotate"), nil); err != nil {
+			return Key{}, fmt.Errorf("failed to rotate Transit key %s: %w", location.name, err)
+		}
+		location.version = 0
+		return v.transitKey(ctx, location)
+	}
+
+	keyType, _, err := v.kvKey(ctx, location)
+	if err != nil {
+		return Key{}, err
+	}
+	privateKey, err := generatePrivateKey(keyType)
+	if err != nil {
+		return Key{}, err
+	}
+	// Keys in the DID's own secret predate per-key secrets, so they move to a new key
+	if location.name == "dids/"+did {
+		return v.Import(ctx, keyType, privateKey)
+	}
+
+	publicKey, err := publicKeyOf(keyType, privateKey)
+	if err != nil {
+		return Key{}, err
+	}
+	version, err := v.writeKVKey(ctx, location.name, keyType, privateKey)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{Name: keyName(locationKV, location.name), Version: version, Type: keyType, PublicKey: publicKey}, nil
+}
+
+func (v *vaultStore) Assign(ctx context.Context, key Key, keyIDs ...string) error {
+	if _, err := parseKeyName(key.Name); err != nil {
+		return err
+	}
+	location := fmt.Sprintf("%s:%d", key.Name, key.Version)
+	return v.updateLocations(ctx, keyIDs, func(locations map[string]interface{}, fragment string) {
+		locations[fragment] = location
+	})
+}
+
+func (v *vaultStore) Unassign(ctx context.Context, keyIDs ...string) error {
+	return v.updateLocations(ctx, keyIDs, func(locations map[string]interface{}, fragment string) {
+		delete(locations, fragment)
+	})
+}
+
+// updateLocations changes the key locations recorded for verification methods in their DIDs' KV
+// metadata
+func (v *vaultStore) updateLocations(ctx context.Context, keyIDs []string, change func(locations map[string]interface{}, fragment string)) error {
+	// Vault replaces custom metadata as a whole, so merge into each DID's existing entries
+	byDID := map[string][]string{}
+	var dids []string
+	for _, keyID := range keyIDs {
+		did, fragment, err := splitKeyID(keyID)
+		if err != nil {
+			return err
+		}
+		if _, ok := byDID[did]; !ok {
+			dids = append(dids, did)
+		}
+		byDID[did] = append(byDID[did], fragment)
+	}
+
+	for _, did := range dids {
+		customMetadata, err := v.locations(ctx, did)
+		if err != nil {
+			return err
+		}
+		if customMetadata == nil {
+			customMetadata = map[string]interface{}{}
+		}
+		for _, fragment := range byDID[did] {
+			change(customMetadata, fragment)
+		}
+
+		_, err = v.client.Logical().WriteWithContext(ctx, v.kvPath("metadata", "dids/"+did), map[string]interface{}{
+			"custom_metadata": customMetadata,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record key locations in Vault: %w", err)
+		}
+	}
+	return nil
+}
+
+func (v *vaultStore) PublicKey(ctx context.Context, keyID string) (Key, error) {
+	location, err := v.location(ctx, keyID)
+	if err != nil {
+		return Key{}, err
+	}
+	if location.kind == locationTransit {
+		return v.transitKey(ctx, location)
+	}
+
+	keyType, privateKey, err := v.kvKey(ctx, location)
+	if err != nil {
+		return Key{}, err
+	}
+	publicKey, err := publicKeyOf(keyType, privateKey)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{Name: keyName(locationKV, location.name), Version: location.version, Type: keyType, PublicKey: publicKey}, nil
+}
+
+func (v *vaultStore) Sign(ctx context.Context, keyID string, data []byte) ([]byte, error) {
+	location, err := v.location(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if location.kind == locationKV {
+		keyType, privateKey, err := v.kvKey(ctx, location)
+		if err != nil {
+			return nil, err
+		}
+		return signLocal(keyType, privateKey, data)
+	}
+
+	key, err := v.transitKey(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	// ECDSA signatures are over the SHA-256 digest, as the raw r || s of the JWS encoding
+	request := map[string]interface{}{
+		"input":       base64.StdEncoding.EncodeToString(data),
+		"key_version": key.Version,
+	}
+	if key.Type == P256 {
+		request["hash_algorithm"] = "sha2-256"
+		request["marshaling_algorithm"] = "jws"
+	}
+
+	secret, err := v.client.Logical().WriteWithContext(ctx, v.transitPath("sign", location.name), request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign with Transit key %s: %w", location.name, err)
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("transit key %s returned no signature", location.name)
+	}
+	signature, _ := secret.Data["signature"].(string)
+
+	return decodeTransitSignature(key.Type, signature)
+}
+
+func (v *vaultStore) Discard(ctx context.Context, key Key) error {
+	location, err := parseKeyName(key.Name)
+	if err != nil {
+		return err
+	}
+	return v.destroyKey(ctx, location)
+}
+
+func (v *vaultStore) Destroy(ctx context.Context, did string) error {
+	customMetadata, err := v.locations(ctx, did)
+	if err != nil {
+		return err
+	}
+
+	// The metadata names the keys, so destroy them before the metadata itself
+	destroyed := map[keyLocation]bool{}
+	for _, value := range customMetadata {
+		recorded, _ := value.(string)
+		location, err := parseKeyLocation(did, recorded)
+		if err != nil {
+			return err
+		}
+		location.version = 0
+		if location.name == "dids/"+did || destroyed[location] {
+			continue
+		}
+		if err := v.destroyKey(ctx, location); err != nil {
+			return err
+		}
+		destroyed[location] = true
+	}
+
+	// Deleting the metadata path destroys every version, unlike deleting the data path
+	if _, err := v.client.Logical().DeleteWithContext(ctx, v.kvPath("metadata", "dids/"+did)); err != nil {
+		return fmt.Errorf("failed to destroy private keys in Vault: %w", err)
+	}
+	return nil
+}
+
+// destroyKey permanently deletes every version of a key. Transit refuses to delete keys until
+// deletion is explicitly allowed in their configuration.
+func (v *vaultStore) destroyKey(ctx context.Context, location keyLocation) error {
+	if location.kind == locationKV {
+		if _, err := v.client.Logical().DeleteWithContext(ctx, v.kvPath("metadata", location.name)); err != nil {
+			return fmt.Errorf("failed to destroy private key %s: %w", location.name, err)
+		}
+		return nil
+	}
+
+	_, err := v.client.Logical().WriteWithContext(ctx, v.transitPath("keys", location.name, "config"), map[string]interface{}{
+		"deletion_allowed": true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to allow deletion of Transit key %s: %w", location.name, err)
+	}
+	if _, err := v.client.Logical().DeleteWithContext(ctx, v.transitPath("keys", location.name)); err != nil {
+		return fmt.Errorf("failed to delete Transit key %s: %w", location.name, err)
+	}
+	return nil
+}
+
+// locations reads the key locations recorded in a DID's KV metadata, nil when there are none
+func (v *vaultStore) locations(ctx context.Context, did string) (map[string]interface{}, error) {
+	metadata, err := v.client.Logical().ReadWithContext(ctx, v.kvPath("metadata", "dids/"+did))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key locations from Vault: %w", err)
+	}
+	if metadata == nil {
+		return nil, nil
+	}
+	customMetadata, _ := metadata.Data["custom_metadata"].(map[string]interface{})
+	return customMetadata, nil
+}
+
+// location looks up the key version of a verification method
+func (v *vaultStore) location(ctx context.Context, keyID string) (keyLocation, error) {
+	did, fragment, err := splitKeyID(keyID)
+	if err != nil {
+		return keyLocation{}, err
+	}
+	customMetadata, err := v.locations(ctx, did)
+	if err != nil {
+		return keyLocation{}, err
+	}
+	if len(customMetadata) == 0 {
+		return parseKeyLocation(did, "")
+	}
+
+	// Once keys are tracked, never fall back to whichever key happens to be the latest
+	recorded, ok := customMetadata[fragment].(string)
+	if !ok {
+		return keyLocation{}, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+	return parseKeyLocation(did, recorded)
+}
+
+// kvKey reads the key type and private key material of a KV key version. Keys stored before key
+// types were recorded are Ed25519.
+func (v *vaultStore) kvKey(ctx context.Context, location keyLocation) (string, []byte, error) {
+	path := v.kvPath("data", location.name)
+
+	var secret *api.Secret
+	var err error
+	if location.version == 0 {
+		secret, err = v.client.Logical().ReadWithContext(ctx, path)
+	} else {
+		secret, err = v.client.Logical().ReadWithDataWithContext(ctx, path, map[string][]string{
+			"version": {strconv.Itoa(location.version)},
+		})
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read private key from Vault: %w", err)
+	}
+	if secret == nil {
+		return "", nil, fmt.Errorf("%w: no secret found at path %s", ErrKeyNotFound, path)
+	}
+
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("secret data is not in the expected format")
+	}
+	privateKeyBase64, ok := data["private_key"].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("private key not found in secret data")
+	}
+	keyType, _ := data["key_type"].(string)
+	if keyType == "" {
+		keyType = Ed25519
+	}
+
+	privateKey, err := base64.StdEncoding.DecodeString(privateKeyBase64)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to decode base64 private key: %w", err)
+	}
+	if err := checkPrivateKey(keyType, privateKey); err != nil {
+		return "", nil, err
+	}
+	return keyType, privateKey, nil
+}
+
+// transitKey reads the type and public key of a Transit key version, the latest when the
+// location has no version
+func (v *vaultStore) transitKey(ctx context.Context, location keyLocation) (Key, error) {
+	secret, err := v.client.Logical().ReadWithContext(ctx, v.transitPath("keys", location.name))
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to read Transit key: %w", err)
+	}
+	if secret == nil {
+		return Key{}, fmt.Errorf("%w: transit key %s not found", ErrKeyNotFound, location.name)
+	}
+
+	transitType, _ := secret.Data["type"].(string)
+	var keyType string
+	for ourType, theirType := range transitKeyTypes {
+		if theirType == transitType {
+			keyType = ourType
+		}
+	}
+	if keyType == "" {
+		return Key{}, fmt.Errorf("%w: Transit key type %q", ErrUnsupportedKeyType, transitType)
+	}
+
+	version := location.version
+	if version == 0 {
+		version, err = strconv.Atoi(fmt.Sprint(secret.Data["latest_version"]))
+		if err != nil {
+			return Key{}, fmt.Errorf("transit key %s has no latest version", location.name)
+		}
+	}
+	keys, _ := secret.Data["keys"].(map[string]interface{})
+	keyVersion, ok := keys[strconv.Itoa(version)].(map[string]interface{})
+	if !ok {
+		return Key{}, fmt.Errorf("%w: transit key %s has no version %d", ErrKeyNotFound, location.name, version)
+	}
+	encodedKey, _ := keyVersion["public_key"].(string)
+
+	publicKey, err := decodeTransitPublicKey(keyType, encodedKey)
+	if err != nil {
+		return Key{}, fmt.Errorf("transit key %s: %w", location.name, err)
+	}
+	return Key{Name: keyName(locationTransit, location.name), Version: version, Type: keyType, PublicKey: publicKey}, nil
+}
+
+func (v *vaultStore) kvPath(kind, path string) string {
+	return fmt.Sprintf("%s/%s/%s", v.kvMount, kind, path)
+}
+
+func (v *vaultStore) transitPath(parts ...string) string {
+	return v.transit + "/" + strings.Join(parts, "/")
+}
+
+// decodeTransitPublicKey converts a Transit public key to its raw form: Transit returns Ed25519
+// keys base64 encoded and ECDSA keys as PEM encoded PKIX structures
+func decodeTransitPublicKey(keyType, encodedKey string) ([]byte, error) {
+	switch keyType {
+	case Ed25519:
+		publicKey, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key")
+		}
+		return publicKey, nil
+
+	case P256:
+		block, _ := pem.Decode([]byte(encodedKey))
+		if block == nil {
+			return nil, fmt.Errorf("invalid P-256 public key")
+		}
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid P-256 public key: %w", err)
+		}
+		ecPublicKey, ok := parsed.(*ecdsa.PublicKey)
+		if !ok || ecPublicKey.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("public key is not a P-256 key")
+		}
+		return elliptic.MarshalCompressed(elliptic.P256(), ecPublicKey.X, ecPublicKey.Y), nil
+
+	default:
+		return nil, fmt.Errorf("%w: %q is not a Transit key type", ErrUnsupportedKeyType, keyType)
+	}
+}
+
+// decodeTransitSignature strips the "vault:v<version>:" prefix of a Transit signature and decodes
+// it: base64 for Ed25519, unpadded base64url for JWS marshaled ECDSA signatures
+func decodeTransitSignature(keyType, signature string) ([]byte, error) {
+	parts := strings.SplitN(signature, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, fmt.Errorf("unexpected Transit signature format")
+	}
+
+	encoding := base64.StdEncoding
+	if keyType == P256 {
+		encoding = base64.RawURLEncoding
+	}
+	decoded, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Transit signature: %w", err)
+	}
+	return decoded, nil
+}
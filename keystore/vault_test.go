@@ -0,0 +1,347 @@
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// fakeTransitKey is a Transit key of a fakeVault, one private key per version
+type fakeTransitKey struct {
+	keyType         string // Transit key type, e.g. "ed25519"
+	versions        []interface{}
+	deletionAllowed bool
+}
+
+// fakeVault serves the parts of the KV v2 and Transit APIs the Vault key store uses
+type fakeVault struct {
+	mu       sync.Mutex
+	kv       map[string][]map[string]interface{} // Secret versions by path below the mount
+	metadata map[string]map[string]interface{}   // Custom metadata by path below the mount
+	transit  map[string]*fakeTransitKey
+}
+
+// newTestVault starts a fakeVault and returns a Vault key store using it
+func newTestVault(t *testing.T, transit bool) (KeyStore, *fakeVault) {
+	t.Helper()
+	vault := &fakeVault{
+		kv:       map[string][]map[string]interface{}{},
+		metadata: map[string]map[string]interface{}{},
+		transit:  map[string]*fakeTransitKey{},
+	}
+	server := httptest.NewServer(vault)
+	t.Cleanup(server.Close)
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	config.MaxRetries = 0
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create Vault client: %v", err)
+	}
+	client.SetToken("test-token")
+
+	store, err := NewVault(VaultConfig{Client: client, Transit: transit})
+	if err != nil {
+		t.Fatalf("Failed to create Vault store: %v", err)
+	}
+	return store, vault
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	var data interface{}
+	var status int
+	switch {
+	case strings.HasPrefix(path, "secret/data/"):
+		data, status = f.serveKVData(r, strings.TrimPrefix(path, "secret/data/"), body)
+	case strings.HasPrefix(path, "secret/metadata/"):
+		data, status = f.serveKVMetadata(r, strings.TrimPrefix(path, "secret/metadata/"), body)
+	case strings.HasPrefix(path, "transit/keys/"):
+		data, status = f.serveTransitKey(r, strings.TrimPrefix(path, "transit/keys/"), body)
+	case strings.HasPrefix(path, "transit/sign/"):
+		data, status = f.serveTransitSign(strings.TrimPrefix(path, "transit/sign/"), body)
+	default:
+		status = http.StatusNotFound
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case status != http.StatusOK:
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{}})
+	case data == nil:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}
+}
+
+func (f *fakeVault) serveKVData(r *http.Request, path string, body map[string]interface{}) (interface{}, int) {
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		secret, _ := body["data"].(map[string]interface{})
+		f.kv[path] = append(f.kv[path], secret)
+		return map[string]interface{}{"version": len(f.kv[path])}, http.StatusOK
+	case http.MethodGet:
+		versions := f.kv[path]
+		version := len(versions)
+		if requested := r.URL.Query().Get("version"); requested != "" {
+			version, _ = strconv.Atoi(requested)
+		}
+		if version < 1 || version > len(versions) {
+			return nil, http.StatusNotFound
+		}
+		return map[string]interface{}{
+			"data":     versions[version-1],
+			"metadata": map[string]interface{}{"version": version},
+		}, http.StatusOK
+	}
+	return nil, http.StatusMethodNotAllowed
+}
+
+func (f *fakeVault) serveKVMetadata(r *http.Request, path string, body map[string]interface{}) (interface{}, int) {
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		customMetadata, _ := body["custom_metadata"].(map[string]interface{})
+		f.metadata[path] = customMetadata
+		return nil, http.StatusOK
+	case http.MethodGet:
+		customMetadata, ok := f.metadata[path]
+		if !ok && len(f.kv[path]) == 0 {
+			return nil, http.StatusNotFound
+		}
+		return map[string]interface{}{"custom_metadata": customMetadata, "current_version": len(f.kv[path])}, http.StatusOK
+	case http.MethodDelete:
+		delete(f.metadata, path)
+		delete(f.kv, path)
+		return nil, http.StatusOK
+	}
+	return nil, http.StatusMethodNotAllowed
+}
+
+func (f *fakeVault) serveTransitKey(r *http.Request, path string, body map[string]interface{}) (interface{}, int) {
+	name, action, _ := strings.Cut(path, "/")
+	key, exists := f.transit[name]
+
+	switch {
+	case action == "" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		keyType, _ := body["type"].(string)
+		key = &fakeTransitKey{keyType: keyType}
+		if err := key.rotate(); err != nil {
+			return nil, http.StatusBadRequest
+		}
+		f.transit[name] = key
+		return nil, http.StatusOK
+	case !exists:
+		return nil, http.StatusNotFound
+	case action == "" && r.Method == http.MethodGet:
+		keys := map[string]interface{}{}
+		for i := range key.versions {
+			publicKey, err := key.publicKey(i + 1)
+			if err != nil {
+				return nil, http.StatusInternalServerError
+			}
+			keys[strconv.Itoa(i+1)] = map[string]interface{}{"public_key": publicKey}
+		}
+		return map[string]interface{}{"type": key.keyType, "latest_version": len(key.versions), "keys": keys}, http.StatusOK
+	case action == "rotate":
+		if err := key.rotate(); err != nil {
+			return nil, http.StatusInternalServerError
+		}
+		return nil, http.StatusOK
+	case action == "config":
+		key.deletionAllowed, _ = body["deletion_allowed"].(bool)
+		return nil, http.StatusOK
+	case action == "" && r.Method == http.MethodDelete:
+		if !key.deletionAllowed {
+			return nil, http.StatusBadRequest
+		}
+		delete(f.transit, name)
+		return nil, http.StatusOK
+	}
+	return nil, http.StatusMethodNotAllowed
+}
+
+func (f *fakeVault) serveTransitSign(name string, body map[string]interface{}) (interface{}, int) {
+	key, ok := f.transit[name]
+	if !ok {
+		return nil, http.StatusNotFound
+	}
+	input, err := base64.StdEncoding.DecodeString(fmt.Sprint(body["input"]))
+	version, _ := strconv.Atoi(fmt.Sprint(body["key_version"]))
+	if err != nil || version < 1 || version > len(key.versions) {
+		return nil, http.StatusBadRequest
+	}
+
+	var signature string
+	switch privateKey := key.versions[version-1].(type) {
+	case ed25519.PrivateKey:
+		signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, input))
+	case *ecdsa.PrivateKey:
+		if body["hash_algorithm"] != "sha2-256" || body["marshaling_algorithm"] != "jws" {
+			return nil, http.StatusBadRequest
+		}
+		digest := sha256.Sum256(input)
+		r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest[:])
+		if err != nil {
+			return nil, http.StatusInternalServerError
+		}
+		signature = base64.RawURLEncoding.EncodeToString(append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...))
+	}
+	return map[string]interface{}{"signature": fmt.Sprintf("vault:v%d:%s", version, signature)}, http.StatusOK
+}
+
+// rotate adds a new version to the key
+func (k *fakeTransitKey) rotate() error {
+	switch k.keyType {
+	case "ed25519":
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		k.versions = append(k.versions, privateKey)
+	case "ecdsa-p256":
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		k.versions = append(k.versions, privateKey)
+	default:
+		return fmt.Errorf("unsupported Transit key type %q", k.keyType)
+	}
+	return nil
+}
+
+// publicKey encodes the public key of a key version as Transit does
+func (k *fakeTransitKey) publicKey(version int) (string, error) {
+	switch privateKey := k.versions[version-1].(type) {
+	case ed25519.PrivateKey:
+		return base64.StdEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey)), nil
+	default:
+		der, err := x509.MarshalPKIXPublicKey(&privateKey.(*ecdsa.PrivateKey).PublicKey)
+		if err != nil {
+			return "", err
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+	}
+}
+
+func TestVaultKVStore(t *testing.T) {
+	store, vault := newTestVault(t, false)
+	testKeyStore(t, store)
+
+	if len(vault.transit) != 0 {
+		t.Errorf("Expected no Transit keys without Transit, got %d", len(vault.transit))
+	}
+	if len(vault.kv) != 0 || len(vault.metadata) != 0 {
+		t.Errorf("Expected Destroy to remove every secret, got %v %v", vault.kv, vault.metadata)
+	}
+}
+
+func TestVaultTransitStore(t *testing.T) {
+	store, vault := newTestVault(t, true)
+	testKeyStore(t, store)
+
+	// Ed25519 and P-256 keys lived in Transit, and Destroy deleted them
+	if len(vault.transit) != 0 {
+		t.Errorf("Expected Destroy to delete every Transit key, got %d", len(vault.transit))
+	}
+	if len(vault.kv) != 0 || len(vault.metadata) != 0 {
+		t.Errorf("Expected Destroy to remove every secret, got %v %v", vault.kv, vault.metadata)
+	}
+
+	ctx := context.Background()
+	for keyType, transitType := range transitKeyTypes {
+		key, err := store.Create(ctx, keyType)
+		if err != nil {
+			t.Fatalf("Failed to create %s key: %v", keyType, err)
+		}
+		location, err := parseKeyName(key.Name)
+		if err != nil || location.kind != locationTransit || vault.transit[location.name] == nil || vault.transit[location.name].keyType != transitType {
+			t.Errorf("Expected a %s Transit key, got %s", transitType, key.Name)
+		}
+	}
+}
+
+func TestVaultDiscard(t *testing.T) {
+	store, vault := newTestVault(t, true)
+	ctx := context.Background()
+
+	for _, keyType := range []string{Ed25519, Secp256k1} {
+		keyID := testDID + "#" + keyType
+		key, err := store.Create(ctx, keyType)
+		if err != nil {
+			t.Fatalf("Failed to create %s key: %v", keyType, err)
+		}
+		if err := store.Assign(ctx, key, keyID); err != nil {
+			t.Fatalf("Failed to assign %s key: %v", keyType, err)
+		}
+		if err := store.Discard(ctx, key); err != nil {
+			t.Fatalf("Failed to discard %s key: %v", keyType, err)
+		}
+		if _, err := store.Sign(ctx, keyID, []byte("data")); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Expected ErrKeyNotFound signing with a discarded %s key, got %v", keyType, err)
+		}
+	}
+	if len(vault.transit) != 0 || len(vault.kv) != 0 {
+		t.Errorf("Expected the discarded keys to be deleted, got %d Transit keys and %v", len(vault.transit), vault.kv)
+	}
+}
+
+func TestVaultLegacyKey(t *testing.T) {
+	store, vault := newTestVault(t, true)
+	ctx := context.Background()
+
+	// Releases before the key store kept a DID's only key in the DID's own secret
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	vault.kv["dids/"+testDID] = []map[string]interface{}{{"private_key": base64.StdEncoding.EncodeToString(privateKey)}}
+
+	keyID := testDID + "#keys-1"
+	key, err := store.PublicKey(ctx, keyID)
+	if err != nil || key.Type != Ed25519 || !bytes.Equal(key.PublicKey, publicKey) {
+		t.Fatalf("Expected the legacy key, got %+v (%v)", key, err)
+	}
+	signature, err := store.Sign(ctx, keyID, []byte("data"))
+	if err != nil {
+		t.Fatalf("Failed to sign with legacy key: %v", err)
+	}
+	verify(t, key, []byte("data"), signature)
+
+	// Rotating moves the key to a secret of its own
+	rotated, err := store.Rotate(ctx, keyID)
+	if err != nil {
+		t.Fatalf("Failed to rotate legacy key: %v", err)
+	}
+	if rotated.Name == keyName(locationKV, "dids/"+testDID) || rotated.Version != 1 {
+		t.Errorf("Expected a new key, got %s version %d", rotated.Name, rotated.Version)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DIDURL is a parsed DID URL: did:<method>:<id>[/path][?query][#fragment]
+type DIDURL struct {
+	DID      string
+	Path     string
+	Query    url.Values
+	Fragment string
+}
+
+// parseDIDURL splits a DID URL into the DID and its path, query and fragment components
+func parseDIDURL(didURL string) (DIDURL, error) {
+	var parsed DIDURL
+
+	rest, fragment, _ := strings.Cut(didURL, "#")
+	parsed.Fragment = fragment
+
+	rest, rawQuery, _ := strings.Cut(rest, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return DIDURL{}, fmt.Errorf("%w: invalid query in %s", errInvalidDID, didURL)
+	}
+	parsed.Query = query
+
+	if slash := strings.Index(rest, "/"); slash >= 0 {
+		parsed.DID, parsed.Path = rest[:slash], rest[slash:]
+	} else {
+		parsed.DID = rest
+	}
+
+	if _, err := parseDIDMethod(parsed.DID); err != nil {
+		return DIDURL{}, err
+	}
+
+	return parsed, nil
+}
+
+// dereferenceFragment selects the verification method or service a fragment identifies within a
+// DID document
+func dereferenceFragment(didDocument DIDDocument, fragment string) (interface{}, error) {
+	absoluteID := didDocument.ID + "#" + fragment
+
+	// Older documents list their keys under "publicKey" rather than "verificationMethod"
+	methods := append(append([]VerificationMethod{}, didDocument.VerificationMethod...), didDocument.PublicKey...)
+	for _, method := range methods {
+		if method.ID == absoluteID || method.ID == "#"+fragment {
+			return method, nil
+		}
+	}
+	for _, service := range didDocument.Service {
+		if service.ID == absoluteID || service.ID == "#"+fragment {
+			return service, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", errDIDNotFound, absoluteID)
+}
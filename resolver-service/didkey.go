@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mr-tron/base58"
+)
+
+const (
+	didKeyPrefix = "did:key:"
+
+	// multibaseBase58BTC is the multibase prefix for base58btc encoded values
+	multibaseBase58BTC = "z"
+)
+
+// Key types resolver-service understands in multibase encoded public keys
+const (
+	keyTypeEd25519    = "Ed25519"
+	keyTypeP256       = "P-256"
+	keyTypeSecp256k1  = "secp256k1"
+	keyTypeBLS12381G2 = "BLS12-381-G2"
+	keyTypeX25519     = "X25519"
+)
+
+// multicodecKeyTypes describes each supported multicodec public key: its varint encoded prefix and
+// the length of the key that follows. P-256 and secp256k1 keys are compressed points.
+var multicodecKeyTypes = []struct {
+	keyType string
+	prefix  []byte
+	length  int
+}{
+	{keyType: keyTypeEd25519, prefix: []byte{0xed, 0x01}, length: 32},    // ed25519-pub (0xed)
+	{keyType: keyTypeP256, prefix: []byte{0x80, 0x24}, length: 33},       // p256-pub (0x1200)
+	{keyType: keyTypeSecp256k1, prefix: []byte{0xe7, 0x01}, length: 33},  // secp256k1-pub (0xe7)
+	{keyType: keyTypeBLS12381G2, prefix: []byte{0xeb, 0x01}, length: 96}, // bls12_381-g2-pub (0xeb)
+	{keyType: keyTypeX25519, prefix: []byte{0xec, 0x01}, length: 32},     // x25519-pub (0xec)
+}
+
+// didKeyResolver derives did:key documents from the identifier alone, without a database hit
+// for the document itself. The registry is only consulted for document metadata of DIDs minted
+// by did-service, and for identifiers minted with the old base64url encoding, which are not
+// self-describing.
+type didKeyResolver struct {
+	registry registryResolver
+}
+
+func (r didKeyResolver) Resolve(ctx context.Context, did string) (DIDDocument, DocumentMetadata, error) {
+	multibaseKey := strings.TrimPrefix(did, didKeyPrefix)
+
+	if keyType, _, err := decodeMultibaseKey(multibaseKey); err != nil || keyType == keyTypeX25519 {
+		if strings.HasPrefix(did, "did:key:z6M") {
+			return r.registry.Resolve(ctx, did)
+		}
+		if err == nil {
+			err = fmt.Errorf("X25519 keys cannot be used as did:key signing keys")
+		}
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: %v", errInvalidDID, err)
+	}
+
+	metadata, err := r.registry.Metadata(ctx, did)
+	if err != nil {
+		// The document is self-describing, so a registry failure only costs us metadata
+		log.Printf("Failed to load metadata for %s: %v", did, err)
+	}
+
+	return newDIDKeyDocument(did, multibaseKey), metadata, nil
+}
+
+// decodeMultibaseKey parses a base58btc multibase, multicodec prefixed public key and returns
+// its key type and raw key bytes
+func decodeMultibaseKey(multibaseKey string) (string, []byte, error) {
+	if !strings.HasPrefix(multibaseKey, multibaseBase58BTC) {
+		return "", nil, fmt.Errorf("public key is not base58btc multibase encoded")
+	}
+
+	decoded, err := base58.Decode(strings.TrimPrefix(multibaseKey, multibaseBase58BTC))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to decode base58btc public key: %w", err)
+	}
+
+	for _, codec := range multicodecKeyTypes {
+		if !bytes.HasPrefix(decoded, codec.prefix) {
+			continue
+		}
+		publicKey := decoded[len(codec.prefix):]
+		if len(publicKey) != codec.length {
+			return "", nil, fmt.Errorf("invalid %s public key length: %d", codec.keyType, len(publicKey))
+		}
+		return codec.keyType, publicKey, nil
+	}
+
+	return "", nil, fmt.Errorf("public key has an unsupported multicodec type")
+}
+
+// newDIDKeyDocument builds the DID Core document of a did:key identifier, matching the
+// documents did-service stores when it mints the DID
+func newDIDKeyDocument(did, multibaseKey string) DIDDocument {
+	keyID := fmt.Sprintf("%s#%s", did, multibaseKey)
+
+	return DIDDocument{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID: did,
+		VerificationMethod: []VerificationMethod{
+			{
+				ID:                 keyID,
+				Type:               "Multikey",
+				Controller:         did,
+				PublicKeyMultibase: multibaseKey,
+			},
+		},
+		Authentication:  []string{keyID},
+		AssertionMethod: []string{keyID},
+	}
+}
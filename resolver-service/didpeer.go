@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const didPeerPrefix = "did:peer:"
+
+// didPeerServiceAbbreviations expands the abbreviated keys and values of numalgo 2 services
+var didPeerServiceAbbreviations = map[string]string{
+	"t":  "type",
+	"s":  "serviceEndpoint",
+	"r":  "routingKeys",
+	"a":  "accept",
+	"dm": "DIDCommMessaging",
+}
+
+// didPeerResolver decodes did:peer documents from the identifier itself. Numalgo 0 wraps a
+// single key like did:key; numalgo 2 encodes every key and service as a "."-separated element.
+// The registry is only consulted for document metadata of DIDs minted by did-service.
+type didPeerResolver struct {
+	registry registryResolver
+}
+
+func (r didPeerResolver) Resolve(ctx context.Context, did string) (DIDDocument, DocumentMetadata, error) {
+	numalgo := strings.TrimPrefix(did, didPeerPrefix)
+
+	var didDocument DIDDocument
+	var err error
+	switch {
+	case strings.HasPrefix(numalgo, "0"):
+		didDocument, err = decodeDIDPeer0(did, strings.TrimPrefix(numalgo, "0"))
+	case strings.HasPrefix(numalgo, "2"):
+		didDocument, err = decodeDIDPeer2(did, strings.TrimPrefix(numalgo, "2"))
+	case numalgo != "" && strings.Contains("134", numalgo[:1]):
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: did:peer numalgo %s", errMethodNotSupported, numalgo[:1])
+	default:
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: unknown did:peer numalgo in %s", errInvalidDID, did)
+	}
+	if err != nil {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: %v", errInvalidDID, err)
+	}
+
+	metadata, err := r.registry.Metadata(ctx, did)
+	if err != nil {
+		// The document is self-describing, so a registry failure only costs us metadata
+		log.Printf("Failed to load metadata for %s: %v", did, err)
+	}
+
+	return didDocument, metadata, nil
+}
+
+// decodeDIDPeer0 builds the document of a did:peer:0 identifier from its inception key
+func decodeDIDPeer0(did, multibaseKey string) (DIDDocument, error) {
+	keyType, _, err := decodeMultibaseKey(multibaseKey)
+	if err != nil {
+		return DIDDocument{}, err
+	}
+	if keyType == keyTypeX25519 {
+		return DIDDocument{}, fmt.Errorf("did:peer:0 inception key cannot be an X25519 key")
+	}
+
+	return newDIDKeyDocument(did, multibaseKey), nil
+}
+
+// decodeDIDPeer2 builds the document of a did:peer:2 identifier. Keys are numbered #key-1,
+// #key-2, ... and services #service, #service-1, ... in the order they appear.
+func decodeDIDPeer2(did, elements string) (DIDDocument, error) {
+	if !strings.HasPrefix(elements, ".") {
+		return DIDDocument{}, fmt.Errorf("malformed did:peer:2 identifier")
+	}
+
+	didDocument := DIDDocument{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID: did,
+	}
+
+	keys, services := 0, 0
+	for _, element := range strings.Split(elements[1:], ".") {
+		if len(element) < 2 {
+			return DIDDocument{}, fmt.Errorf("empty did:peer:2 element")
+		}
+		purpose, value := element[0], element[1:]
+
+		if purpose == 'S' {
+			service, err := decodeDIDPeerService(value)
+			if err != nil {
+				return DIDDocument{}, err
+			}
+			switch {
+			case service.ID == "" && services == 0:
+				service.ID = did + "#service"
+			case service.ID == "":
+				service.ID = fmt.Sprintf("%s#service-%d", did, services)
+			case strings.HasPrefix(service.ID, "#"):
+				service.ID = did + service.ID
+			}
+			didDocument.Service = append(didDocument.Service, service)
+			services++
+			continue
+		}
+
+		if err := validateDIDPeerKey(purpose, value); err != nil {
+			return DIDDocument{}, err
+		}
+		keys++
+		keyID := fmt.Sprintf("%s#key-%d", did, keys)
+		didDocument.VerificationMethod = append(didDocument.VerificationMethod, VerificationMethod{
+			ID:                 keyID,
+			Type:               "Multikey",
+			Controller:         did,
+			PublicKeyMultibase: value,
+		})
+
+		switch purpose {
+		case 'V':
+			didDocument.Authentication = append(didDocument.Authentication, keyID)
+		case 'A':
+			didDocument.AssertionMethod = append(didDocument.AssertionMethod, keyID)
+		case 'E':
+			didDocument.KeyAgreement = append(didDocument.KeyAgreement, keyID)
+		}
+	}
+
+	if keys == 0 {
+		return DIDDocument{}, fmt.Errorf("did:peer:2 identifier has no keys")
+	}
+	return didDocument, nil
+}
+
+// validateDIDPeerKey checks that a numalgo 2 key element carries a key fit for its purpose:
+// X25519 for key agreement, a signing key for everything else
+func validateDIDPeerKey(purpose byte, multibaseKey string) error {
+	if !strings.ContainsRune("AVEID", rune(purpose)) {
+		return fmt.Errorf("unsupported did:peer:2 purpose code %q", purpose)
+	}
+
+	keyType, _, err := decodeMultibaseKey(multibaseKey)
+	if err != nil {
+		return err
+	}
+	if (purpose == 'E') != (keyType == keyTypeX25519) {
+		return fmt.Errorf("%s key cannot be used with did:peer:2 purpose code %q", keyType, purpose)
+	}
+	return nil
+}
+
+// decodeDIDPeerService decodes a base64url encoded, abbreviated numalgo 2 service
+func decodeDIDPeerService(encoded string) (Service, error) {
+	serviceJSON, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return Service{}, fmt.Errorf("failed to decode did:peer:2 service: %w", err)
+	}
+
+	var abbreviated map[string]interface{}
+	if err := json.Unmarshal(serviceJSON, &abbreviated); err != nil {
+		return Service{}, fmt.Errorf("failed to parse did:peer:2 service: %w", err)
+	}
+
+	expanded, err := json.Marshal(expandDIDPeerAbbreviations(abbreviated))
+	if err != nil {
+		return Service{}, fmt.Errorf("failed to expand did:peer:2 service: %w", err)
+	}
+
+	var service Service
+	if err := json.Unmarshal(expanded, &service); err != nil {
+		return Service{}, fmt.Errorf("invalid did:peer:2 service: %w", err)
+	}
+	if service.Type == "" || service.ServiceEndpoint == nil {
+		return Service{}, fmt.Errorf("did:peer:2 service requires type and serviceEndpoint")
+	}
+
+	return service, nil
+}
+
+// expandDIDPeerAbbreviations replaces abbreviated keys and the abbreviated service type,
+// including inside nested serviceEndpoint objects
+func expandDIDPeerAbbreviations(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			if full, ok := didPeerServiceAbbreviations[key]; ok {
+				key = full
+			}
+			expanded[key] = expandDIDPeerAbbreviations(nested)
+		}
+		if serviceType, ok := expanded["type"].(string); ok {
+			if full, ok := didPeerServiceAbbreviations[serviceType]; ok {
+				expanded["type"] = full
+			}
+		}
+		return expanded
+	case []interface{}:
+		for i := range v {
+			v[i] = expandDIDPeerAbbreviations(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/mr-tron/base58"
+)
+
+func TestDIDPeerResolverNumalgo0(t *testing.T) {
+	multibaseKey := "z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp"
+	did := "did:peer:0" + multibaseKey
+
+	didDocument, _, err := didPeerResolver{}.Resolve(context.Background(), did)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if didDocument.ID != did || didDocument.VerificationMethod[0].PublicKeyMultibase != multibaseKey {
+		t.Errorf("Unexpected DID document %+v", didDocument)
+	}
+	if didDocument.AssertionMethod[0] != did+"#"+multibaseKey {
+		t.Errorf("Unexpected assertion method %v", didDocument.AssertionMethod)
+	}
+}
+
+func TestDIDPeerResolverNumalgo2(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	signingKey := "z" + base58.Encode(append([]byte{0xed, 0x01}, publicKey...))
+
+	agreementKey := make([]byte, 32)
+	rand.Read(agreementKey)
+	encryptionKey := "z" + base58.Encode(append([]byte{0xec, 0x01}, agreementKey...))
+
+	didcomm := base64.RawURLEncoding.EncodeToString([]byte(`{"t":"dm","s":{"uri":"https://holder.example.com/didcomm","a":["didcomm/v2"]}}`))
+	linkedDomain := base64.RawURLEncoding.EncodeToString([]byte(`{"t":"LinkedDomains","s":"https://holder.example.com"}`))
+	did := "did:peer:2.V" + signingKey + ".A" + signingKey + ".E" + encryptionKey + ".S" + didcomm + ".S" + linkedDomain
+
+	didDocument, _, err := didPeerResolver{}.Resolve(context.Background(), did)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(didDocument.VerificationMethod) != 3 {
+		t.Fatalf("Expected three verification methods, got %d", len(didDocument.VerificationMethod))
+	}
+	if didDocument.Authentication[0] != did+"#key-1" || didDocument.AssertionMethod[0] != did+"#key-2" || didDocument.KeyAgreement[0] != did+"#key-3" {
+		t.Errorf("Unexpected verification relationships %v %v %v", didDocument.Authentication, didDocument.AssertionMethod, didDocument.KeyAgreement)
+	}
+
+	if len(didDocument.Service) != 2 {
+		t.Fatalf("Expected two services, got %d", len(didDocument.Service))
+	}
+	service := didDocument.Service[0]
+	endpoint, ok := service.ServiceEndpoint.(map[string]interface{})
+	if service.ID != did+"#service" || service.Type != "DIDCommMessaging" || !ok || endpoint["uri"] != "https://holder.example.com/didcomm" || endpoint["accept"] == nil {
+		t.Errorf("Unexpected DIDComm service %+v", service)
+	}
+	service = didDocument.Service[1]
+	if service.ID != did+"#service-1" || service.Type != "LinkedDomains" || service.ServiceEndpoint != "https://holder.example.com" {
+		t.Errorf("Unexpected linked domain service %+v", service)
+	}
+}
+
+func TestDIDPeerResolverRejectsInvalidIdentifiers(t *testing.T) {
+	tests := []struct {
+		did      string
+		expected error
+	}{
+		{did: "did:peer:2", expected: errInvalidDID},
+		{did: "did:peer:2.Xz6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp", expected: errInvalidDID},
+		{did: "did:peer:2.Ez6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp", expected: errInvalidDID},
+		{did: "did:peer:2.Snot-json", expected: errInvalidDID},
+		{did: "did:peer:0zInvalid", expected: errInvalidDID},
+		{did: "did:peer:9abc", expected: errInvalidDID},
+		{did: "did:peer:4zQmd8CpeFPci817KDsbSAKWcXAE2mjw5", expected: errMethodNotSupported},
+	}
+
+	for _, tt := range tests {
+		if _, _, err := (didPeerResolver{}).Resolve(context.Background(), tt.did); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.did, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const didWebPrefix = "did:web:"
+
+// Limits on fetching did:web documents: a DID's domain may redirect within itself, but not to
+// another host, and documents larger than maxDIDWebDocumentSize are rejected
+const (
+	maxDIDWebRedirects    = 3
+	maxDIDWebDocumentSize = 1 << 20
+)
+
+// didWebResolver resolves did:web identifiers by fetching the DID document over HTTPS from the
+// DID's domain. The HTTP client is injectable so tests can point it at a local server.
+// Documents hosted by did-service also have version metadata and history in the registry.
+type didWebResolver struct {
+	client   *http.Client
+	registry registryResolver
+}
+
+// newDIDWebResolver creates a did:web resolver with a bounded request timeout
+func newDIDWebResolver() didWebResolver {
+	return didWebResolver{client: &http.Client{Timeout: 10 * time.Second}, registry: registryResolver{}}
+}
+
+// sameHostRedirect lets a did:web fetch follow HTTPS redirects within the DID's own host only, so
+// a DID cannot be resolved from a document served by another domain
+func sameHostRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxDIDWebRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxDIDWebRedirects)
+	}
+	if req.URL.Scheme != "https" || req.URL.Host != via[0].URL.Host {
+		return fmt.Errorf("redirect to %s leaves %s", req.URL, via[0].URL.Host)
+	}
+	return nil
+}
+
+// didWebURL maps a did:web identifier to the HTTPS URL of its DID document
+func didWebURL(did string) (string, error) {
+	segments := strings.Split(strings.TrimPrefix(did, didWebPrefix), ":")
+	if !strings.HasPrefix(did, didWebPrefix) || segments[0] == "" {
+		return "", fmt.Errorf("%w: %s", errInvalidDID, did)
+	}
+
+	// The domain may carry a percent-encoded port, path segments are plain
+	domain, err := url.PathUnescape(segments[0])
+	if err != nil || strings.ContainsAny(domain, "/?#@") {
+		return "", fmt.Errorf("%w: %s", errInvalidDID, did)
+	}
+
+	path := "/.well-known"
+	if len(segments) > 1 {
+		for _, segment := range segments[1:] {
+			if segment == "" || segment == "." || segment == ".." {
+				return "", fmt.Errorf("%w: %s", errInvalidDID, did)
+			}
+		}
+		path = "/" + strings.Join(segments[1:], "/")
+	}
+
+	return "https://" + domain + path + "/did.json", nil
+}
+
+func (r didWebResolver) Resolve(ctx context.Context, did string) (DIDDocument, DocumentMetadata, error) {
+	documentURL, err := didWebURL(did)
+	if err != nil {
+		return DIDDocument{}, DocumentMetadata{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, documentURL, nil)
+	if err != nil {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: %v", errInvalidDID, err)
+	}
+	req.Header.Set("Accept", "application/did+ld+json, application/json")
+
+	// Whatever client was injected, redirects must stay on the DID's host
+	client := *r.client
+	client.CheckRedirect = sameHostRedirect
+	resp, err := client.Do(req)
+	if err != nil {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("failed to fetch %s: %w", documentURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGone {
+		// did-service stops publishing deactivated DIDs; the registry still knows their last document
+		didDocument, metadata, err := r.registry.Resolve(ctx, did)
+		if err == nil && metadata.Deactivated {
+			return didDocument, metadata, nil
+		}
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: %s", errDIDNotFound, did)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: %s", errDIDNotFound, did)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("failed to fetch %s: %s", documentURL, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDIDWebDocumentSize+1))
+	if err != nil {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("failed to fetch %s: %w", documentURL, err)
+	}
+	if len(body) > maxDIDWebDocumentSize {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("DID document at %s exceeds %d bytes", documentURL, maxDIDWebDocumentSize)
+	}
+
+	var didDocument DIDDocument
+	if err := json.Unmarshal(body, &didDocument); err != nil {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("failed to decode DID document from %s: %w", documentURL, err)
+	}
+
+	// A document served for another DID must not be trusted
+	if didDocument.ID != did {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("DID document at %s is for %s, not %s", documentURL, didDocument.ID, did)
+	}
+
+	metadata, err := r.registry.Metadata(ctx, did)
+	if err != nil {
+		// The hosted document is authoritative, so a registry failure only costs us metadata
+		log.Printf("Failed to load metadata for %s: %v", did, err)
+	}
+
+	return didDocument, metadata, nil
+}
+
+// ResolveVersion returns a historic version of a did:web document. Only documents managed by
+// did-service have a history; the live did.json only ever holds the current version.
+func (r didWebResolver) ResolveVersion(ctx context.Context, did, versionID string) (DIDDocument, DocumentMetadata, error) {
+	if _, err := didWebURL(did); err != nil {
+		return DIDDocument{}, DocumentMetadata{}, err
+	}
+	return r.registry.ResolveVersion(ctx, did, versionID)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDIDWebURL(t *testing.T) {
+	tests := []struct {
+		did, expected string
+	}{
+		{did: "did:web:w3c-ccg.github.io", expected: "https://w3c-ccg.github.io/.well-known/did.json"},
+		{did: "did:web:w3c-ccg.github.io:user:alice", expected: "https://w3c-ccg.github.io/user/alice/did.json"},
+		{did: "did:web:example.com%3A3000:user:alice", expected: "https://example.com:3000/user/alice/did.json"},
+	}
+
+	for _, tt := range tests {
+		documentURL, err := didWebURL(tt.did)
+		if err != nil || documentURL != tt.expected {
+			t.Errorf("%s: expected %s, got %s (%v)", tt.did, tt.expected, documentURL, err)
+		}
+	}
+
+	for _, invalid := range []string{"did:web:", "did:web:example.com:..:admin", "did:web:evil.com%2Fpath"} {
+		if _, err := didWebURL(invalid); !errors.Is(err, errInvalidDID) {
+			t.Errorf("%s: expected an invalid DID error, got %v", invalid, err)
+		}
+	}
+}
+
+func TestDIDWebResolverFetchesDocument(t *testing.T) {
+	var did string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/issuers/hr/did.json":
+			json.NewEncoder(w).Encode(DIDDocument{
+				Context:    []string{"https://www.w3.org/ns/did/v1"},
+				ID:         did,
+				Controller: []string{"did:web:example.com"},
+				VerificationMethod: []VerificationMethod{
+					{ID: did + "#keys-1", Type: "Multikey", Controller: did, PublicKeyMultibase: "z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp"},
+				},
+				AssertionMethod: []string{did + "#keys-1"},
+				Service: []Service{
+					{ID: did + "#linked-domain", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"},
+				},
+			})
+		case "/.well-known/did.json":
+			// Serve a document for a different DID than the one requested
+			json.NewEncoder(w).Encode(DIDDocument{ID: "did:web:attacker.example"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	domain := strings.Replace(strings.TrimPrefix(server.URL, "https://"), ":", "%3A", 1)
+	did = "did:web:" + domain + ":issuers:hr"
+	resolver := didWebResolver{client: server.Client()}
+
+	didDocument, _, err := resolver.Resolve(context.Background(), did)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if didDocument.ID != did || len(didDocument.VerificationMethod) != 1 {
+		t.Errorf("Unexpected DID document %+v", didDocument)
+	}
+	if controllers, ok := didDocument.Controller.([]interface{}); !ok || len(controllers) != 1 || controllers[0] != "did:web:example.com" {
+		t.Errorf("Expected the document's controller, got %v", didDocument.Controller)
+	}
+	service, err := dereferenceFragment(didDocument, "linked-domain")
+	if err != nil || service.(Service).ServiceEndpoint != "https://example.com" {
+		t.Errorf("Expected the linked domain service, got %+v (%v)", service, err)
+	}
+
+	if _, _, err := resolver.Resolve(context.Background(), "did:web:"+domain+":unknown"); !errors.Is(err, errDIDNotFound) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+
+	if _, _, err := resolver.Resolve(context.Background(), "did:web:"+domain); err == nil {
+		t.Error("Expected a document for another DID to be rejected")
+	}
+}
+
+func TestDIDWebResolverLimits(t *testing.T) {
+	other := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(DIDDocument{ID: "did:web:elsewhere"})
+	}))
+	defer other.Close()
+
+	var domain string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved/did.json":
+			http.Redirect(w, r, "/current/did.json", http.StatusMovedPermanently)
+		case "/current/did.json":
+			json.NewEncoder(w).Encode(DIDDocument{ID: "did:web:" + domain + ":moved"})
+		case "/offsite/did.json":
+			http.Redirect(w, r, other.URL+"/did.json", http.StatusFound)
+		case "/large/did.json":
+			w.Write([]byte(`{"id":"` + strings.Repeat("a", maxDIDWebDocumentSize) + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	domain = strings.Replace(strings.TrimPrefix(server.URL, "https://"), ":", "%3A", 1)
+	client := server.Client()
+	client.Transport.(*http.Transport).TLSClientConfig.RootCAs.AddCert(other.Certificate())
+	resolver := didWebResolver{client: client}
+
+	if didDocument, _, err := resolver.Resolve(context.Background(), "did:web:"+domain+":moved"); err != nil || didDocument.ID != "did:web:"+domain+":moved" {
+		t.Errorf("Expected a redirect within the host to be followed, got %+v (%v)", didDocument, err)
+	}
+	if _, _, err := resolver.Resolve(context.Background(), "did:web:"+domain+":offsite"); err == nil || !strings.Contains(err.Error(), "redirect") {
+		t.Errorf("Expected a redirect to another host to be rejected, got %v", err)
+	}
+	if _, _, err := resolver.Resolve(context.Background(), "did:web:"+domain+":large"); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("Expected an oversized document to be rejected, got %v", err)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+
+	"github.com/gorilla/mux"
 )
 
 func resolveDIDHandler(w http.ResponseWriter, r *http.Request) {
@@ -16,16 +18,165 @@ func resolveDIDHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Println("here is the did that you want me to resolve: ", did)
 
+	// Pick the representation before resolving so unsupported ones fail fast
+	mediaType, ok := negotiateRepresentation(r.Header.Get("Accept"))
+	if !ok {
+		writeRepresentationNotSupported(w)
+		return
+	}
+
 	ctx := context.Background()
 	// Resolve the DID
-	didDocument, err := resolveDID(ctx, did)
+	didDocument, metadata, err := resolveDIDVersion(ctx, did, r.URL.Query().Get("versionId"))
 	if err != nil {
 		log.Printf("Error resolving DID: %v", err)
-		http.Error(w, "Failed to resolve DID", http.StatusInternalServerError)
+		writeResolutionError(w, mediaType, err)
+		return
+	}
+
+	writeResolution(w, mediaType, didDocument, metadata)
+}
+
+// universalResolverHandler implements the DIF Universal Resolver driver API. The identifier is
+// either a DID, which is resolved, or a DID URL, which is dereferenced: a fragment selects a
+// single verification method or service and a versionId query selects a historic document.
+func universalResolverHandler(w http.ResponseWriter, r *http.Request) {
+	identifier := mux.Vars(r)["identifier"]
+
+	mediaType, ok := negotiateRepresentation(r.Header.Get("Accept"))
+	if !ok {
+		writeRepresentationNotSupported(w)
+		return
+	}
+
+	didURL, err := parseDIDURL(identifier)
+	if err != nil {
+		log.Printf("Error parsing DID URL: %v", err)
+		writeResolutionError(w, mediaType, err)
+		return
+	}
+
+	// The version may be part of the DID URL or a query parameter of the request itself
+	versionID := didURL.Query.Get("versionId")
+	if versionID == "" {
+		versionID = r.URL.Query().Get("versionId")
+	}
+
+	ctx := context.Background()
+	didDocument, metadata, err := resolveDIDVersion(ctx, didURL.DID, versionID)
+	if err != nil {
+		log.Printf("Error resolving DID: %v", err)
+		writeResolutionError(w, mediaType, err)
+		return
+	}
+
+	if didURL.Fragment == "" {
+		writeResolution(w, mediaType, didDocument, metadata)
+		return
+	}
+
+	content, err := dereferenceFragment(didDocument, didURL.Fragment)
+	if err != nil {
+		log.Printf("Error dereferencing DID URL: %v", err)
+		writeResolutionError(w, mediaType, err)
+		return
+	}
+
+	// Respond with only the dereferenced resource when a DID document representation was requested
+	if isDocumentRepresentation(mediaType) {
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(resolutionStatus(metadata))
+		json.NewEncoder(w).Encode(content)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(resolutionStatus(metadata))
+	if err := json.NewEncoder(w).Encode(DereferencingResult{
+		Context:       didResolutionContext,
+		ContentStream: content,
+		DereferencingMetadata: ResolutionMetadata{
+			ContentType: mediaTypeDIDLDJSON,
+		},
+		ContentMetadata: metadata,
+	}); err != nil {
+		log.Printf("Failed to encode dereferencing result: %v", err)
+	}
+}
+
+// isDocumentRepresentation reports whether the client asked for the bare DID document
+func isDocumentRepresentation(mediaType string) bool {
+	return mediaType == mediaTypeDIDLDJSON || mediaType == mediaTypeDIDJSON
+}
+
+// writeResolution responds with the DID document alone or wrapped in a resolution result,
+// depending on the negotiated representation
+func writeResolution(w http.ResponseWriter, mediaType string, didDocument DIDDocument, metadata DocumentMetadata) {
+	if isDocumentRepresentation(mediaType) {
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(resolutionStatus(metadata))
+		json.NewEncoder(w).Encode(didDocument)
 		return
 	}
 
-	// Respond with the DID Document
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(didDocument)
+	writeResolutionResult(w, mediaType, resolutionStatus(metadata), ResolutionResult{
+		Context:     didResolutionContext,
+		DIDDocument: &didDocument,
+		DIDResolutionMetadata: ResolutionMetadata{
+			ContentType: mediaTypeDIDLDJSON,
+		},
+		DIDDocumentMetadata: metadata,
+	})
+}
+
+// resolutionStatus is the HTTP status of a successful resolution: 410 Gone for deactivated DIDs,
+// as the DID Resolution HTTP(S) binding specifies, 200 otherwise
+func resolutionStatus(metadata DocumentMetadata) int {
+	if metadata.Deactivated {
+		return http.StatusGone
+	}
+	return http.StatusOK
+}
+
+// writeResolutionError reports a resolution failure as a resolution result with the DID
+// Resolution error code and HTTP status
+func writeResolutionError(w http.ResponseWriter, mediaType string, err error) {
+	// Errors are always reported as a resolution result
+	if isDocumentRepresentation(mediaType) {
+		mediaType = mediaTypeJSON
+	}
+
+	code, status := resolutionError(err)
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = "Failed to resolve DID"
+	}
+
+	writeResolutionResult(w, mediaType, status, ResolutionResult{
+		Context: didResolutionContext,
+		DIDResolutionMetadata: ResolutionMetadata{
+			Error:        code,
+			ErrorMessage: message,
+		},
+	})
+}
+
+// writeRepresentationNotSupported rejects requests whose Accept header names no supported media type
+func writeRepresentationNotSupported(w http.ResponseWriter) {
+	writeResolutionResult(w, mediaTypeJSON, http.StatusNotAcceptable, ResolutionResult{
+		Context: didResolutionContext,
+		DIDResolutionMetadata: ResolutionMetadata{
+			Error:        errorRepresentationNotSupported,
+			ErrorMessage: "Supported representations: " + mediaTypeDIDLDJSON + ", " + mediaTypeDIDJSON + ", " + mediaTypeResolutionResult,
+		},
+	})
+}
+
+// writeResolutionResult writes a DID Resolution Result with the given media type and status
+func writeResolutionResult(w http.ResponseWriter, mediaType string, status int, result ResolutionResult) {
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Failed to encode resolution result: %v", err)
+	}
 }
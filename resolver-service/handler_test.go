@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+const testDIDKey = "did:key:z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp"
+
+func resolveRequest(did, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/v1/dids/resolver?did="+url.QueryEscape(did), nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rr := httptest.NewRecorder()
+	initializeRoutes().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestResolveDIDHandlerReturnsResolutionResult(t *testing.T) {
+	rr := resolveRequest(testDIDKey, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d", rr.Code)
+	}
+
+	var result ResolutionResult
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode resolution result: %v", err)
+	}
+	if result.DIDDocument == nil || result.DIDDocument.ID != testDIDKey {
+		t.Fatalf("Expected the DID document of %s, got %+v", testDIDKey, result.DIDDocument)
+	}
+	if result.DIDResolutionMetadata.ContentType != "application/did+ld+json" || result.DIDResolutionMetadata.Error != "" {
+		t.Errorf("Unexpected resolution metadata %+v", result.DIDResolutionMetadata)
+	}
+}
+
+func TestResolveDIDHandlerDocumentRepresentation(t *testing.T) {
+	rr := resolveRequest(testDIDKey, "application/did+ld+json")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d", rr.Code)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/did+ld+json" {
+		t.Errorf("Expected application/did+ld+json, got %s", contentType)
+	}
+
+	var didDocument map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&didDocument); err != nil {
+		t.Fatalf("Failed to decode DID document: %v", err)
+	}
+	if didDocument["id"] != testDIDKey {
+		t.Errorf("Expected the DID document of %s, got %v", testDIDKey, didDocument["id"])
+	}
+	// A did:key has no owning organization in the registry
+	if _, ok := didDocument["organization_id"]; ok {
+		t.Errorf("Expected no organization_id in the DID document, got %v", didDocument["organization_id"])
+	}
+}
+
+func TestResolveDIDHandlerErrors(t *testing.T) {
+	tests := []struct {
+		did, accept string
+		status      int
+		code        string
+	}{
+		{did: "not-a-did", status: http.StatusBadRequest, code: "invalidDid"},
+		{did: "did:example:123", status: http.StatusNotImplemented, code: "methodNotSupported"},
+		{did: testDIDKey, accept: "text/html", status: http.StatusNotAcceptable, code: "representationNotSupported"},
+	}
+
+	for _, tt := range tests {
+		rr := resolveRequest(tt.did, tt.accept)
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.did, tt.status, rr.Code)
+		}
+
+		var result ResolutionResult
+		if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+			t.Fatalf("%s: failed to decode resolution result: %v", tt.did, err)
+		}
+		if result.DIDResolutionMetadata.Error != tt.code || result.DIDDocument != nil {
+			t.Errorf("%s: expected error %s, got %+v", tt.did, tt.code, result.DIDResolutionMetadata)
+		}
+	}
+}
+
+func universalResolverRequest(identifier, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/1.0/identifiers/"+url.PathEscape(identifier), nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rr := httptest.NewRecorder()
+	initializeRoutes().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestUniversalResolverResolvesDID(t *testing.T) {
+	rr := universalResolverRequest(testDIDKey, `application/ld+json;profile="https://w3id.org/did-resolution"`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d", rr.Code)
+	}
+
+	var result ResolutionResult
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode resolution result: %v", err)
+	}
+	if result.DIDDocument == nil || result.DIDDocument.ID != testDIDKey {
+		t.Errorf("Expected the DID document of %s, got %+v", testDIDKey, result.DIDDocument)
+	}
+}
+
+func TestUniversalResolverDereferencesFragment(t *testing.T) {
+	keyID := testDIDKey + "#z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp"
+
+	rr := universalResolverRequest(keyID, "application/did+ld+json")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d", rr.Code)
+	}
+
+	var method VerificationMethod
+	if err := json.NewDecoder(rr.Body).Decode(&method); err != nil {
+		t.Fatalf("Failed to decode verification method: %v", err)
+	}
+	if method.ID != keyID || method.Controller != testDIDKey {
+		t.Errorf("Expected verification method %s, got %+v", keyID, method)
+	}
+
+	rr = universalResolverRequest(keyID, "")
+	var result DereferencingResult
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode dereferencing result: %v", err)
+	}
+	if content, ok := result.ContentStream.(map[string]interface{}); !ok || content["id"] != keyID {
+		t.Errorf("Expected the verification method as content stream, got %+v", result.ContentStream)
+	}
+}
+
+func TestUniversalResolverNotFound(t *testing.T) {
+	tests := []string{
+		testDIDKey + "#keys-9",
+		testDIDKey + "?versionId=42",
+	}
+
+	for _, identifier := range tests {
+		rr := universalResolverRequest(identifier, "")
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", identifier, rr.Code)
+		}
+	}
+}
+
+// deactivatedResolver resolves every DID to a deactivated document
+type deactivatedResolver struct{}
+
+func (deactivatedResolver) Resolve(ctx context.Context, did string) (DIDDocument, DocumentMetadata, error) {
+	return DIDDocument{ID: did}, DocumentMetadata{Updated: "2024-11-02T09:15:00Z", Deactivated: true}, nil
+}
+
+func TestResolveDIDHandlerDeactivated(t *testing.T) {
+	methodResolvers["deactivated"] = deactivatedResolver{}
+	defer delete(methodResolvers, "deactivated")
+
+	rr := resolveRequest("did:deactivated:123", "")
+	if rr.Code != http.StatusGone {
+		t.Fatalf("Expected status Gone, got %d", rr.Code)
+	}
+
+	var result ResolutionResult
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode resolution result: %v", err)
+	}
+	if !result.DIDDocumentMetadata.Deactivated || result.DIDDocument == nil {
+		t.Errorf("Expected the deactivated document with deactivated metadata, got %+v", result)
+	}
+}
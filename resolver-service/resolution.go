@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Media types defined by DID Core and DID Resolution
+const (
+	mediaTypeDIDLDJSON        = "application/did+ld+json"
+	mediaTypeDIDJSON          = "application/did+json"
+	mediaTypeJSON             = "application/json"
+	mediaTypeLDJSON           = "application/ld+json"
+	didResolutionContext      = "https://w3id.org/did-resolution/v1"
+	mediaTypeResolutionResult = `application/ld+json;profile="https://w3id.org/did-resolution"`
+)
+
+// DID Resolution error codes
+const (
+	errorInvalidDID                 = "invalidDid"
+	errorNotFound                   = "notFound"
+	errorMethodNotSupported         = "methodNotSupported"
+	errorRepresentationNotSupported = "representationNotSupported"
+	errorInternal                   = "internalError"
+)
+
+// ResolutionResult is the DID Resolution Result returned by the resolver
+type ResolutionResult struct {
+	Context               string             `json:"@context"`
+	DIDDocument           *DIDDocument       `json:"didDocument"`
+	DIDResolutionMetadata ResolutionMetadata `json:"didResolutionMetadata"`
+	DIDDocumentMetadata   DocumentMetadata   `json:"didDocumentMetadata"`
+}
+
+// ResolutionMetadata describes the outcome of the resolution process
+type ResolutionMetadata struct {
+	ContentType  string `json:"contentType,omitempty"`
+	Error        string `json:"error,omitempty"`
+	ErrorMessage string `json:"errorMessage,omitempty"`
+}
+
+// DocumentMetadata describes the resolved DID document
+type DocumentMetadata struct {
+	Created     string `json:"created,omitempty"`
+	Updated     string `json:"updated,omitempty"`
+	VersionID   string `json:"versionId,omitempty"`
+	Deactivated bool   `json:"deactivated,omitempty"`
+}
+
+// DereferencingResult is the DID URL Dereferencing Result returned for DID URLs
+type DereferencingResult struct {
+	Context               string             `json:"@context"`
+	ContentStream         interface{}        `json:"contentStream"`
+	DereferencingMetadata ResolutionMetadata `json:"dereferencingMetadata"`
+	ContentMetadata       DocumentMetadata   `json:"contentMetadata"`
+}
+
+// resolutionError maps a resolution failure to its DID Resolution error code and HTTP status
+func resolutionError(err error) (string, int) {
+	switch {
+	case errors.Is(err, errInvalidDID):
+		return errorInvalidDID, http.StatusBadRequest
+	case errors.Is(err, errDIDNotFound):
+		return errorNotFound, http.StatusNotFound
+	case errors.Is(err, errMethodNotSupported):
+		return errorMethodNotSupported, http.StatusNotImplemented
+	default:
+		return errorInternal, http.StatusInternalServerError
+	}
+}
+
+// negotiateRepresentation picks the response media type from the Accept header. It returns
+// the DID document media types when only the document is wanted, the resolution result
+// media type otherwise, and false when no acceptable representation is supported.
+func negotiateRepresentation(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return mediaTypeJSON, true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		switch mediaType {
+		case mediaTypeDIDLDJSON, mediaTypeDIDJSON:
+			return mediaType, true
+		case mediaTypeLDJSON:
+			// JSON-LD requests get the resolution result, which carries its own @context
+			return mediaTypeResolutionResult, true
+		case mediaTypeJSON, "application/*", "*/*":
+			return mediaTypeJSON, true
+		}
+	}
+
+	return "", false
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
@@ -22,40 +25,214 @@ func initDB() {
 }
 
 // Define the structure for the DID Document
-type PublicKey struct {
-	ID              string `json:"id"`
-	Type            string `json:"type"`
-	Controller      string `json:"controller"`
-	PublicKeyBase58 string `json:"publicKeyBase58"`
+type VerificationMethod struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller"`
+	PublicKeyMultibase string `json:"publicKeyMultibase,omitempty"`
+	PublicKeyBase58    string `json:"publicKeyBase58,omitempty"` // Only present in legacy documents
+}
+
+// Service describes a service endpoint of the DID subject. The endpoint is a URI string or,
+// for DIDComm services, an object.
+type Service struct {
+	ID              string      `json:"id"`
+	Type            string      `json:"type"`
+	ServiceEndpoint interface{} `json:"serviceEndpoint"`
+	RoutingKeys     []string    `json:"routingKeys,omitempty"`
+	Accept          []string    `json:"accept,omitempty"`
 }
 
 type DIDDocument struct {
-	Context        string      `json:"@context"`
-	ID             string      `json:"id"`
-	CreatedAt      string      `json:"createdAt"`
-	PublicKey      []PublicKey `json:"publicKey"`
-	OrganizationID string      `json:"organization_id"`
+	Context            interface{}          `json:"@context"` // A string in legacy documents, an array otherwise
+	ID                 string               `json:"id"`
+	Controller         interface{}          `json:"controller,omitempty"` // A DID or a list of DIDs
+	CreatedAt          string               `json:"createdAt,omitempty"`  // Only present in stored documents
+	VerificationMethod []VerificationMethod `json:"verificationMethod,omitempty"`
+	Authentication     []string             `json:"authentication,omitempty"`
+	AssertionMethod    []string             `json:"assertionMethod,omitempty"`
+	KeyAgreement       []string             `json:"keyAgreement,omitempty"`
+	Service            []Service            `json:"service,omitempty"`
+	PublicKey          []VerificationMethod `json:"publicKey,omitempty"`       // Only present in legacy documents
+	OrganizationID     string               `json:"organization_id,omitempty"` // Only set for DIDs read from the registry
+}
+
+// Errors returned while resolving a DID
+var (
+	errInvalidDID         = errors.New("invalid DID")
+	errMethodNotSupported = errors.New("DID method not supported")
+	errDIDNotFound        = errors.New("DID not found")
+)
+
+// MethodResolver resolves DIDs of a single DID method
+type MethodResolver interface {
+	Resolve(ctx context.Context, did string) (DIDDocument, DocumentMetadata, error)
+}
+
+// methodResolvers maps each supported DID method name to its resolver
+var methodResolvers = map[string]MethodResolver{
+	"key":  didKeyResolver{registry: registryResolver{}},
+	"web":  newDIDWebResolver(),
+	"peer": didPeerResolver{registry: registryResolver{}},
 }
 
-// Resolve the DID Document
-func resolveDID(ctx context.Context, did string) (DIDDocument, error) {
+// parseDIDMethod validates the "did:<method>:<method-specific-id>" syntax and returns the method name
+func parseDIDMethod(did string) (string, error) {
+	parts := strings.SplitN(did, ":", 3)
+	if len(parts) != 3 || parts[0] != "did" || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("%w: %s", errInvalidDID, did)
+	}
+	for _, c := range parts[1] {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			return "", fmt.Errorf("%w: %s", errInvalidDID, did)
+		}
+	}
+	return parts[1], nil
+}
+
+// Resolve the DID Document and its metadata by dispatching to the resolver of the DID's method
+func resolveDID(ctx context.Context, did string) (DIDDocument, DocumentMetadata, error) {
+	method, err := parseDIDMethod(did)
+	if err != nil {
+		return DIDDocument{}, DocumentMetadata{}, err
+	}
+
+	resolver, ok := methodResolvers[method]
+	if !ok {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: %s", errMethodNotSupported, method)
+	}
+
+	return resolver.Resolve(ctx, did)
+}
+
+// VersionResolver is implemented by method resolvers that keep a history of DID documents
+type VersionResolver interface {
+	ResolveVersion(ctx context.Context, did, versionID string) (DIDDocument, DocumentMetadata, error)
+}
+
+// resolveDIDVersion resolves the DID document version selected by versionID, or the current
+// document when versionID is empty. Methods without document history only know their current
+// version.
+func resolveDIDVersion(ctx context.Context, did, versionID string) (DIDDocument, DocumentMetadata, error) {
+	if versionID == "" {
+		return resolveDID(ctx, did)
+	}
+
+	method, err := parseDIDMethod(did)
+	if err != nil {
+		return DIDDocument{}, DocumentMetadata{}, err
+	}
+	resolver, ok := methodResolvers[method]
+	if !ok {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: %s", errMethodNotSupported, method)
+	}
+	if versioned, ok := resolver.(VersionResolver); ok {
+		return versioned.ResolveVersion(ctx, did, versionID)
+	}
+
+	didDocument, metadata, err := resolver.Resolve(ctx, did)
+	if err != nil {
+		return DIDDocument{}, DocumentMetadata{}, err
+	}
+	if metadata.VersionID != versionID {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: version %s of %s", errDIDNotFound, versionID, did)
+	}
+
+	return didDocument, metadata, nil
+}
+
+// registryResolver resolves DIDs whose documents only exist in the dids table
+type registryResolver struct{}
+
+func (registryResolver) Resolve(ctx context.Context, did string) (DIDDocument, DocumentMetadata, error) {
+	// Without a database there is no registry to look DIDs up in
+	if db == nil {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: %s", errDIDNotFound, did)
+	}
+
 	var didDocument DIDDocument
 	var organizationID string
+	var createdAt time.Time
+	var updatedAt *time.Time
+	var version int
+	var deactivated bool
 
 	// Query the database for the DID document
-	query := "SELECT document, organization_id FROM dids WHERE did = $1"
-	err := db.QueryRow(ctx, query, did).Scan(&didDocument, &organizationID)
+	query := "SELECT document, organization_id, created_at, updated_at, version_id, deactivated FROM dids WHERE did = $1"
+	err := db.QueryRow(ctx, query, did).Scan(&didDocument, &organizationID, &createdAt, &updatedAt, &version, &deactivated)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return DIDDocument{}, fmt.Errorf("DID not found: %s", did)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: %s", errDIDNotFound, did)
 		}
-		return DIDDocument{}, err
+		return DIDDocument{}, DocumentMetadata{}, err
 	}
 
 	// Set the organization ID
 	didDocument.OrganizationID = organizationID
-	// Update the created time if needed (optional)
-	didDocument.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	// The creation time is reported as document metadata rather than inside the document
+	didDocument.CreatedAt = ""
+
+	// Deactivation is reported as an update at the time of deactivation
+	metadata := versionMetadata(createdAt, updatedAt, version)
+	metadata.Deactivated = deactivated
+
+	return didDocument, metadata, nil
+}
+
+// ResolveVersion returns a historic version of a DID document from the did_document_versions table
+func (registryResolver) ResolveVersion(ctx context.Context, did, versionID string) (DIDDocument, DocumentMetadata, error) {
+	version, err := strconv.Atoi(versionID)
+	if err != nil || version < 1 {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: invalid versionId %q", errInvalidDID, versionID)
+	}
+	if db == nil {
+		return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: version %s of %s", errDIDNotFound, versionID, did)
+	}
+
+	var didDocument DIDDocument
+	var organizationID string
+	var createdAt, versionCreatedAt time.Time
+
+	query := `SELECT v.document, d.organization_id, d.created_at, v.created_at
+		FROM did_document_versions v JOIN dids d ON d.did = v.did
+		WHERE v.did = $1 AND v.version_id = $2`
+	err = db.QueryRow(ctx, query, did, version).Scan(&didDocument, &organizationID, &createdAt, &versionCreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return DIDDocument{}, DocumentMetadata{}, fmt.Errorf("%w: version %s of %s", errDIDNotFound, versionID, did)
+		}
+		return DIDDocument{}, DocumentMetadata{}, err
+	}
+
+	didDocument.OrganizationID = organizationID
+	didDocument.CreatedAt = ""
 
-	return didDocument, nil
+	var updatedAt *time.Time
+	if version > 1 {
+		updatedAt = &versionCreatedAt
+	}
+	return didDocument, versionMetadata(createdAt, updatedAt, version), nil
+}
+
+// versionMetadata builds the document metadata of a stored DID document version. updated is
+// only reported for documents that have been changed since the DID was created.
+func versionMetadata(createdAt time.Time, updatedAt *time.Time, version int) DocumentMetadata {
+	metadata := DocumentMetadata{
+		Created:   createdAt.UTC().Format(time.RFC3339),
+		VersionID: strconv.Itoa(version),
+	}
+	if updatedAt != nil {
+		metadata.Updated = updatedAt.UTC().Format(time.RFC3339)
+	}
+	return metadata
+}
+
+// Metadata returns the registry's document metadata for a DID, or empty metadata when the
+// DID was not registered through did-service
+func (r registryResolver) Metadata(ctx context.Context, did string) (DocumentMetadata, error) {
+	_, metadata, err := r.Resolve(ctx, did)
+	if errors.Is(err, errDIDNotFound) {
+		return DocumentMetadata{}, nil
+	}
+	return metadata, err
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestResolveDIDKeyOffline(t *testing.T) {
+	// Test vector from the did:key method specification
+	did := "did:key:z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp"
+
+	didDocument, _, err := resolveDID(context.Background(), did)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if didDocument.ID != did {
+		t.Errorf("Expected document for %s, got %s", did, didDocument.ID)
+	}
+	if len(didDocument.VerificationMethod) != 1 {
+		t.Fatalf("Expected one verification method, got %d", len(didDocument.VerificationMethod))
+	}
+
+	keyID := did + "#z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp"
+	if didDocument.VerificationMethod[0].ID != keyID || didDocument.AssertionMethod[0] != keyID {
+		t.Errorf("Unexpected verification method %+v", didDocument.VerificationMethod[0])
+	}
+}
+
+func TestResolveDIDKeyTypes(t *testing.T) {
+	// Test vectors from the did:key method specification
+	tests := []struct {
+		did     string
+		keyType string
+	}{
+		{did: "did:key:zDnaerDaTF5BXEavCrfRZEk316dpbLsfPDZ3WJ5hRTPFU2169", keyType: keyTypeP256},
+		{did: "did:key:zQ3shokFTS3brHcDQrn82RUDfCZESWL1ZdCEJwekUDPQiYBme", keyType: keyTypeSecp256k1},
+	}
+
+	for _, tt := range tests {
+		didDocument, _, err := resolveDID(context.Background(), tt.did)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.did, err)
+		}
+		keyType, _, err := decodeMultibaseKey(didDocument.VerificationMethod[0].PublicKeyMultibase)
+		if err != nil || keyType != tt.keyType {
+			t.Errorf("%s: expected %s key, got %s (%v)", tt.did, tt.keyType, keyType, err)
+		}
+	}
+}
+
+func TestResolveDIDErrors(t *testing.T) {
+	tests := []struct {
+		did      string
+		expected error
+	}{
+		{did: "not-a-did", expected: errInvalidDID},
+		{did: "did:Key:z6Mk", expected: errInvalidDID},
+		{did: "did:key:zNotBase58!", expected: errInvalidDID},
+		{did: "did:key:z6LSeu9HkTHSfLLeUs2nnzUSNedgDUevfNQgQjQC23ZCit6F", expected: errInvalidDID},
+		{did: "did:example:123", expected: errMethodNotSupported},
+	}
+
+	for _, tt := range tests {
+		_, _, err := resolveDID(context.Background(), tt.did)
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.did, tt.expected, err)
+		}
+	}
+}
+
+func TestResolveDIDVersion(t *testing.T) {
+	ctx := context.Background()
+
+	// did:key documents never change, so only their first version exists
+	did := "did:key:z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp"
+	if _, _, err := resolveDIDVersion(ctx, did, "2"); !errors.Is(err, errDIDNotFound) {
+		t.Errorf("Expected version 2 of a did:key not to be found, got %v", err)
+	}
+
+	if _, _, err := resolveDIDVersion(ctx, "did:web:example.com", "latest"); !errors.Is(err, errInvalidDID) {
+		t.Errorf("Expected a non-numeric versionId to be rejected, got %v", err)
+	}
+	if _, _, err := resolveDIDVersion(ctx, "did:web:example.com", "2"); !errors.Is(err, errDIDNotFound) {
+		t.Errorf("Expected an unregistered did:web version not to be found, got %v", err)
+	}
+}
@@ -12,5 +12,8 @@ func initializeRoutes() *mux.Router {
 	v1 := r.PathPrefix("/v1").Subrouter()
 	v1.HandleFunc("/dids/resolver", resolveDIDHandler).Methods("GET")
 
+	// DIF Universal Resolver driver API, so the service can be deployed as a driver
+	r.HandleFunc("/1.0/identifiers/{identifier:.+}", universalResolverHandler).Methods("GET")
+
 	return r
 }
@@ -22,6 +22,10 @@ func createSchema(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err := validateSchema(&schema); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Insert schema into database
 	schemaID, err := insertSchema(schema)
@@ -58,6 +62,27 @@ func getSchemaByID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(schema)
 }
 
+// Get Schema as the JSON Schema of its credentials
+func getSchemaJSONSchema(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	schemaID := vars["id"]
+
+	schema, err := fetchSchemaByID(schemaID)
+	if err != nil {
+		http.Error(w, "Schema not found", http.StatusNotFound)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	schemaURL := fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.Path)
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	json.NewEncoder(w).Encode(renderJSONSchema(*schema, schemaURL))
+}
+
 // Update Schema
 func updateSchema(w http.ResponseWriter, r *http.Request) {
 	var schema Schema
@@ -75,6 +100,10 @@ func updateSchema(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err := validateSchema(&schema); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	err = updateSchemaByID(schemaID, schema)
 	if err != nil {
@@ -13,6 +13,7 @@ func initializeRoutes() *mux.Router {
 	v1.HandleFunc("/schemas", createSchema).Methods("POST")
 	v1.HandleFunc("/schemas", getAllSchemas).Methods("GET")
 	v1.HandleFunc("/schemas/{id}", getSchemaByID).Methods("GET")
+	v1.HandleFunc("/schemas/{id}/json-schema", getSchemaJSONSchema).Methods("GET")
 	v1.HandleFunc("/schemas/{id}", updateSchema).Methods("PUT")
 	v1.HandleFunc("/schemas/{id}", deleteSchema).Methods("DELETE")
 
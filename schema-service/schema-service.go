@@ -3,6 +3,10 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
 
 	_ "github.com/lib/pq"
 )
@@ -11,7 +15,10 @@ type Schema struct {
 	ID              string     `json:"id"`
 	OrganizationDID string     `json:"organization_did"`
 	SchemaName      string     `json:"schema_name"`
-	Properties      []Property `json:"properties"` // Array of properties
+	Properties      []Property `json:"properties"`                 // Array of properties
+	CredentialTypes []string   `json:"credential_types,omitempty"` // Types of the credentials issued with the schema
+	Contexts        []string   `json:"contexts,omitempty"`         // JSON-LD contexts of the credentials issued with the schema
+	DefaultLifetime string     `json:"default_lifetime,omitempty"` // ISO 8601 duration the credentials are valid for by default
 	CreatedAt       string     `json:"created_at"`
 	UpdatedAt       string     `json:"updated_at"`
 }
@@ -42,15 +49,15 @@ func insertSchema(schema Schema) (string, error) {
 
 	var schemaID string
 
-	// Convert schema.Properties to JSONB format
-	schemaJson, err := json.Marshal(schema.Properties)
+	// Convert schema.Properties, CredentialTypes and Contexts to JSONB format
+	schemaJson, typesJSON, contextsJSON, err := encodeSchemaJSON(schema)
 	if err != nil {
 		return "", err
 	}
 
 	// Insert the schema into the database
-	query := "INSERT INTO schemas (organization_did, schema_name, schema_json) VALUES ($1, $2, $3) RETURNING id"
-	err = db.QueryRow(query, schema.OrganizationDID, schema.SchemaName, string(schemaJson)).Scan(&schemaID)
+	query := "INSERT INTO schemas (organization_did, schema_name, schema_json, credential_types, contexts, default_lifetime) VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')) RETURNING id"
+	err = db.QueryRow(query, schema.OrganizationDID, schema.SchemaName, schemaJson, typesJSON, contextsJSON, schema.DefaultLifetime).Scan(&schemaID)
 	if err != nil {
 		return "", err
 	}
@@ -67,7 +74,7 @@ func fetchAllSchemas() ([]Schema, error) {
 	defer db.Close()
 
 	var schemas []Schema
-	query := "SELECT id, organization_did, schema_name, schema_json, created_at, updated_at FROM schemas"
+	query := "SELECT id, organization_did, schema_name, schema_json, credential_types, contexts, COALESCE(default_lifetime, ''), created_at, updated_at FROM schemas"
 
 	rows, err := db.Query(query)
 	if err != nil {
@@ -77,15 +84,15 @@ func fetchAllSchemas() ([]Schema, error) {
 
 	for rows.Next() {
 		var schema Schema
-		var schemaJSON []byte
+		var schemaJSON, typesJSON, contextsJSON []byte
 
-		err := rows.Scan(&schema.ID, &schema.OrganizationDID, &schema.SchemaName, &schemaJSON, &schema.CreatedAt, &schema.UpdatedAt)
+		err := rows.Scan(&schema.ID, &schema.OrganizationDID, &schema.SchemaName, &schemaJSON, &typesJSON, &contextsJSON, &schema.DefaultLifetime, &schema.CreatedAt, &schema.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
 
-		// Unmarshal schemaJSON into the SchemaJSON field
-		err = json.Unmarshal(schemaJSON, &schema.Properties)
+		// Unmarshal the JSONB columns into the schema
+		err = decodeSchemaJSON(&schema, schemaJSON, typesJSON, contextsJSON)
 		if err != nil {
 			return nil, err
 		}
@@ -109,16 +116,16 @@ func fetchSchemaByID(id string) (*Schema, error) {
 	defer db.Close()
 
 	var schema Schema
-	var schemaJSON []byte
+	var schemaJSON, typesJSON, contextsJSON []byte
 
-	query := "SELECT id, organization_did, schema_name, schema_json, created_at, updated_at FROM schemas WHERE id = $1"
-	err = db.QueryRow(query, id).Scan(&schema.ID, &schema.OrganizationDID, &schema.SchemaName, &schemaJSON, &schema.CreatedAt, &schema.UpdatedAt)
+	query := "SELECT id, organization_did, schema_name, schema_json, credential_types, contexts, COALESCE(default_lifetime, ''), created_at, updated_at FROM schemas WHERE id = $1"
+	err = db.QueryRow(query, id).Scan(&schema.ID, &schema.OrganizationDID, &schema.SchemaName, &schemaJSON, &typesJSON, &contextsJSON, &schema.DefaultLifetime, &schema.CreatedAt, &schema.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 
-	// Unmarshal the schema_json field
-	err = json.Unmarshal(schemaJSON, &schema.Properties)
+	// Unmarshal the JSONB columns into the schema
+	err = decodeSchemaJSON(&schema, schemaJSON, typesJSON, contextsJSON)
 	if err != nil {
 		return nil, err
 	}
@@ -134,8 +141,13 @@ func updateSchemaByID(id string, schema Schema) error {
 	}
 	defer db.Close()
 
-	query := "UPDATE schemas SET schema_name = $1, schema_json = $2, updated_at = NOW() WHERE id = $3"
-	_, err = db.Exec(query, schema.SchemaName, schema.Properties, id)
+	schemaJSON, typesJSON, contextsJSON, err := encodeSchemaJSON(schema)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE schemas SET schema_name = $1, schema_json = $2, credential_types = $3, contexts = $4, default_lifetime = NULLIF($5, ''), updated_at = NOW() WHERE id = $6"
+	_, err = db.Exec(query, schema.SchemaName, schemaJSON, typesJSON, contextsJSON, schema.DefaultLifetime, id)
 	if err != nil {
 		return err
 	}
@@ -143,6 +155,36 @@ func updateSchemaByID(id string, schema Schema) error {
 	return nil
 }
 
+// encodeSchemaJSON encodes the properties, credential types and contexts of a schema for their
+// JSONB columns
+func encodeSchemaJSON(schema Schema) (string, string, string, error) {
+	values := []interface{}{schema.Properties, schema.CredentialTypes, schema.Contexts}
+	encoded := make([]string, len(values))
+	for i, value := range values {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", "", "", err
+		}
+		// Missing lists are stored empty
+		if string(raw) == "null" {
+			raw = []byte("[]")
+		}
+		encoded[i] = string(raw)
+	}
+	return encoded[0], encoded[1], encoded[2], nil
+}
+
+// decodeSchemaJSON decodes the JSONB columns of a schema
+func decodeSchemaJSON(schema *Schema, schemaJSON, typesJSON, contextsJSON []byte) error {
+	if err := json.Unmarshal(schemaJSON, &schema.Properties); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(typesJSON, &schema.CredentialTypes); err != nil {
+		return err
+	}
+	return json.Unmarshal(contextsJSON, &schema.Contexts)
+}
+
 // Delete a schema by its ID
 func deleteSchemaByID(id string) error {
 	db, err := openDB()
@@ -159,3 +201,104 @@ func deleteSchemaByID(id string) error {
 
 	return nil
 }
+
+// propertyTypes are the property types issuer-service checks claims against. Types are matched
+// case-insensitively and stored in lower case.
+var propertyTypes = map[string]bool{
+	"string":    true,
+	"number":    true,
+	"integer":   true,
+	"boolean":   true,
+	"object":    true,
+	"array":     true,
+	"date":      true,
+	"date-time": true,
+}
+
+// jsonSchemaTypes maps property types that are formats of JSON strings to their format
+var jsonSchemaTypes = map[string]string{
+	"date":      "date",
+	"date-time": "date-time",
+}
+
+// renderJSONSchema renders a schema as the JSON Schema of the credentials issued with it, which
+// credentials reference as their credentialSchema. The claims are the members of
+// credentialSubject, whose id is the holder's DID.
+func renderJSONSchema(schema Schema, schemaURL string) map[string]interface{} {
+	properties := map[string]interface{}{
+		"id": map[string]interface{}{"type": "string"},
+	}
+	required := []string{"id"}
+	for _, property := range schema.Properties {
+		// Schemas stored before types were checked may use any case or an unknown type, which
+		// allows any value
+		propertyType := strings.ToLower(property.Type)
+		if format, ok := jsonSchemaTypes[propertyType]; ok {
+			properties[property.Name] = map[string]interface{}{"type": "string", "format": format}
+		} else if propertyTypes[propertyType] {
+			properties[property.Name] = map[string]interface{}{"type": propertyType}
+		} else {
+			properties[property.Name] = map[string]interface{}{}
+		}
+		if property.Required {
+			required = append(required, property.Name)
+		}
+	}
+
+	credentialProperties := map[string]interface{}{
+		"credentialSubject": map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		},
+	}
+	// Credentials must carry the schema's types
+	if len(schema.CredentialTypes) > 0 {
+		contains := []interface{}{}
+		for _, credentialType := range schema.CredentialTypes {
+			contains = append(contains, map[string]interface{}{"contains": map[string]interface{}{"const": credentialType}})
+		}
+		credentialProperties["type"] = map[string]interface{}{"type": "array", "allOf": contains}
+	}
+
+	return map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"$id":        schemaURL,
+		"title":      schema.SchemaName,
+		"type":       "object",
+		"properties": credentialProperties,
+		"required":   []string{"credentialSubject"},
+	}
+}
+
+// lifetimePattern matches the ISO 8601 durations default lifetimes are given in, e.g. P1Y or P90D
+var lifetimePattern = regexp.MustCompile(`^P(?:\d+Y)?(?:\d+M)?(?:\d+W)?(?:\d+D)?(?:T(?:\d+H)?(?:\d+M)?(?:\d+S)?)?$`)
+
+// validateSchema checks the properties, credential types, contexts and default lifetime of a
+// schema: property types are propertyTypes, which it lower-cases, credential types are names
+// without whitespace, contexts are absolute URLs and the lifetime is a positive ISO 8601 duration
+func validateSchema(schema *Schema) error {
+	for i, property := range schema.Properties {
+		propertyType := strings.ToLower(property.Type)
+		if !propertyTypes[propertyType] {
+			return fmt.Errorf("property %q has unsupported type %q", property.Name, property.Type)
+		}
+		schema.Properties[i].Type = propertyType
+	}
+	if lifetime := schema.DefaultLifetime; lifetime != "" {
+		if !lifetimePattern.MatchString(lifetime) || strings.HasSuffix(lifetime, "T") || !strings.ContainsAny(lifetime, "123456789") {
+			return fmt.Errorf("default lifetime %q is not a positive ISO 8601 duration such as P1Y", lifetime)
+		}
+	}
+	for _, credentialType := range schema.CredentialTypes {
+		if credentialType == "" || strings.ContainsAny(credentialType, " \t\r\n") {
+			return fmt.Errorf("credential type %q is not valid", credentialType)
+		}
+	}
+	for _, contextURL := range schema.Contexts {
+		if u, err := url.Parse(contextURL); err != nil || !u.IsAbs() {
+			return fmt.Errorf("context %q is not an absolute URL", contextURL)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name      string
+		schema    Schema
+		wantTypes []string // Property types after validation
+		wantErr   string
+	}{
+		{
+			name: "valid schema",
+			schema: Schema{
+				Properties:      []Property{{Name: "name", Type: "string"}, {Name: "birthDate", Type: "date"}},
+				CredentialTypes: []string{"EmployeeBadge"},
+				Contexts:        []string{"https://example.com/contexts/employee/v1"},
+				DefaultLifetime: "P1Y",
+			},
+			wantTypes: []string{"string", "date"},
+		},
+		{
+			name:      "property types are lower-cased",
+			schema:    Schema{Properties: []Property{{Name: "name", Type: "String"}, {Name: "hired", Type: "Date-Time"}, {Name: "age", Type: "INTEGER"}}},
+			wantTypes: []string{"string", "date-time", "integer"},
+		},
+		{
+			name:    "unknown property type",
+			schema:  Schema{Properties: []Property{{Name: "name", Type: "text"}}},
+			wantErr: `property "name" has unsupported type "text"`,
+		},
+		{
+			name:    "missing property type",
+			schema:  Schema{Properties: []Property{{Name: "name"}}},
+			wantErr: "unsupported type",
+		},
+		{
+			name:    "credential type with whitespace",
+			schema:  Schema{CredentialTypes: []string{"Employee Badge"}},
+			wantErr: "credential type",
+		},
+		{
+			name:    "relative context",
+			schema:  Schema{Contexts: []string{"contexts/employee/v1"}},
+			wantErr: "not an absolute URL",
+		},
+		{
+			name:    "zero lifetime",
+			schema:  Schema{DefaultLifetime: "P0D"},
+			wantErr: "positive ISO 8601 duration",
+		},
+		{
+			name:    "lifetime without time components",
+			schema:  Schema{DefaultLifetime: "P1DT"},
+			wantErr: "positive ISO 8601 duration",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSchema(&tt.schema)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var types []string
+			for _, property := range tt.schema.Properties {
+				types = append(types, property.Type)
+			}
+			if !reflect.DeepEqual(types, tt.wantTypes) {
+				t.Errorf("Expected property types %v, got %v", tt.wantTypes, types)
+			}
+		})
+	}
+}
+
+func TestRenderJSONSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		property Property
+		want     map[string]interface{}
+	}{
+		{"string", Property{Name: "name", Type: "string"}, map[string]interface{}{"type": "string"}},
+		{"integer", Property{Name: "age", Type: "integer"}, map[string]interface{}{"type": "integer"}},
+		{"date", Property{Name: "birthDate", Type: "date"}, map[string]interface{}{"type": "string", "format": "date"}},
+		{"date-time", Property{Name: "hired", Type: "date-time"}, map[string]interface{}{"type": "string", "format": "date-time"}},
+		{"stored in mixed case", Property{Name: "hired", Type: "Date-Time"}, map[string]interface{}{"type": "string", "format": "date-time"}},
+		{"stored unknown type", Property{Name: "note", Type: "text"}, map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered := renderJSONSchema(Schema{SchemaName: "EmployeeBadge", Properties: []Property{tt.property}}, "https://schemas.example.com/v1/schemas/7")
+			subject := rendered["properties"].(map[string]interface{})["credentialSubject"].(map[string]interface{})
+			got := subject["properties"].(map[string]interface{})[tt.property.Name]
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// Required properties and the schema's credential types are required of credentials
+	rendered := renderJSONSchema(Schema{
+		SchemaName:      "EmployeeBadge",
+		Properties:      []Property{{Name: "name", Type: "string", Required: true}, {Name: "age", Type: "integer"}},
+		CredentialTypes: []string{"EmployeeBadge"},
+	}, "https://schemas.example.com/v1/schemas/7")
+	if rendered["$id"] != "https://schemas.example.com/v1/schemas/7" || rendered["title"] != "EmployeeBadge" {
+		t.Errorf("Unexpected schema id or title: %v %v", rendered["$id"], rendered["title"])
+	}
+	properties := rendered["properties"].(map[string]interface{})
+	required := properties["credentialSubject"].(map[string]interface{})["required"]
+	if !reflect.DeepEqual(required, []string{"id", "name"}) {
+		t.Errorf("Expected id and name to be required, got %v", required)
+	}
+	credentialType := properties["type"].(map[string]interface{})
+	wantType := []interface{}{map[string]interface{}{"contains": map[string]interface{}{"const": "EmployeeBadge"}}}
+	if !reflect.DeepEqual(credentialType["allOf"], wantType) {
+		t.Errorf("Expected the credential type to be required, got %v", credentialType)
+	}
+}
@@ -11,7 +11,10 @@ import (
 type Client struct {
 	DIDServiceURL      string
 	ResolverServiceURL string
-	HTTPClient         *http.Client
+	// OrganizationID is sent as the X-Organization-ID header, which listing, reading, updating
+	// and deactivating DIDs require
+	OrganizationID string
+	HTTPClient     *http.Client
 }
 
 // NewClient creates a new instance of Client with an initialized HTTP client.
@@ -31,6 +34,9 @@ func (c *Client) sendRequest(method, url string, body []byte) ([]byte, error) {
 	}
 
 	req.Header.Set("Content-Type", "application/json") // Set the content type to JSON
+	if c.OrganizationID != "" {
+		req.Header.Set("X-Organization-ID", c.OrganizationID)
+	}
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
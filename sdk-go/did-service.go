@@ -4,35 +4,55 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 )
 
 // DIDRequest represents the request payload for creating a DID
 type DIDRequest struct {
 	Type           string `json:"type"`
 	OrganizationID string `json:"organization_id"`
+	KeyType        string `json:"keyType,omitempty"` // "Ed25519" (default), "P-256", "secp256k1" or "BLS12-381-G2"
 }
 
 // Define the structure for the response when creating a DID
 type DIDResponse struct {
-	ID        string `json:"id"`
-	PublicKey []struct {
-		ID              string `json:"id"`
-		Type            string `json:"type"`
-		Controller      string `json:"controller"`
-		PublicKeyBase58 string `json:"publicKeyBase58"`
-	} `json:"publicKey"`
-	CreatedAt      string `json:"createdAt"`
-	OrganizationID string `json:"organization_id"`
+	Context            []string `json:"@context"`
+	ID                 string   `json:"id"`
+	VerificationMethod []struct {
+		ID                 string `json:"id"`
+		Type               string `json:"type"`
+		Controller         string `json:"controller"`
+		PublicKeyMultibase string `json:"publicKeyMultibase"`
+	} `json:"verificationMethod"`
+	Authentication  []string  `json:"authentication"`
+	AssertionMethod []string  `json:"assertionMethod"`
+	Controller      []string  `json:"controller,omitempty"`
+	Service         []Service `json:"service,omitempty"`
+	CreatedAt       string    `json:"createdAt"`
+	OrganizationID  string    `json:"organization_id"`
+}
+
+// Service is a service endpoint listed in a DID document, e.g. LinkedDomains or DIDCommMessaging
+type Service struct {
+	ID              string `json:"id,omitempty"` // Numbered "#service-N" by the DID service when empty
+	Type            string `json:"type"`
+	ServiceEndpoint string `json:"serviceEndpoint"`
 }
 
 // CreateDID creates a new DID and returns the DIDResponse.
 func (c *Client) CreateDID(orgID string) (DIDResponse, error) {
+	return c.CreateDIDWithKeyType(orgID, "")
+}
+
+// CreateDIDWithKeyType creates a new DID whose key is of the given type, Ed25519 when empty.
+func (c *Client) CreateDIDWithKeyType(orgID, keyType string) (DIDResponse, error) {
 	url := fmt.Sprintf("%s/v1/dids", c.DIDServiceURL)
 
 	// Construct the request payload
 	didRequest := DIDRequest{
 		Type:           "organization", // This is the type expected by the service
 		OrganizationID: orgID,          // Replace with actual organization ID if necessary
+		KeyType:        keyType,
 	}
 
 	// Serialize the request payload to JSON
@@ -56,9 +76,50 @@ func (c *Client) CreateDID(orgID string) (DIDResponse, error) {
 	return didResp, nil
 }
 
+// GetDID returns the current DID document of a DID registered with the DID service
+func (c *Client) GetDID(did string) (DIDResponse, error) {
+	url := fmt.Sprintf("%s/v1/dids/%s", c.DIDServiceURL, url.PathEscape(did))
+
+	respBody, err := c.sendRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return DIDResponse{}, err
+	}
+
+	var didResp DIDResponse
+	if err := json.Unmarshal(respBody, &didResp); err != nil {
+		return DIDResponse{}, err
+	}
+
+	return didResp, nil
+}
+
+// DIDListResponse represents a page of DID documents. NextCursor is empty on the last page.
+type DIDListResponse struct {
+	DIDs       []DIDResponse `json:"dids"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// ListDIDs returns a page of DID documents. filter holds the query parameters of GET /v1/dids,
+// e.g. organization_id, method, keyType, limit and the cursor of the previous page.
+func (c *Client) ListDIDs(filter url.Values) (DIDListResponse, error) {
+	url := fmt.Sprintf("%s/v1/dids?%s", c.DIDServiceURL, filter.Encode())
+
+	respBody, err := c.sendRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return DIDListResponse{}, err
+	}
+
+	var listResp DIDListResponse
+	if err := json.Unmarshal(respBody, &listResp); err != nil {
+		return DIDListResponse{}, err
+	}
+
+	return listResp, nil
+}
+
 // ResolveDID resolves a given DID and returns the resolved data.
 func (c *Client) ResolveDID(did string) (string, error) {
-	url := fmt.Sprintf("%s/v1/dids/resolver?did=%s", c.ResolverServiceURL, did)
+	url := fmt.Sprintf("%s/v1/dids/resolver?did=%s", c.ResolverServiceURL, url.QueryEscape(did))
 
 	respBody, err := c.sendRequest(http.MethodGet, url, nil)
 	if err != nil {
@@ -67,3 +128,86 @@ func (c *Client) ResolveDID(did string) (string, error) {
 
 	return string(respBody), nil // Return the response as a string or modify based on the expected format
 }
+
+// KeyUpdateRequest represents the request payload for adding, rotating or retiring a key
+type KeyUpdateRequest struct {
+	Action string `json:"action"`
+	KeyID  string `json:"keyId,omitempty"`
+}
+
+// UpdateDIDKeys adds ("add"), rotates ("rotate") or retires ("retire") a key of a did:web DID
+// and returns the new version of its DID document. keyID is ignored when adding a key.
+func (c *Client) UpdateDIDKeys(did, action, keyID string) (DIDResponse, error) {
+	url := fmt.Sprintf("%s/v1/dids/%s/keys", c.DIDServiceURL, url.PathEscape(did))
+
+	requestBody, err := json.Marshal(KeyUpdateRequest{Action: action, KeyID: keyID})
+	if err != nil {
+		return DIDResponse{}, err
+	}
+
+	respBody, err := c.sendRequest(http.MethodPut, url, requestBody)
+	if err != nil {
+		return DIDResponse{}, err
+	}
+
+	var didResp DIDResponse
+	if err := json.Unmarshal(respBody, &didResp); err != nil {
+		return DIDResponse{}, err
+	}
+
+	return didResp, nil
+}
+
+// DeactivateDIDResponse represents the response when deactivating a DID
+type DeactivateDIDResponse struct {
+	DID           string `json:"did"`
+	Deactivated   bool   `json:"deactivated"`
+	DeactivatedAt string `json:"deactivatedAt"`
+}
+
+// DeactivateDID permanently deactivates a DID and destroys its private keys.
+func (c *Client) DeactivateDID(did string) (DeactivateDIDResponse, error) {
+	url := fmt.Sprintf("%s/v1/dids/%s/deactivate", c.DIDServiceURL, url.PathEscape(did))
+
+	respBody, err := c.sendRequest(http.MethodPost, url, nil)
+	if err != nil {
+		return DeactivateDIDResponse{}, err
+	}
+
+	var deactivateResp DeactivateDIDResponse
+	if err := json.Unmarshal(respBody, &deactivateResp); err != nil {
+		return DeactivateDIDResponse{}, err
+	}
+
+	return deactivateResp, nil
+}
+
+// DocumentUpdateRequest represents the request payload for updating the controllers and services
+// of a DID document. Nil fields are left unchanged, empty ones remove every entry.
+type DocumentUpdateRequest struct {
+	Controller *[]string  `json:"controller,omitempty"`
+	Services   *[]Service `json:"services,omitempty"`
+}
+
+// UpdateDIDDocument replaces the controllers and/or services of a did:web DID and returns the
+// new version of its DID document
+func (c *Client) UpdateDIDDocument(did string, update DocumentUpdateRequest) (DIDResponse, error) {
+	url := fmt.Sprintf("%s/v1/dids/%s", c.DIDServiceURL, url.PathEscape(did))
+
+	requestBody, err := json.Marshal(update)
+	if err != nil {
+		return DIDResponse{}, err
+	}
+
+	respBody, err := c.sendRequest(http.MethodPatch, url, requestBody)
+	if err != nil {
+		return DIDResponse{}, err
+	}
+
+	var didResp DIDResponse
+	if err := json.Unmarshal(respBody, &didResp); err != nil {
+		return DIDResponse{}, err
+	}
+
+	return didResp, nil
+}
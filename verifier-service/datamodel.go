@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Versions of the W3C Verifiable Credentials Data Model, told apart by their base context
+const (
+	dataModelV1 = "1.1"
+	dataModelV2 = "2.0"
+
+	credentialsContextV1 = "https://www.w3.org/2018/credentials/v1"
+	credentialsContextV2 = "https://www.w3.org/ns/credentials/v2"
+)
+
+var (
+	// ErrUnsupportedDataModel is returned for credentials that are not in the 1.1 or 2.0 data
+	// model, or mix the two
+	ErrUnsupportedDataModel = errors.New("unsupported credential data model")
+	// ErrInvalidCredentialStatus is returned for credentialStatus entries the data model does not
+	// allow
+	ErrInvalidCredentialStatus = errors.New("invalid credentialStatus")
+)
+
+// CredentialStatus is the credentialStatus of a credential: one status entry, or in the 2.0 data
+// model also an array of them. It is encoded the way it was decoded, as the proof covers it.
+type CredentialStatus struct {
+	Entries []map[string]interface{}
+	isArray bool
+}
+
+// UnmarshalJSON decodes a status entry or an array of them
+func (s *CredentialStatus) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		s.isArray = true
+		return json.Unmarshal(data, &s.Entries)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return err
+	}
+	s.Entries, s.isArray = []map[string]interface{}{entry}, false
+	return nil
+}
+
+// MarshalJSON encodes the entries as they were decoded; a single entry that was not decoded as
+// an array is encoded as an object
+func (s CredentialStatus) MarshalJSON() ([]byte, error) {
+	if !s.isArray && len(s.Entries) == 1 {
+		return json.Marshal(s.Entries[0])
+	}
+	if s.Entries == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(s.Entries)
+}
+
+// credentialDataModel returns the data model version of a credential from its base context,
+// which must come first
+func credentialDataModel(vc VerifiableCredential) (string, error) {
+	if len(vc.Context) > 0 {
+		switch vc.Context[0] {
+		case credentialsContextV1:
+			return dataModelV1, nil
+		case credentialsContextV2:
+			return dataModelV2, nil
+		}
+	}
+	return "", fmt.Errorf("%w: @context %v", ErrUnsupportedDataModel, vc.Context)
+}
+
+// validityWindow returns when a credential becomes valid and when it expires: its issuanceDate and
+// expirationDate in the 1.1 data model, its validFrom and validUntil in 2.0. from is zero for 2.0
+// credentials without validFrom and until is zero for credentials that never expire.
+func validityWindow(vc VerifiableCredential) (from, until time.Time, err error) {
+	dataModel, err := credentialDataModel(vc)
+	if err != nil {
+		return from, until, err
+	}
+
+	var fromDate, untilDate string
+	switch dataModel {
+	case dataModelV1:
+		if vc.ValidFrom != "" || vc.ValidUntil != "" {
+			return from, until, fmt.Errorf("%w: validFrom and validUntil are not part of data model 1.1", ErrUnsupportedDataModel)
+		}
+		if vc.IssuanceDate == "" {
+			return from, until, errors.New("invalid issuance date")
+		}
+		fromDate, untilDate = vc.IssuanceDate, vc.ExpirationDate
+	case dataModelV2:
+		if vc.IssuanceDate != "" || vc.ExpirationDate != "" {
+			return from, until, fmt.Errorf("%w: issuanceDate and expirationDate are not part of data model 2.0", ErrUnsupportedDataModel)
+		}
+		fromDate, untilDate = vc.ValidFrom, vc.ValidUntil
+	}
+
+	if fromDate != "" {
+		if from, err = time.Parse(time.RFC3339, fromDate); err != nil {
+			return from, until, errors.New("invalid issuance date")
+		}
+	}
+	if untilDate != "" {
+		if until, err = time.Parse(time.RFC3339, untilDate); err != nil {
+			return from, until, errors.New("invalid expiration date")
+		}
+	}
+	return from, until, nil
+}
+
+// checkCredentialStatus checks the credentialStatus of a credential: in the 1.1 data model a
+// single entry with an id and a type, in 2.0 one entry or a non-empty array of entries with a
+// type. The statuses themselves are not looked up.
+func checkCredentialStatus(vc VerifiableCredential, dataModel string) error {
+	status := vc.CredentialStatus
+	if status == nil {
+		return nil
+	}
+	if dataModel == dataModelV1 && status.isArray {
+		return fmt.Errorf("%w: data model 1.1 allows a single entry", ErrInvalidCredentialStatus)
+	}
+	if len(status.Entries) == 0 {
+		return fmt.Errorf("%w: no entries", ErrInvalidCredentialStatus)
+	}
+	for i, entry := range status.Entries {
+		if statusType, ok := entry["type"].(string); !ok || statusType == "" {
+			return fmt.Errorf("%w: entry %d has no type", ErrInvalidCredentialStatus, i)
+		}
+		if id, ok := entry["id"].(string); dataModel == dataModelV1 && (!ok || id == "") {
+			return fmt.Errorf("%w: entry %d has no id", ErrInvalidCredentialStatus, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bradtumy/credential-service/dataintegrity"
+)
+
+// toDataModelV2 turns a test credential into a data model 2.0 credential
+func toDataModelV2(vc *VerifiableCredential) {
+	vc.Context = []string{credentialsContextV2}
+	vc.ValidFrom, vc.ValidUntil = vc.IssuanceDate, vc.ExpirationDate
+	vc.IssuanceDate, vc.ExpirationDate = "", ""
+}
+
+func TestVerifyCredentialV2(t *testing.T) {
+	resolver := staticResolver{}
+	privateKey, did, keyID := newTestIssuer(t, resolver)
+	sign := func(hashData []byte) []byte { return ed25519.Sign(privateKey, hashData) }
+
+	vc := signTestCredentialWith(t, dataintegrity.EdDSAJCS2022, did, keyID, sign, toDataModelV2)
+	if valid, err := VerifyCredential(vc, resolver); err != nil || !valid {
+		t.Fatalf("Expected a 2.0 credential to verify, got %v", err)
+	}
+
+	// Without validFrom the proof creation time counts as the issuance time
+	vc = signTestCredentialWith(t, dataintegrity.EdDSAJCS2022, did, keyID, sign, toDataModelV2, func(vc *VerifiableCredential) { vc.ValidFrom = "" })
+	if valid, err := VerifyCredential(vc, resolver); err != nil || !valid {
+		t.Fatalf("Expected a 2.0 credential without validFrom to verify, got %v", err)
+	}
+	created, _ := time.Parse(time.RFC3339, vc.Proof.Created)
+	earlier := deactivatedResolver{staticResolver: resolver, deactivatedAt: created.Add(-time.Hour).Format(time.RFC3339)}
+	if valid, err := VerifyCredential(vc, earlier); valid || !errors.Is(err, ErrIssuerDeactivated) {
+		t.Errorf("Expected %v, got %v", ErrIssuerDeactivated, err)
+	}
+
+	// The proof covers credentialStatus in the shape it was issued in
+	var status CredentialStatus
+	json.Unmarshal([]byte(`[{"id":"https://status.example.com/1#7","type":"BitstringStatusListEntry"}]`), &status)
+	vc = signTestCredentialWith(t, dataintegrity.EdDSAJCS2022, did, keyID, sign, toDataModelV2, func(vc *VerifiableCredential) { vc.CredentialStatus = &status })
+	encoded, _ := json.Marshal(vc)
+	var decoded VerifiableCredential
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to decode credential: %v", err)
+	}
+	if valid, err := VerifyCredential(decoded, resolver); err != nil || !valid {
+		t.Errorf("Expected a 2.0 credential with credentialStatus to verify, got %v", err)
+	}
+}
+
+func TestValidityWindowMixedDataModels(t *testing.T) {
+	for _, vc := range []VerifiableCredential{
+		{Context: []string{credentialsContextV1}, IssuanceDate: "2024-09-01T00:00:00Z", ValidUntil: "2025-09-01T00:00:00Z"},
+		{Context: []string{credentialsContextV2}, ValidFrom: "2024-09-01T00:00:00Z", ExpirationDate: "2025-09-01T00:00:00Z"},
+		{Context: []string{"https://www.w3.org/2018/credentials/examples/v1", credentialsContextV1}, IssuanceDate: "2024-09-01T00:00:00Z"},
+	} {
+		if _, _, err := validityWindow(vc); !errors.Is(err, ErrUnsupportedDataModel) {
+			t.Errorf("Expected %v for %+v, got %v", ErrUnsupportedDataModel, vc, err)
+		}
+	}
+
+	from, until, err := validityWindow(VerifiableCredential{Context: []string{credentialsContextV2}, ValidUntil: "2025-09-01T00:00:00Z"})
+	if err != nil || !from.IsZero() || until.IsZero() {
+		t.Errorf("Unexpected window %v - %v: %v", from, until, err)
+	}
+}
+
+func TestCheckCredentialStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		dataModel string
+		status    string
+		valid     bool
+	}{
+		{name: "1.1 entry", dataModel: dataModelV1, status: `{"id":"https://status.example.com/1#7","type":"StatusList2021Entry"}`, valid: true},
+		{name: "1.1 entry without id", dataModel: dataModelV1, status: `{"type":"StatusList2021Entry"}`},
+		{name: "1.1 array", dataModel: dataModelV1, status: `[{"id":"https://status.example.com/1#7","type":"StatusList2021Entry"}]`},
+		{name: "2.0 entry without id", dataModel: dataModelV2, status: `{"type":"BitstringStatusListEntry"}`, valid: true},
+		{name: "2.0 array", dataModel: dataModelV2, status: `[{"type":"BitstringStatusListEntry"},{"type":"BitstringStatusListEntry"}]`, valid: true},
+		{name: "2.0 empty array", dataModel: dataModelV2, status: `[]`},
+		{name: "2.0 entry without type", dataModel: dataModelV2, status: `[{"id":"https://status.example.com/1#7"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var status CredentialStatus
+			if err := json.Unmarshal([]byte(tt.status), &status); err != nil {
+				t.Fatalf("Failed to decode credentialStatus: %v", err)
+			}
+			err := checkCredentialStatus(VerifiableCredential{CredentialStatus: &status}, tt.dataModel)
+			if tt.valid && err != nil {
+				t.Errorf("Expected a valid credentialStatus, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidCredentialStatus) {
+				t.Errorf("Expected %v, got %v", ErrInvalidCredentialStatus, err)
+			}
+
+			encoded, _ := json.Marshal(status)
+			if string(encoded) != tt.status {
+				t.Errorf("Expected credentialStatus to encode as %s, got %s", tt.status, encoded)
+			}
+		})
+	}
+}
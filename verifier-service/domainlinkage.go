@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DIF Well-Known DID Configuration (https://identity.foundation/.well-known/resources/did-configuration/)
+const (
+	linkedDomainsServiceType    = "LinkedDomains"
+	domainLinkageCredentialType = "DomainLinkageCredential"
+	didConfigurationPath        = "/.well-known/did-configuration.json"
+
+	// maxDIDConfigurationSize bounds the did-configuration.json resources we read
+	maxDIDConfigurationSize = 1 << 20
+)
+
+// Reasons a domain linkage can fail verification
+var (
+	ErrNoLinkedDomains      = errors.New("issuer DID document lists no LinkedDomains service")
+	ErrDomainNotLinked      = errors.New("domain does not link back to the DID")
+	ErrInvalidDomainLinkage = errors.New("invalid domain linkage credential")
+)
+
+// DIDConfiguration is the did-configuration.json resource of an origin
+type DIDConfiguration struct {
+	Context    interface{}       `json:"@context"`
+	LinkedDIDs []json.RawMessage `json:"linked_dids"`
+}
+
+// DIDConfigurationFetcher fetches the did-configuration.json of an origin
+type DIDConfigurationFetcher interface {
+	Fetch(origin string) (DIDConfiguration, error)
+}
+
+// HTTPDIDConfigurationFetcher fetches did-configuration.json from <origin>/.well-known over HTTPS
+type HTTPDIDConfigurationFetcher struct {
+	HTTPClient *http.Client
+}
+
+// NewHTTPDIDConfigurationFetcher creates a fetcher that does not follow redirects, so the
+// configuration is always read from the origin itself
+func NewHTTPDIDConfigurationFetcher() *HTTPDIDConfigurationFetcher {
+	return &HTTPDIDConfigurationFetcher{
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Fetch reads and decodes the did-configuration.json of an origin
+func (f *HTTPDIDConfigurationFetcher) Fetch(origin string) (DIDConfiguration, error) {
+	resp, err := f.HTTPClient.Get(origin + didConfigurationPath)
+	if err != nil {
+		return DIDConfiguration{}, fmt.Errorf("failed to fetch DID configuration: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return DIDConfiguration{}, fmt.Errorf("failed to fetch DID configuration: %s", resp.Status)
+	}
+
+	var configuration DIDConfiguration
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDIDConfigurationSize)).Decode(&configuration); err != nil {
+		return DIDConfiguration{}, fmt.Errorf("failed to decode DID configuration: %w", err)
+	}
+	return configuration, nil
+}
+
+// DomainLinkageResult is the outcome of checking one linked domain of a DID
+type DomainLinkageResult struct {
+	Origin   string `json:"origin"`
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// normalizeOrigin returns the scheme, host and port of an https origin, lower-cased
+func normalizeOrigin(origin string) (string, error) {
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" || parsed.User != nil ||
+		(parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", fmt.Errorf("%q is not an https origin", origin)
+	}
+	return "https://" + strings.ToLower(parsed.Host), nil
+}
+
+// linkedDomainOrigins collects the origins of the LinkedDomains services of a DID document. A
+// service endpoint is an origin, a list of origins or an object with an "origins" list.
+func linkedDomainOrigins(didDocument map[string]interface{}) []string {
+	var origins []string
+	services, _ := didDocument["service"].([]interface{})
+	for _, entry := range services {
+		service, ok := entry.(map[string]interface{})
+		if !ok || !hasType(service["type"], linkedDomainsServiceType) {
+			continue
+		}
+
+		var endpoints []interface{}
+		switch endpoint := service["serviceEndpoint"].(type) {
+		case string:
+			endpoints = []interface{}{endpoint}
+		case []interface{}:
+			endpoints = endpoint
+		case map[string]interface{}:
+			endpoints, _ = endpoint["origins"].([]interface{})
+		}
+		for _, endpoint := range endpoints {
+			if origin, ok := endpoint.(string); ok {
+				origins = append(origins, origin)
+			}
+		}
+	}
+	return origins
+}
+
+// hasType reports whether a JSON-LD type, a string or a list of strings, includes typeName
+func hasType(value interface{}, typeName string) bool {
+	switch types := value.(type) {
+	case string:
+		return types == typeName
+	case []interface{}:
+		for _, t := range types {
+			if t == typeName {
+				return true
+			}
+		}
+	case []string:
+		for _, t := range types {
+			if t == typeName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifyDomainLinkageCredential checks that a Domain Linkage Credential in JSON-LD form links the
+// DID to the origin: the DID is its issuer and subject, it is currently valid and its proof
+// verifies against the DID's keys
+func verifyDomainLinkageCredential(raw json.RawMessage, did, origin string, resolver DIDResolver) error {
+	// The proof is verified over the credential as received, not as re-encoded by vc
+	vc, document, err := decodeCredential(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDomainLinkage, err)
+	}
+
+	if !hasType(vc.Type, domainLinkageCredentialType) {
+		return fmt.Errorf("%w: type %v", ErrInvalidDomainLinkage, vc.Type)
+	}
+	if vc.Issuer != did || vc.CredentialSubject["id"] != did {
+		return fmt.Errorf("%w: issuer and subject must be %s", ErrInvalidDomainLinkage, did)
+	}
+	subjectOrigin, _ := vc.CredentialSubject["origin"].(string)
+	if normalized, err := normalizeOrigin(subjectOrigin); err != nil || normalized != origin {
+		return fmt.Errorf("%w: credential is for origin %q", ErrInvalidDomainLinkage, subjectOrigin)
+	}
+
+	// Domain Linkage Credentials must say when they become valid and expire, in either data model
+	now := time.Now()
+	issuanceDate, expirationDate, err := validityWindow(vc)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDomainLinkage, err)
+	}
+	if issuanceDate.IsZero() || issuanceDate.After(now) {
+		return fmt.Errorf("%w: missing or future issuance date", ErrInvalidDomainLinkage)
+	}
+	if expirationDate.IsZero() || !expirationDate.After(now) {
+		return fmt.Errorf("%w: missing or past expiration date", ErrInvalidDomainLinkage)
+	}
+
+	return verifyDocumentProof(vc, document, resolver)
+}
+
+// verifyLinkedOrigin checks that the origin's did-configuration.json holds a valid Domain Linkage
+// Credential for the DID
+func verifyLinkedOrigin(did, origin string, resolver DIDResolver, fetcher DIDConfigurationFetcher) error {
+	configuration, err := fetcher.Fetch(origin)
+	if err != nil {
+		return err
+	}
+
+	err = fmt.Errorf("%w: no credential for %s in %s%s", ErrDomainNotLinked, did, origin, didConfigurationPath)
+	for _, raw := range configuration.LinkedDIDs {
+		// JWT encoded credentials are strings, only the JSON-LD form is supported
+		var subject struct {
+			CredentialSubject struct {
+				ID string `json:"id"`
+			} `json:"credentialSubject"`
+		}
+		if json.Unmarshal(raw, &subject) != nil || subject.CredentialSubject.ID != did {
+			continue
+		}
+		if err = verifyDomainLinkageCredential(raw, did, origin, resolver); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// VerifyDomainLinkage checks every domain the DID claims through its LinkedDomains services
+// against the domain's did-configuration.json. It fails if the DID cannot be resolved or claims no
+// domains; the result of each domain is reported separately.
+func VerifyDomainLinkage(did string, resolver DIDResolver, fetcher DIDConfigurationFetcher) ([]DomainLinkageResult, error) {
+	didDocument, _, err := resolver.Resolve(did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve DID: %w", err)
+	}
+
+	origins := linkedDomainOrigins(didDocument)
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoLinkedDomains, did)
+	}
+
+	results := make([]DomainLinkageResult, 0, len(origins))
+	for _, claimed := range origins {
+		result := DomainLinkageResult{Origin: claimed}
+		origin, err := normalizeOrigin(claimed)
+		if err == nil {
+			err = verifyLinkedOrigin(did, origin, resolver, fetcher)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Verified = true
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradtumy/credential-service/dataintegrity"
+	"github.com/mr-tron/base58"
+)
+
+// staticFetcher serves did-configuration.json resources from memory
+type staticFetcher map[string]DIDConfiguration
+
+func (s staticFetcher) Fetch(origin string) (DIDConfiguration, error) {
+	configuration, ok := s[origin]
+	if !ok {
+		return DIDConfiguration{}, errors.New("404 Not Found")
+	}
+	return configuration, nil
+}
+
+// signTestDomainLinkage builds a Domain Linkage Credential the way did-service does, without an id
+func signTestDomainLinkage(t *testing.T, privateKey ed25519.PrivateKey, did, keyID, origin string, expiresAt time.Time) json.RawMessage {
+	t.Helper()
+
+	now := time.Now().UTC()
+	context := []string{"https://www.w3.org/2018/credentials/v1", "https://identity.foundation/.well-known/did-configuration/v1"}
+	credential := map[string]interface{}{
+		"@context":          context,
+		"issuer":            did,
+		"issuanceDate":      now.Format(time.RFC3339),
+		"expirationDate":    expiresAt.UTC().Format(time.RFC3339),
+		"type":              []string{"VerifiableCredential", domainLinkageCredentialType},
+		"credentialSubject": map[string]interface{}{"id": did, "origin": origin},
+	}
+	proof := Proof{
+		Type:               proofTypeDataIntegrity,
+		Cryptosuite:        dataintegrity.EdDSAJCS2022,
+		Created:            now.Format(time.RFC3339),
+		ProofPurpose:       proofPurposeAssertion,
+		VerificationMethod: keyID,
+	}
+	hashData, err := documentProofHashData(credential, context, proof)
+	if err != nil {
+		t.Fatalf("Failed to build proof hash data: %v", err)
+	}
+	proof.ProofValue = "z" + base58.Encode(ed25519.Sign(privateKey, hashData))
+	credential["proof"] = proof
+
+	raw, err := json.Marshal(credential)
+	if err != nil {
+		t.Fatalf("Failed to marshal credential: %v", err)
+	}
+	return raw
+}
+
+// newLinkedTestIssuer creates an issuer whose DID document links to the given origins
+func newLinkedTestIssuer(t *testing.T, resolver staticResolver, origins ...string) (ed25519.PrivateKey, string, string) {
+	privateKey, did, keyID := newTestIssuer(t, resolver)
+	endpoints := []interface{}{}
+	for _, origin := range origins {
+		endpoints = append(endpoints, origin)
+	}
+	resolver[did]["service"] = []interface{}{
+		map[string]interface{}{"id": did + "#linked-domain", "type": linkedDomainsServiceType, "serviceEndpoint": map[string]interface{}{"origins": endpoints}},
+	}
+	return privateKey, did, keyID
+}
+
+func TestVerifyDomainLinkage(t *testing.T) {
+	resolver := staticResolver{}
+	privateKey, did, keyID := newLinkedTestIssuer(t, resolver, "https://issuer.example.com", "https://other.example.com/")
+	otherKey, otherDID, otherKeyID := newTestIssuer(t, resolver)
+
+	fetcher := staticFetcher{
+		"https://issuer.example.com": {LinkedDIDs: []json.RawMessage{
+			json.RawMessage(`"eyJhbGciOiJFZERTQSJ9.e30.c2lnbmF0dXJl"`),
+			signTestDomainLinkage(t, otherKey, otherDID, otherKeyID, "https://issuer.example.com", time.Now().Add(time.Hour)),
+			signTestDomainLinkage(t, privateKey, did, keyID, "https://issuer.example.com", time.Now().Add(time.Hour)),
+		}},
+		// Linked to another origin than the one serving it
+		"https://other.example.com": {LinkedDIDs: []json.RawMessage{
+			signTestDomainLinkage(t, privateKey, did, keyID, "https://issuer.example.com", time.Now().Add(time.Hour)),
+		}},
+	}
+
+	results, err := VerifyDomainLinkage(did, resolver, fetcher)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != 2 || !results[0].Verified || results[1].Verified {
+		t.Fatalf("Expected only issuer.example.com to verify, got %+v", results)
+	}
+	if !strings.Contains(results[1].Error, ErrInvalidDomainLinkage.Error()) {
+		t.Errorf("Expected the origin mismatch to be reported, got %s", results[1].Error)
+	}
+
+	if _, err := VerifyDomainLinkage(otherDID, resolver, fetcher); !errors.Is(err, ErrNoLinkedDomains) {
+		t.Errorf("Expected a DID without LinkedDomains service to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyDomainLinkageFailureReasons(t *testing.T) {
+	resolver := staticResolver{}
+	privateKey, did, keyID := newLinkedTestIssuer(t, resolver, "https://issuer.example.com")
+	otherKey, _, _ := newTestIssuer(t, resolver)
+	origin := "https://issuer.example.com"
+
+	valid := signTestDomainLinkage(t, privateKey, did, keyID, origin, time.Now().Add(time.Hour))
+	// Pushing the expiration date out breaks the signature
+	tampered := json.RawMessage(strings.Replace(string(valid), `"expirationDate":"20`, `"expirationDate":"21`, 1))
+	tests := map[string]struct {
+		credential json.RawMessage
+		expected   error
+	}{
+		"expired":        {signTestDomainLinkage(t, privateKey, did, keyID, origin, time.Now().Add(-time.Hour)), ErrInvalidDomainLinkage},
+		"wrong key":      {signTestDomainLinkage(t, otherKey, did, keyID, origin, time.Now().Add(time.Hour)), ErrInvalidSignature},
+		"tampered":       {tampered, ErrInvalidSignature},
+		"another origin": {signTestDomainLinkage(t, privateKey, did, keyID, "https://evil.example.com", time.Now().Add(time.Hour)), ErrInvalidDomainLinkage},
+	}
+	for name, tt := range tests {
+		err := verifyLinkedOrigin(did, origin, resolver, staticFetcher{origin: {LinkedDIDs: []json.RawMessage{tt.credential}}})
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", name, tt.expected, err)
+		}
+	}
+
+	if err := verifyLinkedOrigin(did, origin, resolver, staticFetcher{origin: {}}); !errors.Is(err, ErrDomainNotLinked) {
+		t.Errorf("Expected an empty configuration to be reported, got %v", err)
+	}
+}
+
+func TestHTTPDIDConfigurationFetcher(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case didConfigurationPath:
+			w.Write([]byte(`{"@context":"https://identity.foundation/.well-known/did-configuration/v1","linked_dids":[{"issuer":"did:example:123"}]}`))
+		default:
+			http.Redirect(w, r, didConfigurationPath, http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPDIDConfigurationFetcher()
+	fetcher.HTTPClient.Transport = server.Client().Transport
+
+	configuration, err := fetcher.Fetch(server.URL)
+	if err != nil || len(configuration.LinkedDIDs) != 1 {
+		t.Fatalf("Expected one linked DID, got %+v (%v)", configuration, err)
+	}
+	if _, err := fetcher.Fetch(server.URL + "/redirect"); err == nil {
+		t.Error("Expected redirects not to be followed")
+	}
+}
@@ -1,5 +1,18 @@
 module verifier-service
 
-go 1.20
+go 1.21
 
-require github.com/gorilla/mux v1.8.1
+require (
+	github.com/bradtumy/credential-service/dataintegrity v0.0.0-00010101000000-000000000000
+	github.com/cloudflare/circl v1.3.8
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0
+	github.com/gorilla/mux v1.8.1
+	github.com/mr-tron/base58 v1.2.0
+)
+
+require (
+	golang.org/x/crypto v0.11.1-0.20230711161743-2e82bdd1719d // indirect
+	golang.org/x/sys v0.10.0 // indirect
+)
+
+replace github.com/bradtumy/credential-service/dataintegrity => ../dataintegrity
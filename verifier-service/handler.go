@@ -3,23 +3,29 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 )
 
 // Handler for verifying the credential presentation
 func VerifyCredentialHandler(w http.ResponseWriter, r *http.Request) {
-	var presentation VerifiableCredential
-
-	// Decode the incoming JSON credential presentation
-	err := json.NewDecoder(r.Body).Decode(&presentation)
-	if err != nil {
+	// Keep the credential as received: its proof covers every claim, including those
+	// VerifiableCredential does not model
+	raw, err := io.ReadAll(r.Body)
+	if err != nil || !json.Valid(raw) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
 
 	// Verify the credential
-	isValid, err := VerifyCredential(presentation)
-	if err != nil || !isValid {
+	isValid, err := VerifyCredentialJSON(raw, didResolver)
+	if err != nil {
+		log.Printf("Credential verification failed: %v", err)
+		http.Error(w, fmt.Sprintf("Credential verification failed: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !isValid {
 		http.Error(w, "Credential verification failed", http.StatusBadRequest)
 		return
 	}
@@ -28,3 +34,40 @@ func VerifyCredentialHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintln(w, "Credential verified successfully")
 }
+
+// DomainLinkageResponse reports whether a credential's issuer is linked to its domains
+type DomainLinkageResponse struct {
+	Issuer        string                `json:"issuer"`
+	Verified      bool                  `json:"verified"` // At least one linked domain verified
+	LinkedDomains []DomainLinkageResult `json:"linkedDomains,omitempty"`
+	Error         string                `json:"error,omitempty"`
+}
+
+// VerifyDomainLinkageHandler checks the Linked Domains of the issuer of the posted credential.
+// The credential itself is not verified, see VerifyCredentialHandler.
+func VerifyDomainLinkageHandler(w http.ResponseWriter, r *http.Request) {
+	var credential VerifiableCredential
+	if err := json.NewDecoder(r.Body).Decode(&credential); err != nil || credential.Issuer == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	response := DomainLinkageResponse{Issuer: credential.Issuer}
+	results, err := VerifyDomainLinkage(credential.Issuer, didResolver, didConfigurationFetcher)
+	if err != nil {
+		response.Error = err.Error()
+	}
+	response.LinkedDomains = results
+	for _, result := range results {
+		response.Verified = response.Verified || result.Verified
+	}
+
+	status := http.StatusOK
+	if !response.Verified {
+		log.Printf("Domain linkage verification failed for %s: %+v", credential.Issuer, response)
+		status = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
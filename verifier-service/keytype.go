@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/circl/sign/bls"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/mr-tron/base58"
+)
+
+// Key types the verifier can check signatures of
+const (
+	keyTypeEd25519    = "Ed25519"
+	keyTypeP256       = "P-256"
+	keyTypeSecp256k1  = "secp256k1"
+	keyTypeBLS12381G2 = "BLS12-381-G2"
+)
+
+// multicodecKeyTypes describes each supported multicodec public key: its varint encoded prefix and
+// the length of the key that follows. P-256 and secp256k1 keys are compressed points.
+var multicodecKeyTypes = []struct {
+	keyType string
+	prefix  []byte
+	length  int
+}{
+	{keyType: keyTypeEd25519, prefix: []byte{0xed, 0x01}, length: ed25519.PublicKeySize}, // ed25519-pub (0xed)
+	{keyType: keyTypeP256, prefix: []byte{0x80, 0x24}, length: 33},                       // p256-pub (0x1200)
+	{keyType: keyTypeSecp256k1, prefix: []byte{0xe7, 0x01}, length: 33},                  // secp256k1-pub (0xe7)
+	{keyType: keyTypeBLS12381G2, prefix: []byte{0xeb, 0x01}, length: 96},                 // bls12_381-g2-pub (0xeb)
+}
+
+// verificationMethodKey decodes the public key of a verification method and returns its key type.
+// Keys without a multicodec prefix, as in publicKeyBase58 values, are taken to be Ed25519 keys.
+func verificationMethodKey(method map[string]interface{}) (string, []byte, error) {
+	var keyBytes []byte
+
+	if multibaseKey, ok := method["publicKeyMultibase"].(string); ok {
+		if len(multibaseKey) < 2 || multibaseKey[0] != multibaseBase58BTC {
+			return "", nil, fmt.Errorf("%w: publicKeyMultibase is not base58btc encoded", ErrInvalidPublicKey)
+		}
+		decoded, err := base58.Decode(multibaseKey[1:])
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+		}
+		// Multikey and Ed25519VerificationKey2020 values carry a multicodec prefix
+		for _, codec := range multicodecKeyTypes {
+			if len(decoded) == len(codec.prefix)+codec.length && bytes.HasPrefix(decoded, codec.prefix) {
+				return codec.keyType, decoded[len(codec.prefix):], nil
+			}
+		}
+		keyBytes = decoded
+	} else if base58Key, ok := method["publicKeyBase58"].(string); ok {
+		decoded, err := base58.Decode(base58Key)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+		}
+		keyBytes = decoded
+	}
+
+	if len(keyBytes) != ed25519.PublicKeySize {
+		return "", nil, ErrInvalidPublicKey
+	}
+
+	return keyTypeEd25519, keyBytes, nil
+}
+
+// verifySignature checks a signature over a proof's hash data. ECDSA signatures are over the
+// SHA-256 digest of the hash data and encoded as the 64 byte concatenation r || s.
+func verifySignature(keyType string, publicKey, data, signature []byte) error {
+	valid := false
+
+	switch keyType {
+	case keyTypeEd25519:
+		valid = ed25519.Verify(publicKey, data, signature)
+
+	case keyTypeP256:
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), publicKey)
+		if x == nil {
+			return fmt.Errorf("%w: invalid P-256 point", ErrInvalidPublicKey)
+		}
+		if len(signature) != 64 {
+			return ErrInvalidSignature
+		}
+		digest := sha256.Sum256(data)
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		valid = ecdsa.Verify(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, digest[:], r, s)
+
+	case keyTypeSecp256k1:
+		k1PublicKey, err := secp256k1.ParsePubKey(publicKey)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+		}
+		if len(signature) != 64 {
+			return ErrInvalidSignature
+		}
+		var r, s secp256k1.ModNScalar
+		if r.SetByteSlice(signature[:32]) || s.SetByteSlice(signature[32:]) {
+			return ErrInvalidSignature
+		}
+		digest := sha256.Sum256(data)
+		valid = secp256k1ecdsa.NewSignature(&r, &s).Verify(digest[:], k1PublicKey)
+
+	case keyTypeBLS12381G2:
+		var blsPublicKey bls.PublicKey[bls.KeyG2SigG1]
+		if err := blsPublicKey.UnmarshalBinary(publicKey); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+		}
+		valid = bls.Verify(&blsPublicKey, data, signature)
+	}
+
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
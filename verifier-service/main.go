@@ -4,9 +4,20 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 )
 
+// didResolver resolves issuer DIDs when verifying credential signatures
+var didResolver DIDResolver
+
+// didConfigurationFetcher fetches the did-configuration.json of issuers' linked domains
+var didConfigurationFetcher DIDConfigurationFetcher
+
 func main() {
+	// Resolve issuer DIDs through resolver-service
+	didResolver = NewHTTPDIDResolver(os.Getenv("RESOLVER_SERVICE_URL"))
+	didConfigurationFetcher = NewHTTPDIDConfigurationFetcher()
+
 	// Initialize routes for verifier service
 	routes := InitializeRoutes()
 
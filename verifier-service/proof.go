@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bradtumy/credential-service/dataintegrity"
+	"github.com/mr-tron/base58"
+)
+
+// Data Integrity proof parameters accepted by the verifier
+const (
+	proofTypeDataIntegrity = dataintegrity.ProofType
+	proofPurposeAssertion  = "assertionMethod"
+
+	// multibaseBase58BTC is the multibase prefix for base58btc encoded values
+	multibaseBase58BTC = 'z'
+)
+
+// Reasons a credential's signature can fail verification
+var (
+	ErrUnsupportedProof            = errors.New("unsupported proof type")
+	ErrUnknownVerificationMethod   = errors.New("verification method not found in issuer DID document")
+	ErrVerificationMethodNotIssuer = errors.New("verification method does not belong to the issuer")
+	ErrInvalidPublicKey            = errors.New("verification method has no usable public key")
+	ErrInvalidSignature            = errors.New("signature does not match")
+	ErrIssuerDeactivated           = errors.New("issuer DID was deactivated before the credential was issued")
+)
+
+// documentProofHashData builds the JCS cryptosuites' signing input of a credential from its
+// unsecured document as it was received, see dataintegrity.HashData. The proof configuration
+// shares the document's @context.
+func documentProofHashData(document map[string]interface{}, context interface{}, proof Proof) ([]byte, error) {
+	return dataintegrity.HashData(dataintegrity.ProofConfig{
+		Context:            context,
+		Type:               proof.Type,
+		Cryptosuite:        proof.Cryptosuite,
+		Created:            proof.Created,
+		ProofPurpose:       proof.ProofPurpose,
+		VerificationMethod: proof.VerificationMethod,
+	}, document)
+}
+
+// verifyDocumentProof resolves the proof's verification method and checks the signature over the
+// credential's unsecured document with the cryptosuite of the method's key type. The document is
+// the credential as received, so claims vc does not model are covered too.
+func verifyDocumentProof(vc VerifiableCredential, document map[string]interface{}, resolver DIDResolver) error {
+	proof := vc.Proof
+	if proof.Type != proofTypeDataIntegrity || !dataintegrity.SupportedCryptosuite(proof.Cryptosuite) {
+		return fmt.Errorf("%w: %q with cryptosuite %q", ErrUnsupportedProof, proof.Type, proof.Cryptosuite)
+	}
+	if proof.ProofPurpose != proofPurposeAssertion {
+		return fmt.Errorf("%w: proof purpose %q", ErrUnsupportedProof, proof.ProofPurpose)
+	}
+
+	// The verification method must be a key of the issuer's own DID
+	controllerDID, _, _ := strings.Cut(proof.VerificationMethod, "#")
+	if controllerDID != vc.Issuer {
+		return fmt.Errorf("%w: %s", ErrVerificationMethodNotIssuer, proof.VerificationMethod)
+	}
+
+	didDocument, metadata, err := resolver.Resolve(vc.Issuer)
+	if err != nil {
+		if errors.Is(err, ErrDIDNotFound) {
+			return fmt.Errorf("%w: issuer %s could not be resolved", ErrUnknownVerificationMethod, vc.Issuer)
+		}
+		return fmt.Errorf("failed to resolve issuer DID: %w", err)
+	}
+	if err := checkIssuerActive(vc, metadata); err != nil {
+		return err
+	}
+
+	method, err := findVerificationMethod(didDocument, proof.VerificationMethod)
+	if err != nil {
+		return err
+	}
+	if controller, ok := method["controller"].(string); ok && controller != vc.Issuer {
+		return fmt.Errorf("%w: controlled by %s", ErrVerificationMethodNotIssuer, controller)
+	}
+
+	keyType, publicKey, err := verificationMethodKey(method)
+	if err != nil {
+		return err
+	}
+	if cryptosuite, _ := dataintegrity.Cryptosuite(keyType); cryptosuite != proof.Cryptosuite {
+		return fmt.Errorf("%w: cryptosuite %q cannot be used with %s keys", ErrUnsupportedProof, proof.Cryptosuite, keyType)
+	}
+
+	if len(proof.ProofValue) < 2 || proof.ProofValue[0] != multibaseBase58BTC {
+		return fmt.Errorf("%w: proof value is not base58btc multibase encoded", ErrInvalidSignature)
+	}
+	signature, err := base58.Decode(proof.ProofValue[1:])
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	hashData, err := documentProofHashData(document, document["@context"], proof)
+	if err != nil {
+		return err
+	}
+	return verifySignature(keyType, publicKey, hashData, signature)
+}
+
+// checkIssuerActive rejects credentials whose issuer DID was deactivated before their issuance
+// date, or for 2.0 credentials without validFrom the creation of their proof. Credentials issued
+// while the DID was active stay valid. Without a known deactivation time every credential of a
+// deactivated issuer is rejected.
+func checkIssuerActive(vc VerifiableCredential, metadata DIDDocumentMetadata) error {
+	if !metadata.Deactivated {
+		return nil
+	}
+
+	deactivatedAt, err := time.Parse(time.RFC3339, metadata.Updated)
+	if err != nil {
+		return fmt.Errorf("%w: deactivation time of %s is unknown", ErrIssuerDeactivated, vc.Issuer)
+	}
+	issuanceDate, _, err := validityWindow(vc)
+	if err == nil && issuanceDate.IsZero() {
+		issuanceDate, err = time.Parse(time.RFC3339, vc.Proof.Created)
+	}
+	if err != nil || !issuanceDate.Before(deactivatedAt) {
+		return fmt.Errorf("%w: %s deactivated at %s", ErrIssuerDeactivated, vc.Issuer, metadata.Updated)
+	}
+
+	return nil
+}
+
+// findVerificationMethod looks up a verification method by its absolute or relative ID
+func findVerificationMethod(didDocument map[string]interface{}, methodID string) (map[string]interface{}, error) {
+	did, _ := didDocument["id"].(string)
+
+	// Older documents list their keys under "publicKey" rather than "verificationMethod"
+	for _, field := range []string{"verificationMethod", "publicKey"} {
+		entries, _ := didDocument[field].([]interface{})
+		for _, entry := range entries {
+			method, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := method["id"].(string)
+			if strings.HasPrefix(id, "#") {
+				id = did + id
+			}
+			if id == methodID {
+				return method, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownVerificationMethod, methodID)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrDIDNotFound is returned when the resolver does not know the requested DID
+var ErrDIDNotFound = errors.New("DID not found")
+
+// DIDResolver resolves a DID to its DID document and document metadata
+type DIDResolver interface {
+	Resolve(did string) (map[string]interface{}, DIDDocumentMetadata, error)
+}
+
+// DIDDocumentMetadata is the document metadata of a DID Resolution Result. For a deactivated
+// DID, Updated is the time of deactivation.
+type DIDDocumentMetadata struct {
+	Created     string `json:"created,omitempty"`
+	Updated     string `json:"updated,omitempty"`
+	VersionID   string `json:"versionId,omitempty"`
+	Deactivated bool   `json:"deactivated,omitempty"`
+}
+
+// DIDResolutionResult is the DID Resolution Result returned by resolver-service
+type DIDResolutionResult struct {
+	DIDDocument           map[string]interface{} `json:"didDocument"`
+	DIDResolutionMetadata struct {
+		Error string `json:"error"`
+	} `json:"didResolutionMetadata"`
+	DIDDocumentMetadata DIDDocumentMetadata `json:"didDocumentMetadata"`
+}
+
+// HTTPDIDResolver resolves DIDs through the resolver-service REST API
+type HTTPDIDResolver struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewHTTPDIDResolver creates a resolver client for the given resolver-service base URL
+func NewHTTPDIDResolver(baseURL string) *HTTPDIDResolver {
+	if baseURL == "" {
+		baseURL = "http://resolver-service:8080"
+	}
+	return &HTTPDIDResolver{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Resolve fetches the DID document and its metadata for the given DID from resolver-service
+func (r *HTTPDIDResolver) Resolve(did string) (map[string]interface{}, DIDDocumentMetadata, error) {
+	resolverURL := fmt.Sprintf("%s/v1/dids/resolver?did=%s", r.BaseURL, url.QueryEscape(did))
+	resp, err := r.HTTPClient.Get(resolverURL)
+	if err != nil {
+		return nil, DIDDocumentMetadata{}, fmt.Errorf("failed to fetch DID document from resolver: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, DIDDocumentMetadata{}, ErrDIDNotFound
+	}
+	// Deactivated DIDs resolve with 410 Gone and deactivated document metadata
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusGone {
+		return nil, DIDDocumentMetadata{}, fmt.Errorf("received non-OK response from resolver: %s", resp.Status)
+	}
+
+	var resolution DIDResolutionResult
+	if err := json.NewDecoder(resp.Body).Decode(&resolution); err != nil {
+		return nil, DIDDocumentMetadata{}, fmt.Errorf("failed to decode DID resolution result: %w", err)
+	}
+	if resolution.DIDResolutionMetadata.Error != "" {
+		return nil, DIDDocumentMetadata{}, fmt.Errorf("resolver returned error %q", resolution.DIDResolutionMetadata.Error)
+	}
+	if resolution.DIDDocument == nil {
+		return nil, DIDDocumentMetadata{}, fmt.Errorf("resolver returned no DID document for %s", did)
+	}
+
+	return resolution.DIDDocument, resolution.DIDDocumentMetadata, nil
+}
@@ -25,6 +25,7 @@ func InitializeRoutes() *mux.Router {
 	// Version 1 routes
 	v1 := r.PathPrefix("/v1").Subrouter()
 	v1.Handle("/verifier/verify", LoggingMiddleware(http.HandlerFunc(VerifyCredentialHandler))).Methods("POST")
+	v1.Handle("/verifier/domain-linkage", LoggingMiddleware(http.HandlerFunc(VerifyDomainLinkageHandler))).Methods("POST")
 	v1.Handle("/verifier/health", LoggingMiddleware(http.HandlerFunc(HealthCheckHandler))).Methods("GET")
 
 	return r
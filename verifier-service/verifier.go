@@ -1,58 +1,165 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 )
 
 // VerifiableCredential represents the structure of the verifiable credential
 type VerifiableCredential struct {
-	Context           []string          `json:"@context"`
-	Type              []string          `json:"type"`
-	ID                string            `json:"id"`
-	Issuer            string            `json:"issuer"`
-	IssuanceDate      string            `json:"issuanceDate"`
-	ExpirationDate    string            `json:"expirationDate"`
-	CredentialSubject map[string]string `json:"credentialSubject"`
-	Proof             Proof             `json:"proof,omitempty"`
+	Context           []string               `json:"@context"`
+	Type              []string               `json:"type"`
+	ID                string                 `json:"id"`
+	Issuer            string                 `json:"issuer"`
+	IssuanceDate      string                 `json:"issuanceDate,omitempty"`   // Data model 1.1
+	ExpirationDate    string                 `json:"expirationDate,omitempty"` // Data model 1.1
+	ValidFrom         string                 `json:"validFrom,omitempty"`      // Data model 2.0
+	ValidUntil        string                 `json:"validUntil,omitempty"`     // Data model 2.0
+	CredentialSubject map[string]interface{} `json:"credentialSubject"`
+	CredentialSchema  *CredentialSchema      `json:"credentialSchema,omitempty"`
+	CredentialStatus  *CredentialStatus      `json:"credentialStatus,omitempty"`
+	Proof             Proof                  `json:"proof,omitempty"`
+}
+
+// UnmarshalJSON decodes a credential whose issuer is a DID or an object with the DID as its id
+func (vc *VerifiableCredential) UnmarshalJSON(data []byte) error {
+	type credential VerifiableCredential
+	var decoded struct {
+		credential
+		Issuer json.RawMessage `json:"issuer"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*vc = VerifiableCredential(decoded.credential)
+
+	if len(decoded.Issuer) == 0 || bytes.Equal(decoded.Issuer, []byte("null")) {
+		return nil
+	}
+	if err := json.Unmarshal(decoded.Issuer, &vc.Issuer); err == nil {
+		return nil
+	}
+	var issuer struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(decoded.Issuer, &issuer); err != nil {
+		return errors.New("issuer must be a DID or an object with an id")
+	}
+	vc.Issuer = issuer.ID
+	return nil
+}
+
+// decodeCredential decodes a credential as received: as a VerifiableCredential for its checks,
+// and as the unsecured document its proof signs, with every claim it holds
+func decodeCredential(raw []byte) (VerifiableCredential, map[string]interface{}, error) {
+	var vc VerifiableCredential
+	if err := json.Unmarshal(raw, &vc); err != nil {
+		return vc, nil, err
+	}
+
+	var document map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return vc, nil, err
+	}
+	delete(document, "proof")
+
+	return vc, document, nil
+}
+
+// CredentialSchema references the schema a credential's claims follow
+type CredentialSchema struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
 }
 
 // Proof represents the proof structure for digital signature
 type Proof struct {
 	Type               string `json:"type"`
+	Cryptosuite        string `json:"cryptosuite,omitempty"`
 	Created            string `json:"created"`
 	ProofValue         string `json:"proofValue"`
 	ProofPurpose       string `json:"proofPurpose"`
 	VerificationMethod string `json:"verificationMethod"`
 }
 
-// VerifyCredential validates a Verifiable Credential against basic checks
-func VerifyCredential(vc VerifiableCredential) (bool, error) {
-	// Check if credential is expired
-	issuanceDate, err := time.Parse(time.RFC3339, vc.IssuanceDate)
+// clockSkew is how far the clocks of issuers and the verifier may drift apart: credentials that
+// became valid just now on the issuer's clock are not rejected as not valid yet
+const clockSkew = 5 * time.Minute
+
+var (
+	// ErrCredentialNotYetValid is returned for credentials whose validity period has not begun
+	ErrCredentialNotYetValid = errors.New("credential is not valid yet")
+	// ErrCredentialExpired is returned for credentials whose validity period has ended
+	ErrCredentialExpired = errors.New("credential has expired")
+)
+
+// checkValidityPeriod checks that a credential is valid at the given time, in either data
+// model. Credentials that do not say when they expire never expire.
+func checkValidityPeriod(vc VerifiableCredential, now time.Time) error {
+	from, until, err := validityWindow(vc)
 	if err != nil {
-		return false, errors.New("invalid issuance date")
+		return err
+	}
+	if !from.IsZero() && now.Add(clockSkew).Before(from) {
+		return fmt.Errorf("%w: valid from %s", ErrCredentialNotYetValid, from.Format(time.RFC3339))
+	}
+
+	if until.IsZero() {
+		return nil
+	}
+	if from.After(until) {
+		return errors.New("issuance date is after expiration date")
+	}
+	if now.After(until) {
+		return fmt.Errorf("%w: valid until %s", ErrCredentialExpired, until.Format(time.RFC3339))
 	}
+	return nil
+}
 
-	expirationDate, err := time.Parse(time.RFC3339, vc.ExpirationDate)
+// VerifyCredential validates a credential built in memory, see VerifyCredentialJSON
+func VerifyCredential(vc VerifiableCredential, resolver DIDResolver) (bool, error) {
+	raw, err := json.Marshal(vc)
 	if err != nil {
-		return false, errors.New("invalid expiration date")
+		return false, fmt.Errorf("failed to marshal credential: %w", err)
 	}
+	return VerifyCredentialJSON(raw, resolver)
+}
 
-	if time.Now().After(expirationDate) {
-		return false, errors.New("credential has expired")
+// VerifyCredentialJSON validates a Verifiable Credential's dates and its issuer's signature. The
+// signature is checked over the credential as received. Credentials of the 1.1 and 2.0 data
+// models are accepted.
+func VerifyCredentialJSON(raw []byte, resolver DIDResolver) (bool, error) {
+	vc, document, err := decodeCredential(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid credential: %w", err)
 	}
 
-	if issuanceDate.After(expirationDate) {
-		return false, errors.New("issuance date is after expiration date")
+	dataModel, err := credentialDataModel(vc)
+	if err != nil {
+		return false, err
+	}
+	if err := checkValidityPeriod(vc, time.Now()); err != nil {
+		return false, err
+	}
+	if err := checkCredentialStatus(vc, dataModel); err != nil {
+		return false, err
 	}
 
-	// Signature verification logic (simplified for now)
 	if vc.Proof.ProofValue == "" {
 		return false, errors.New("missing proof or invalid signature")
 	}
 
-	// Further checks can be added here (e.g., DID verification, schema validation)
+	// Resolve the issuer's verification method and check the signature against its public key
+	if err := verifyDocumentProof(vc, document, resolver); err != nil {
+		return false, err
+	}
+
+	// Further checks can be added here (e.g., schema validation)
 
 	return true, nil
 }
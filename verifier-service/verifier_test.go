@@ -0,0 +1,460 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bradtumy/credential-service/dataintegrity"
+	"github.com/cloudflare/circl/sign/bls"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/mr-tron/base58"
+)
+
+// staticResolver resolves DIDs from an in-memory set of DID documents
+type staticResolver map[string]map[string]interface{}
+
+func (s staticResolver) Resolve(did string) (map[string]interface{}, DIDDocumentMetadata, error) {
+	didDocument, ok := s[did]
+	if !ok {
+		return nil, DIDDocumentMetadata{}, ErrDIDNotFound
+	}
+	return didDocument, DIDDocumentMetadata{}, nil
+}
+
+// deactivatedResolver reports every DID of the wrapped resolver as deactivated at a fixed time
+type deactivatedResolver struct {
+	staticResolver
+	deactivatedAt string
+}
+
+func (d deactivatedResolver) Resolve(did string) (map[string]interface{}, DIDDocumentMetadata, error) {
+	didDocument, _, err := d.staticResolver.Resolve(did)
+	return didDocument, DIDDocumentMetadata{Updated: d.deactivatedAt, Deactivated: true}, err
+}
+
+// newTestIssuer generates a did:key issuer and returns its private key, DID and key ID
+func newTestIssuer(t *testing.T, resolver staticResolver) (ed25519.PrivateKey, string, string) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	multibaseKey := "z" + base58.Encode(append([]byte{0xed, 0x01}, publicKey...))
+	did := "did:key:" + multibaseKey
+	keyID := did + "#" + multibaseKey
+
+	resolver[did] = map[string]interface{}{
+		"id": did,
+		"verificationMethod": []interface{}{
+			map[string]interface{}{
+				"id":                 keyID,
+				"type":               "Ed25519VerificationKey2020",
+				"controller":         did,
+				"publicKeyMultibase": multibaseKey,
+			},
+		},
+		"assertionMethod": []interface{}{keyID},
+	}
+
+	return privateKey, did, keyID
+}
+
+// signTestCredential builds and signs a credential the same way issuer-service does
+func signTestCredential(t *testing.T, privateKey ed25519.PrivateKey, issuer, keyID string) VerifiableCredential {
+	t.Helper()
+
+	return signTestCredentialWith(t, dataintegrity.EdDSAJCS2022, issuer, keyID, func(hashData []byte) []byte {
+		return ed25519.Sign(privateKey, hashData)
+	})
+}
+
+// signTestCredentialWith builds a credential, applies the modifications and signs its proof hash
+// data with the given cryptosuite
+func signTestCredentialWith(t *testing.T, cryptosuite, issuer, keyID string, sign func(hashData []byte) []byte, modify ...func(vc *VerifiableCredential)) VerifiableCredential {
+	t.Helper()
+
+	now := time.Now().UTC()
+	vc := VerifiableCredential{
+		Context:           []string{"https://www.w3.org/2018/credentials/v1"},
+		Type:              []string{"VerifiableCredential"},
+		ID:                "urn:uuid:0f0d6a4e-7c44-4a1c-9f3c-7a4c0f4ba1de",
+		Issuer:            issuer,
+		IssuanceDate:      now.Format(time.RFC3339),
+		ExpirationDate:    now.AddDate(1, 0, 0).Format(time.RFC3339),
+		CredentialSubject: map[string]interface{}{"id": "did:example:holder", "name": "Jane Doe"},
+		CredentialSchema:  &CredentialSchema{ID: "https://schemas.example.com/v1/schemas/7/json-schema", Type: "JsonSchema"},
+		Proof: Proof{
+			Type:               proofTypeDataIntegrity,
+			Cryptosuite:        cryptosuite,
+			Created:            now.Format(time.RFC3339),
+			ProofPurpose:       proofPurposeAssertion,
+			VerificationMethod: keyID,
+		},
+	}
+
+	for _, m := range modify {
+		m(&vc)
+	}
+
+	document, err := dataintegrity.UnsecuredDocument(vc)
+	if err != nil {
+		t.Fatalf("Failed to decode credential: %v", err)
+	}
+	hashData, err := documentProofHashData(document, vc.Context, vc.Proof)
+	if err != nil {
+		t.Fatalf("Failed to build proof hash data: %v", err)
+	}
+	vc.Proof.ProofValue = "z" + base58.Encode(sign(hashData))
+
+	return vc
+}
+
+// addTestMultikey registers a did:key issuer for a multicodec prefixed public key and returns its DID and key ID
+func addTestMultikey(resolver staticResolver, multicodec, publicKey []byte) (string, string) {
+	multibaseKey := "z" + base58.Encode(append(append([]byte{}, multicodec...), publicKey...))
+	did := "did:key:" + multibaseKey
+	keyID := did + "#" + multibaseKey
+
+	resolver[did] = map[string]interface{}{
+		"id": did,
+		"verificationMethod": []interface{}{
+			map[string]interface{}{"id": keyID, "type": "Multikey", "controller": did, "publicKeyMultibase": multibaseKey},
+		},
+		"assertionMethod": []interface{}{keyID},
+	}
+
+	return did, keyID
+}
+
+func TestVerifyCredentialValidSignature(t *testing.T) {
+	resolver := staticResolver{}
+	privateKey, did, keyID := newTestIssuer(t, resolver)
+	vc := signTestCredential(t, privateKey, did, keyID)
+
+	valid, err := VerifyCredential(vc, resolver)
+	if err != nil || !valid {
+		t.Fatalf("Expected credential to verify, got %v", err)
+	}
+}
+
+func TestVerifyCredentialFailureReasons(t *testing.T) {
+	resolver := staticResolver{}
+	privateKey, did, keyID := newTestIssuer(t, resolver)
+	otherKey, _, otherKeyID := newTestIssuer(t, resolver)
+
+	tests := []struct {
+		name       string
+		credential func() VerifiableCredential
+		expected   error
+	}{
+		{
+			name: "unknown key",
+			credential: func() VerifiableCredential {
+				return signTestCredential(t, privateKey, did, did+"#keys-9")
+			},
+			expected: ErrUnknownVerificationMethod,
+		},
+		{
+			name: "unresolvable issuer",
+			credential: func() VerifiableCredential {
+				return signTestCredential(t, privateKey, "did:key:z6MkUnknown", "did:key:z6MkUnknown#keys-1")
+			},
+			expected: ErrUnknownVerificationMethod,
+		},
+		{
+			name: "method of another DID",
+			credential: func() VerifiableCredential {
+				return signTestCredential(t, otherKey, did, otherKeyID)
+			},
+			expected: ErrVerificationMethodNotIssuer,
+		},
+		{
+			name: "signature mismatch",
+			credential: func() VerifiableCredential {
+				vc := signTestCredential(t, privateKey, did, keyID)
+				vc.CredentialSubject["name"] = "Mallory"
+				return vc
+			},
+			expected: ErrInvalidSignature,
+		},
+		{
+			name: "schema swapped",
+			credential: func() VerifiableCredential {
+				vc := signTestCredential(t, privateKey, did, keyID)
+				vc.CredentialSchema = &CredentialSchema{ID: "https://schemas.example.com/v1/schemas/8/json-schema", Type: "JsonSchema"}
+				return vc
+			},
+			expected: ErrInvalidSignature,
+		},
+		{
+			name: "signed with the wrong key",
+			credential: func() VerifiableCredential {
+				return signTestCredential(t, otherKey, did, keyID)
+			},
+			expected: ErrInvalidSignature,
+		},
+		{
+			name: "unsupported proof",
+			credential: func() VerifiableCredential {
+				vc := signTestCredential(t, privateKey, did, keyID)
+				vc.Proof.Type = "Ed25519Signature2018"
+				return vc
+			},
+			expected: ErrUnsupportedProof,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, err := VerifyCredential(tt.credential(), resolver)
+			if valid {
+				t.Fatal("Expected verification to fail")
+			}
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestVerifyCredentialJSONUnsignedClaims(t *testing.T) {
+	resolver := staticResolver{}
+	privateKey, did, keyID := newTestIssuer(t, resolver)
+	raw, err := json.Marshal(signTestCredential(t, privateKey, did, keyID))
+	if err != nil {
+		t.Fatalf("Failed to marshal credential: %v", err)
+	}
+	if valid, err := VerifyCredentialJSON(raw, resolver); err != nil || !valid {
+		t.Fatalf("Expected the credential to verify, got %v", err)
+	}
+
+	// Claims added after signing are not covered by the proof, even if VerifiableCredential
+	// does not model them
+	for name, value := range map[string]interface{}{
+		"evidence":   []interface{}{map[string]interface{}{"type": "DocumentVerification"}},
+		"termsOfUse": map[string]interface{}{"type": "IssuerPolicy"},
+		"name":       "Employee of the Year",
+	} {
+		var document map[string]interface{}
+		json.Unmarshal(raw, &document)
+		document[name] = value
+		tampered, _ := json.Marshal(document)
+
+		if valid, err := VerifyCredentialJSON(tampered, resolver); valid || !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("Added %s: expected %v, got %v", name, ErrInvalidSignature, err)
+		}
+	}
+}
+
+func TestVerifyCredentialJSONUnmodelledClaims(t *testing.T) {
+	resolver := staticResolver{}
+	privateKey, did, keyID := newTestIssuer(t, resolver)
+
+	// A credential of another issuer, with claims VerifiableCredential does not model and an
+	// object issuer, signed over its JSON as issued
+	now := time.Now().UTC()
+	document := map[string]interface{}{
+		"@context":          []interface{}{credentialsContextV2},
+		"type":              []interface{}{"VerifiableCredential"},
+		"issuer":            map[string]interface{}{"id": did, "name": "Example University"},
+		"name":              "Bachelor of Science",
+		"validFrom":         now.Format(time.RFC3339),
+		"credentialSubject": map[string]interface{}{"id": "did:example:holder", "gpa": 3.7},
+		"evidence":          []interface{}{map[string]interface{}{"type": "Transcript"}},
+	}
+	proof := Proof{
+		Type:               proofTypeDataIntegrity,
+		Cryptosuite:        dataintegrity.EdDSAJCS2022,
+		Created:            now.Format(time.RFC3339),
+		ProofPurpose:       proofPurposeAssertion,
+		VerificationMethod: keyID,
+	}
+	hashData, err := documentProofHashData(document, document["@context"], proof)
+	if err != nil {
+		t.Fatalf("Failed to build proof hash data: %v", err)
+	}
+	proof.ProofValue = "z" + base58.Encode(ed25519.Sign(privateKey, hashData))
+	document["proof"] = proof
+	raw, _ := json.Marshal(document)
+
+	if valid, err := VerifyCredentialJSON(raw, resolver); err != nil || !valid {
+		t.Fatalf("Expected the credential to verify, got %v", err)
+	}
+
+	document["name"] = "Master of Science"
+	raw, _ = json.Marshal(document)
+	if valid, err := VerifyCredentialJSON(raw, resolver); valid || !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected %v, got %v", ErrInvalidSignature, err)
+	}
+}
+
+func TestVerifyCredentialExpired(t *testing.T) {
+	resolver := staticResolver{}
+	privateKey, did, keyID := newTestIssuer(t, resolver)
+	vc := signTestCredential(t, privateKey, did, keyID)
+	vc.ExpirationDate = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	if valid, err := VerifyCredential(vc, resolver); valid || err == nil {
+		t.Error("Expected an expired credential to be rejected")
+	}
+}
+
+func TestCheckValidityPeriod(t *testing.T) {
+	now := time.Date(2024, 9, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                         string
+		issuanceDate, expirationDate string
+		expected                     error
+	}{
+		{name: "valid", issuanceDate: "2024-09-01T00:00:00Z", expirationDate: "2025-09-01T00:00:00Z"},
+		{name: "never expires", issuanceDate: "2024-09-01T00:00:00Z"},
+		{name: "valid within clock skew", issuanceDate: "2024-09-05T12:04:00Z", expirationDate: "2025-09-01T00:00:00Z"},
+		{name: "not valid yet", issuanceDate: "2024-10-01T00:00:00Z", expirationDate: "2025-09-01T00:00:00Z", expected: ErrCredentialNotYetValid},
+		{name: "not valid yet and never expires", issuanceDate: "2024-10-01T00:00:00Z", expected: ErrCredentialNotYetValid},
+		{name: "expired", issuanceDate: "2023-09-01T00:00:00Z", expirationDate: "2024-09-01T00:00:00Z", expected: ErrCredentialExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vc := VerifiableCredential{Context: []string{credentialsContextV1}, IssuanceDate: tt.issuanceDate, ExpirationDate: tt.expirationDate}
+			err := checkValidityPeriod(vc, now)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	for _, vc := range []VerifiableCredential{
+		{Context: []string{credentialsContextV1}, IssuanceDate: "yesterday"},
+		{Context: []string{credentialsContextV1}, IssuanceDate: "2024-09-01T00:00:00Z", ExpirationDate: "next year"},
+		{Context: []string{credentialsContextV1}, IssuanceDate: "2024-09-01T00:00:00Z", ExpirationDate: "2024-08-01T00:00:00Z"},
+		{IssuanceDate: "2024-09-01T00:00:00Z"},
+	} {
+		if err := checkValidityPeriod(vc, now); err == nil {
+			t.Errorf("Expected an error for %+v", vc)
+		}
+	}
+}
+
+func TestVerifyCredentialNeverExpires(t *testing.T) {
+	resolver := staticResolver{}
+	privateKey, did, keyID := newTestIssuer(t, resolver)
+	vc := signTestCredentialWith(t, dataintegrity.EdDSAJCS2022, did, keyID, func(hashData []byte) []byte {
+		return ed25519.Sign(privateKey, hashData)
+	}, func(vc *VerifiableCredential) { vc.ExpirationDate = "" })
+
+	if valid, err := VerifyCredential(vc, resolver); err != nil || !valid {
+		t.Fatalf("Expected a credential without expiration date to verify, got %v", err)
+	}
+}
+
+func TestVerifyCredentialDeactivatedIssuer(t *testing.T) {
+	resolver := staticResolver{}
+	privateKey, did, keyID := newTestIssuer(t, resolver)
+	vc := signTestCredential(t, privateKey, did, keyID)
+	issuedAt, _ := time.Parse(time.RFC3339, vc.IssuanceDate)
+
+	// Credentials issued while the DID was active stay valid
+	later := deactivatedResolver{staticResolver: resolver, deactivatedAt: issuedAt.Add(time.Hour).Format(time.RFC3339)}
+	if valid, err := VerifyCredential(vc, later); err != nil || !valid {
+		t.Errorf("Expected a credential issued before deactivation to verify, got %v", err)
+	}
+
+	for _, deactivatedAt := range []string{issuedAt.Format(time.RFC3339), issuedAt.Add(-time.Hour).Format(time.RFC3339), ""} {
+		earlier := deactivatedResolver{staticResolver: resolver, deactivatedAt: deactivatedAt}
+		if valid, err := VerifyCredential(vc, earlier); valid || !errors.Is(err, ErrIssuerDeactivated) {
+			t.Errorf("Deactivated at %q: expected %v, got %v", deactivatedAt, ErrIssuerDeactivated, err)
+		}
+	}
+}
+
+func TestVerifyCredentialKeyTypes(t *testing.T) {
+	resolver := staticResolver{}
+
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate P-256 key: %v", err)
+	}
+	k1Key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate secp256k1 key: %v", err)
+	}
+	blsKey, err := bls.KeyGen[bls.KeyG2SigG1](make([]byte, 32), nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate BLS12-381 key: %v", err)
+	}
+	blsPublicKey, _ := blsKey.PublicKey().MarshalBinary()
+
+	tests := []struct {
+		name        string
+		multicodec  []byte
+		publicKey   []byte
+		cryptosuite string
+		sign        func(hashData []byte) []byte
+	}{
+		{
+			name:        "P-256",
+			multicodec:  []byte{0x80, 0x24},
+			publicKey:   elliptic.MarshalCompressed(elliptic.P256(), p256Key.X, p256Key.Y),
+			cryptosuite: "ecdsa-jcs-2019",
+			sign: func(hashData []byte) []byte {
+				digest := sha256.Sum256(hashData)
+				r, s, _ := ecdsa.Sign(rand.Reader, p256Key, digest[:])
+				return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+			},
+		},
+		{
+			name:        "secp256k1",
+			multicodec:  []byte{0xe7, 0x01},
+			publicKey:   k1Key.PubKey().SerializeCompressed(),
+			cryptosuite: "ecdsa-jcs-2019",
+			sign: func(hashData []byte) []byte {
+				digest := sha256.Sum256(hashData)
+				signature := secp256k1ecdsa.Sign(k1Key, digest[:])
+				r, s := signature.R(), signature.S()
+				rBytes, sBytes := r.Bytes(), s.Bytes()
+				return append(rBytes[:], sBytes[:]...)
+			},
+		},
+		{
+			name:        "BLS12-381 G2",
+			multicodec:  []byte{0xeb, 0x01},
+			publicKey:   blsPublicKey,
+			cryptosuite: "bls12381-jcs-2024",
+			sign: func(hashData []byte) []byte {
+				return bls.Sign(blsKey, hashData)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			did, keyID := addTestMultikey(resolver, tt.multicodec, tt.publicKey)
+			vc := signTestCredentialWith(t, tt.cryptosuite, did, keyID, tt.sign)
+			if valid, err := VerifyCredential(vc, resolver); err != nil || !valid {
+				t.Fatalf("Expected credential to verify, got %v", err)
+			}
+
+			vc.CredentialSubject["name"] = "Mallory"
+			if valid, err := VerifyCredential(vc, resolver); valid || !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Expected %v, got %v", ErrInvalidSignature, err)
+			}
+
+			// A proof must use the cryptosuite of the key it names
+			vc = signTestCredentialWith(t, dataintegrity.EdDSAJCS2022, did, keyID, tt.sign)
+			if valid, err := VerifyCredential(vc, resolver); valid || !errors.Is(err, ErrUnsupportedProof) {
+				t.Errorf("Expected %v, got %v", ErrUnsupportedProof, err)
+			}
+		})
+	}
+}